@@ -18,12 +18,16 @@ package v1beta1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // CloudflareSpec defines the desired state of Cloudflare.
+// +kubebuilder:validation:XValidation:rule="has(self.tunnel_name) != has(self.tunnelRef)",message="exactly one of tunnel_name or tunnelRef must be set"
+// +kubebuilder:validation:XValidation:rule="!(has(self.secretRef) && has(self.accountRef))",message="secretRef and accountRef are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!(has(self.tunnelRef) && has(self.fallback))",message="fallback must be set on the Tunnel when tunnelRef is used"
 type CloudflareSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
@@ -40,23 +44,283 @@ type CloudflareSpec struct {
 
 	// Replicas int32 `json:"replicas,omitempty"`
 
-	//+kubebuilder:validation:Required
+	// TunnelName を指定すると、このリソース専用の Tunnel を作成（同名があれば再利用）します。
+	// tunnelRef とはどちらか一方のみ指定できます。
+	// +optional
+	TunnelName string `json:"tunnel_name,omitempty"`
 
-	TunnelName string `json:"tunnel_name"`
+	// TunnelRef は共有する Tunnel リソースを参照します。
+	// 同じ Tunnel を参照する全ての Cloudflare リソースの ingress ルールは、1 つの cloudflared 設定と Deployment にまとめられます。
+	// +optional
+	TunnelRef *TunnelReference `json:"tunnelRef,omitempty"`
 
 	//+kubebuilder:validation:Required
 	// +kubebuilder:default=1
 
 	Replicas int32 `json:"replicas,omitempty"`
+
+	// CredentialsSource は DNS レコードや Tunnel の操作に使う Cloudflare API の認証情報の取得元です。
+	// tunnelRef を指定して secretRef と accountRef を省略した場合は、参照先の Tunnel の認証情報を Tunnel の namespace で使います。
+	CredentialsSource `json:",inline"`
+
+	// ConfigSource は tunnel_name の Tunnel の ingress 設定の管理場所です。
+	// tunnelRef の場合は参照先の Tunnel の設定に従います。
+	// +kubebuilder:default=Local
+	// +optional
+	ConfigSource ConfigSource `json:"configSource,omitempty"`
+
+	// CredentialsRecovery は、tunnel_name の Tunnel が既に存在した時に credentials.json を用意する方法です。
+	// +kubebuilder:default=Token
+	// +optional
+	CredentialsRecovery CredentialsRecoveryPolicy `json:"credentialsRecovery,omitempty"`
+
+	// OriginRequest は全ての ingress ルールに適用する cloudflared の originRequest 設定です。
+	// ルールごとの originRequest で項目単位に上書きできます。
+	// +optional
+	OriginRequest *OriginRequest `json:"originRequest,omitempty"`
+
+	// Fallback はどのルールにも一致しなかったリクエストの転送先です。省略した場合は 404 を返します。
+	// tunnelRef の場合は参照先の Tunnel の fallback に従います。
+	// +optional
+	Fallback *FallbackService `json:"fallback,omitempty"`
+
+	// DNS は全ての ingress ルールのホスト名に作成する DNS レコードの設定です。
+	// ルールごとの dns で項目単位に上書きできます。
+	// +optional
+	DNS *DNSSettings `json:"dns,omitempty"`
+
+	// DNSManagement は ingress ルールのホスト名の DNS レコードの管理方法です。
+	// ルールごとの dnsManagement で上書きできます。
+	// +kubebuilder:default=Managed
+	// +optional
+	DNSManagement DNSManagementPolicy `json:"dnsManagement,omitempty"`
+
+	// DriftPolicy は Tunnel や DNS レコードがこのオペレータ以外から削除・変更されていた時の扱いです。
+	// どちらの場合も Drifted 条件と Event で報告します。
+	// tunnelRef の Tunnel は Tunnel リソースが作り直すため、Report では DNS レコードを作り直した Tunnel に向け直しません。
+	// +kubebuilder:default=Correct
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+}
+
+// DriftPolicy は Cloudflare 上のリソースが外部から削除・変更されていた時の扱いです。
+// +kubebuilder:validation:Enum=Correct;Report
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect は削除された Tunnel や DNS レコードを作り直し、変更されたレコードを元に戻します。
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyReport は変更を報告するだけで、Cloudflare 上のリソースには手を加えません。
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
+// TunnelReference は Tunnel リソースへの参照です。
+type TunnelReference struct {
+	// Name は Tunnel リソース名です。
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace は Tunnel リソースの namespace です。省略した場合は参照元と同じ namespace です。
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
+
+// +kubebuilder:validation:XValidation:rule="has(self.service) != has(self.serviceRef)",message="exactly one of service or serviceRef must be set"
+// +kubebuilder:validation:XValidation:rule="!(has(self.zone) && has(self.zoneID))",message="zone and zoneID are mutually exclusive"
 type IngressRule struct {
+	// Hostname はルールに一致させるホスト名です。"*.example.com" のように左端のラベルをワイルドカードにできます。
+	// 省略するか "*" とした場合は全てのホスト名に一致し、DNS レコードは作成しません。
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+
+	// Path はリクエストのパスに一致させる正規表現です。省略した場合は全てのパスに一致します。
+	// hostname が空か "*" で path も空のルールは全てのリクエストに一致するため、最後のルールにしか置けません。
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Service は転送先の URL（http://nginx-service:80 など）や http_status:404 のような cloudflared の service の値です。
+	// serviceRef とはどちらか一方のみ指定できます。
+	// +optional
+	Service string `json:"service,omitempty"`
+
+	// ServiceRef はクラスタ内の Service を参照します。コントローラがクラスタ内の DNS 名の URL に変換します。
+	// +optional
+	ServiceRef *ServiceReference `json:"serviceRef,omitempty"`
+
+	// OriginRequest はこのルールだけに適用する originRequest 設定です。spec.originRequest の同じ項目を上書きします。
+	// +optional
+	OriginRequest *OriginRequest `json:"originRequest,omitempty"`
+
+	// Zone は hostname の DNS レコードを作成するゾーン名です。
+	// 省略した場合は、アカウントのゾーンのうち hostname を含む最も長いゾーンを使います。
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// ZoneID は hostname の DNS レコードを作成するゾーンの ID です。zone とはどちらか一方のみ指定できます。
+	// 認証情報のアカウントにあり、hostname を含むゾーンでなければなりません。
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+
+	// DNS はこのルールのホスト名の DNS レコードの設定です。spec.dns の同じ項目を上書きします。
+	// +optional
+	DNS *DNSSettings `json:"dns,omitempty"`
+
+	// DNSManagement はこのルールのホスト名の DNS レコードの管理方法です。省略した場合は spec.dnsManagement に従います。
+	// +optional
+	DNSManagement DNSManagementPolicy `json:"dnsManagement,omitempty"`
+}
+
+// DNSManagementPolicy は ingress ルールのホスト名の DNS レコードの管理方法です。
+// Managed 以外に切り替えた場合、このリソースが作成して所有している DNS レコードは削除します。
+// +kubebuilder:validation:Enum=Managed;Disabled;ExternalDNS
+type DNSManagementPolicy string
+
+const (
+	// DNSManagementManaged は Cloudflare の DNS API で Tunnel を指す CNAME を作成します。
+	DNSManagementManaged DNSManagementPolicy = "Managed"
+	// DNSManagementDisabled は DNS レコードを操作しません。レコードは利用者が用意します。
+	DNSManagementDisabled DNSManagementPolicy = "Disabled"
+	// DNSManagementExternalDNS は external-dns の DNSEndpoint リソースを作成し、レコードの作成を external-dns に任せます。
+	DNSManagementExternalDNS DNSManagementPolicy = "ExternalDNS"
+)
+
+// FallbackService は cloudflared の ingress の最後に置く、全てのリクエストに一致するルールの転送先です。
+// httpStatus、serviceRef、helloWorld のいずれか 1 つを指定します。
+// +kubebuilder:validation:XValidation:rule="[has(self.httpStatus), has(self.serviceRef), has(self.helloWorld) && self.helloWorld].filter(x, x).size() == 1",message="exactly one of httpStatus, serviceRef or helloWorld must be set"
+type FallbackService struct {
+	// HTTPStatus はこのステータスコードを返します。
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	// +optional
+	HTTPStatus *int32 `json:"httpStatus,omitempty"`
+
+	// ServiceRef はクラスタ内の Service に転送します。
+	// +optional
+	ServiceRef *ServiceReference `json:"serviceRef,omitempty"`
+
+	// HelloWorld を true にすると cloudflared 組み込みのテスト用サーバーに転送します。
+	// +optional
+	HelloWorld bool `json:"helloWorld,omitempty"`
+}
+
+// ServiceReference はクラスタ内の Service のポートへの参照です。
+type ServiceReference struct {
+	// Name は Service 名です。
 	//+kubebuilder:validation:Required
+	Name string `json:"name"`
 
-	Hostname string `json:"hostname"`
+	// Namespace は Service の namespace です。省略した場合は参照元と同じ namespace です。
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port は Service のポートの名前または番号です。
+	//+kubebuilder:validation:Required
+	Port intstr.IntOrString `json:"port"`
+
+	// Scheme は cloudflared が Service に接続する時のスキームです。
+	// +kubebuilder:validation:Enum=http;https;tcp;ssh;rdp
+	// +kubebuilder:default=http
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// OriginRequest は cloudflared が origin に接続する時の設定です。
+// 省略した項目は cloudflared の既定値（ルールの場合は spec.originRequest の値）を使います。
+type OriginRequest struct {
+	// ConnectTimeout は origin への接続を確立するまでのタイムアウトです。
+	// +optional
+	ConnectTimeout *metav1.Duration `json:"connectTimeout,omitempty"`
+
+	// TLSTimeout は origin との TLS ハンドシェイクのタイムアウトです。
+	// +optional
+	TLSTimeout *metav1.Duration `json:"tlsTimeout,omitempty"`
+
+	// TCPKeepAlive は origin との TCP keepalive の間隔です。
+	// +optional
+	TCPKeepAlive *metav1.Duration `json:"tcpKeepAlive,omitempty"`
+
+	// NoHappyEyeballs を true にすると IPv4/IPv6 のフォールバックを無効にします。
+	// +optional
+	NoHappyEyeballs *bool `json:"noHappyEyeballs,omitempty"`
+
+	// KeepAliveConnections は keepalive で保持する接続数の上限です。
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	KeepAliveConnections *int32 `json:"keepAliveConnections,omitempty"`
+
+	// KeepAliveTimeout はアイドル状態の接続を閉じるまでの時間です。
+	// +optional
+	KeepAliveTimeout *metav1.Duration `json:"keepAliveTimeout,omitempty"`
 
+	// HTTPHostHeader は origin に送る Host ヘッダーです。
+	// +optional
+	HTTPHostHeader *string `json:"httpHostHeader,omitempty"`
+
+	// OriginServerName は origin の証明書で期待するホスト名です。
+	// +optional
+	OriginServerName *string `json:"originServerName,omitempty"`
+
+	// CAPool は origin の証明書を検証する CA 証明書のパスです。cloudflared のコンテナから見えるパスを指定します。
+	// +optional
+	CAPool *string `json:"caPool,omitempty"`
+
+	// NoTLSVerify を true にすると origin の証明書を検証しません。
+	// +optional
+	NoTLSVerify *bool `json:"noTLSVerify,omitempty"`
+
+	// HTTP2Origin を true にすると origin に HTTP/2 で接続します。
+	// +optional
+	HTTP2Origin *bool `json:"http2Origin,omitempty"`
+
+	// DisableChunkedEncoding を true にすると chunked transfer encoding を無効にします。
+	// +optional
+	DisableChunkedEncoding *bool `json:"disableChunkedEncoding,omitempty"`
+
+	// ProxyType は cloudflared をプロキシとして動かす時の種類です。現在は socks のみ指定できます。
+	// +kubebuilder:validation:Enum=socks
+	// +optional
+	ProxyType *string `json:"proxyType,omitempty"`
+
+	// Access を指定すると Cloudflare Access の JWT を検証したリクエストだけを origin に転送します。
+	// +optional
+	Access *OriginAccess `json:"access,omitempty"`
+}
+
+// OriginAccess は Cloudflare Access の JWT 検証の設定です。
+type OriginAccess struct {
+	// Required を true にすると Access の JWT が無いリクエストを拒否します。
+	// +optional
+	Required bool `json:"required,omitempty"`
+
+	// TeamName は Zero Trust の組織（チーム）名です。
 	//+kubebuilder:validation:Required
+	TeamName string `json:"teamName"`
+
+	// AudTag は JWT の検証に使う Access アプリケーションの Audience タグです。
+	// +optional
+	AudTag []string `json:"audTag,omitempty"`
+}
 
-	Service string `json:"service"`
+// DNSSettings は Tunnel を指す CNAME レコードの設定です。
+type DNSSettings struct {
+	// Proxied は Cloudflare のプロキシを経由させるかどうかです。省略した場合は true です。
+	// +optional
+	Proxied *bool `json:"proxied,omitempty"`
+
+	// TTL はレコードの TTL（秒）です。1 は自動を表し、省略した場合も自動です。
+	// proxied のレコードの TTL は Cloudflare が常に自動にするため、この値は使いません。
+	// +kubebuilder:validation:XValidation:rule="self == 1 || (self >= 30 && self <= 86400)",message="ttl must be 1 (automatic) or between 30 and 86400"
+	// +optional
+	TTL *int32 `json:"ttl,omitempty"`
+
+	// Comment はレコードのコメントです。
+	// +kubebuilder:validation:MaxLength=100
+	// +optional
+	Comment string `json:"comment,omitempty"`
+
+	// Tags はレコードのタグです。"name:value" の形式で書きます。
+	// +optional
+	Tags []string `json:"tags,omitempty"`
 }
 
 // CloudflareStatus defines the observed state of Cloudflare.
@@ -64,16 +328,134 @@ type CloudflareStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// TunnelID は使用中の Cloudflare 上の Tunnel の ID です。
+	// +optional
+	TunnelID string `json:"tunnelID,omitempty"`
+
+	// TunnelName は使用中の Cloudflare 上の Tunnel 名です。
+	// +optional
+	TunnelName string `json:"tunnelName,omitempty"`
+
+	// TunnelCredentials は tunnelRef の Tunnel から引き継いだ認証情報の取得元です。
+	// Tunnel リソースが先に削除された場合も、この取得元で DNS レコードを削除します。
+	// +optional
+	TunnelCredentials *InheritedCredentials `json:"tunnelCredentials,omitempty"`
+
+	// ObservedGeneration は最後に調整を終えた spec の世代です。
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas は cloudflared の Deployment の希望レプリカ数です。tunnelRef の場合は共有 Tunnel の Deployment の値です。
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// ReadyReplicas は cloudflared の Deployment の準備ができたレプリカ数です。
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Hostnames は ingress ルールのホスト名ごとの DNS レコードの状態です。
+	// +optional
+	Hostnames []HostnameStatus `json:"hostnames,omitempty"`
+
+	// TunnelHealth は Cloudflare が報告する Tunnel の接続状態です。tunnelRef の場合は共有 Tunnel の status の値です。
+	TunnelHealth `json:",inline"`
+
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// InheritedCredentials は Tunnel から引き継いだ認証情報の取得元と、それを解決する Tunnel の namespace です。
+type InheritedCredentials struct {
+	// Namespace は secretRef の Secret を探し、accountRef の allowedNamespaces と照合する namespace です。
+	Namespace string `json:"namespace"`
+
+	CredentialsSource `json:",inline"`
+}
+
+// HostnameStatus はホスト名の DNS レコードの状態です。
+type HostnameStatus struct {
+	// Hostname は ingress ルールのホスト名です。
+	Hostname string `json:"hostname"`
+
+	// Zone は DNS レコードを作成したゾーン名です。
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// ZoneID は DNS レコードを作成したゾーンの ID です。
+	// +optional
+	ZoneID string `json:"zoneID,omitempty"`
+
+	// RecordID は Tunnel を指す CNAME レコードの ID です。
+	// +optional
+	RecordID string `json:"recordID,omitempty"`
+
+	// State は DNS レコードの状態です。
+	State DNSRecordState `json:"state"`
+
+	// Message は State の詳細です。
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// DNSRecordState はホスト名の DNS レコードの状態です。
+// +kubebuilder:validation:Enum=Synced;Conflict;Error;Unmanaged;Drifted
+type DNSRecordState string
+
 const (
+	// DNSRecordSynced は Tunnel を指す CNAME を作成し、設定どおりになっていることを表します。
+	DNSRecordSynced DNSRecordState = "Synced"
+	// DNSRecordConflict は他の所有者のレコードがあり、変更していないことを表します。
+	DNSRecordConflict DNSRecordState = "Conflict"
+	// DNSRecordError はゾーンの解決や Cloudflare API の呼び出しに失敗したことを表します。
+	DNSRecordError DNSRecordState = "Error"
+	// DNSRecordUnmanaged は dnsManagement が Managed ではなく、Cloudflare の DNS API でレコードを操作しないことを表します。
+	DNSRecordUnmanaged DNSRecordState = "Unmanaged"
+	// DNSRecordDrifted は同期したレコードが外部から削除・変更され、driftPolicy が Report のため元に戻していないことを表します。
+	DNSRecordDrifted DNSRecordState = "Drifted"
+)
+
+const (
+	// TypeCloudflareReady は CredentialsReady、TunnelReady、ConfigReady、DeploymentAvailable、DNSSynced の全てが True で、
+	// ホスト名が Tunnel 経由で公開されていることを表す条件です。False の場合は最初に満たされていない条件の理由を記録します。
+	TypeCloudflareReady = "Ready"
+
+	// TypeCloudflareTunnelReady は Tunnel とその credentials.json を用意できたことを表す条件です。
+	// tunnelRef の場合は参照先の Tunnel リソースの準備ができたことを表します。
+	TypeCloudflareTunnelReady = "TunnelReady"
+
+	// TypeConfigReady は cloudflared の ingress 設定（ConfigMap または Cloudflare 上の設定）と Deployment を描画できたことを表す条件です。
+	TypeConfigReady = "ConfigReady"
+
+	// TypeDeploymentAvailable は cloudflared の Deployment に利用可能なレプリカがあることを表す条件です。
+	TypeDeploymentAvailable = "DeploymentAvailable"
+
+	// TypeDNSSynced は dnsManagement が Managed の全てのホスト名の DNS レコードが Tunnel を指していることを表す条件です。
+	TypeDNSSynced = "DNSSynced"
+
+	// Deprecated: 以前のバージョンの条件です。Ready を使ってください。
 	TypeCloudflareViewAvailable = "Available"
-	TypeCloudflareViewDegraded  = "Degraded"
+	// Deprecated: 以前のバージョンの条件です。Ready を使ってください。
+	TypeCloudflareViewDegraded = "Degraded"
+
+	// TypeBackendNotFound は serviceRef で参照している Service またはそのポートが見つからないことを表す条件です。
+	TypeBackendNotFound = "BackendNotFound"
+
+	// TypeDNSRecordConflict は ingress ルールのホスト名に、他の所有者が管理する DNS レコードがあることを表す条件です。
+	// この場合、レコードは変更せずにメッセージへ衝突の内容を記録します。
+	TypeDNSRecordConflict = "DNSRecordConflict"
+
+	// TypeDrifted は Tunnel や DNS レコードがこのオペレータ以外から削除・変更されたまま残っていることを表す条件です。
+	// driftPolicy が Correct の場合は元に戻すため False で、直前の Reconcile で元に戻した内容をメッセージに記録します。
+	TypeDrifted = "Drifted"
 )
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Tunnel",type=string,JSONPath=`.status.tunnelName`
+// +kubebuilder:printcolumn:name="Tunnel ID",type=string,JSONPath=`.status.tunnelID`,priority=1
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Cloudflare is the Schema for the cloudflares API.
 type Cloudflare struct {
@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// ClusterCloudflareAccountSpec defines the desired state of ClusterCloudflareAccount.
+// +kubebuilder:validation:XValidation:rule="has(self.apiTokenSecretRef) != has(self.apiKeySecretRef)",message="exactly one of apiTokenSecretRef or apiKeySecretRef must be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.apiKeySecretRef) || has(self.email)",message="email is required when apiKeySecretRef is set"
+type ClusterCloudflareAccountSpec struct {
+	// AccountID は Cloudflare のアカウント ID です。Tunnel はこのアカウントに作成されます。
+	//+kubebuilder:validation:Required
+	AccountID string `json:"accountID"`
+
+	// APITokenSecretRef は API トークンを格納した Secret のキーを参照します。キーの既定値は apiToken です。
+	// +optional
+	APITokenSecretRef *SecretKeyReference `json:"apiTokenSecretRef,omitempty"`
+
+	// APIKeySecretRef は Global API Key を格納した Secret のキーを参照します。キーの既定値は apiKey です。
+	// 指定する場合は email も必要です。
+	// +optional
+	APIKeySecretRef *SecretKeyReference `json:"apiKeySecretRef,omitempty"`
+
+	// Email は Global API Key に対応するアカウントのメールアドレスです。
+	// +optional
+	Email string `json:"email,omitempty"`
+
+	// AllowedNamespaces はこのアカウントを参照できる namespace の一覧です。省略した場合は全ての namespace から参照できます。
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// SecretKeyReference は任意の namespace にある Secret の 1 つのキーを参照します。
+type SecretKeyReference struct {
+	// Name は Secret 名です。
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace は Secret の namespace です。
+	//+kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// Key は Secret のキーです。
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// CredentialsSource は Cloudflare API の認証情報の取得元です。
+// secretRef と accountRef のどちらも指定しない場合は、従来どおり default/cloudflare-api-token を使います。
+type CredentialsSource struct {
+	// SecretRef はリソースと同じ namespace にある認証情報の Secret を参照します。
+	// Secret には account_id と、apiToken または apiKey と email を格納します。
+	// +optional
+	SecretRef *SecretReference `json:"secretRef,omitempty"`
+
+	// AccountRef は ClusterCloudflareAccount を参照します。
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
+}
+
+// SecretReference はリソースと同じ namespace にある Secret への参照です。
+type SecretReference struct {
+	// Name は Secret 名です。
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// AccountReference は ClusterCloudflareAccount への参照です。
+type AccountReference struct {
+	// Name は ClusterCloudflareAccount 名です。
+	//+kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// ClusterCloudflareAccountStatus defines the observed state of ClusterCloudflareAccount.
+type ClusterCloudflareAccountStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+const (
+	// TypeCredentialsReady は参照先から Cloudflare API の認証情報を取得できたかを表す条件です。
+	TypeCredentialsReady = "CredentialsReady"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Account ID",type=string,JSONPath=`.spec.accountID`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterCloudflareAccount is the Schema for the clustercloudflareaccounts API.
+type ClusterCloudflareAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterCloudflareAccountSpec   `json:"spec,omitempty"`
+	Status ClusterCloudflareAccountStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterCloudflareAccountList contains a list of ClusterCloudflareAccount.
+type ClusterCloudflareAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterCloudflareAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCloudflareAccount{}, &ClusterCloudflareAccountList{})
+}
@@ -24,20 +24,46 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // TunnelSpec defines the desired state of Tunnel.
+// +kubebuilder:validation:XValidation:rule="!(has(self.secretRef) && has(self.accountRef))",message="secretRef and accountRef are mutually exclusive"
 type TunnelSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// TunnelID が指定されていれば、その Tunnel を再利用します。
-	// 指定がなければ、Reconcile で新規作成します。
-
+	// TunnelName は Cloudflare 上の Tunnel 名です。
+	// 同名の Tunnel が既に存在すればそれを再利用し、なければ Reconcile で新規作成します。
+	// 省略した場合はリソース名を使います。
 	// +optional
-	TunnelName string `json:"tunnel_name"`
+	TunnelName string `json:"tunnel_name,omitempty"`
 
 	//+kubebuilder:validation:Required
 	// +kubebuilder:default=1
 
 	Replicas int32 `json:"replicas,omitempty"`
+
+	// CredentialsSource は Tunnel の作成に使う Cloudflare API の認証情報の取得元です。
+	CredentialsSource `json:",inline"`
+
+	// ConfigSource は ingress 設定の管理場所です。Cloudflare の場合は設定を Cloudflare に登録し、
+	// cloudflared はトークンで起動します。ConfigMap は作成せず、設定の変更で Pod は再起動しません。
+	// +kubebuilder:default=Local
+	// +optional
+	ConfigSource ConfigSource `json:"configSource,omitempty"`
+
+	// CredentialsRecovery は、既存の Tunnel を再利用した時に credentials.json を用意する方法です。
+	// +kubebuilder:default=Token
+	// +optional
+	CredentialsRecovery CredentialsRecoveryPolicy `json:"credentialsRecovery,omitempty"`
+
+	// Fallback は、この Tunnel を参照する全ての Cloudflare リソースのルールに一致しなかったリクエストの転送先です。
+	// 省略した場合は 404 を返します。
+	// +optional
+	Fallback *FallbackService `json:"fallback,omitempty"`
+
+	// AllowedNamespaces は、Tunnel と異なる namespace から tunnelRef でこの Tunnel を参照できる namespace の一覧です。
+	// Tunnel と同じ namespace のリソースは常に参照できます。省略した場合は他の namespace から参照できません。
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
 	// CredentialsSecret は、Cloudflare の認証情報を格納する Secret 名です。
 	// 指定がなければ、Reconcile 時に自動生成した Secret 名を利用し、
 	// その Secret に認証情報を登録します。
@@ -45,16 +71,100 @@ type TunnelSpec struct {
 	// CredentialsSecret string `json:"credentialsSecret"`
 }
 
+// ConfigSource は cloudflared の ingress 設定の管理場所です。
+// +kubebuilder:validation:Enum=Local;Cloudflare
+type ConfigSource string
+
+const (
+	// ConfigSourceLocal は ConfigMap の config.yaml で設定を管理します。
+	ConfigSourceLocal ConfigSource = "Local"
+	// ConfigSourceCloudflare は Tunnel の設定 API で設定を管理します（リモート管理の Tunnel）。
+	ConfigSourceCloudflare ConfigSource = "Cloudflare"
+)
+
+// CredentialsRecoveryPolicy は、作成時のシークレットが手元にない Tunnel の credentials.json を用意する方法です。
+// +kubebuilder:validation:Enum=Token;Rotate
+type CredentialsRecoveryPolicy string
+
+const (
+	// CredentialsRecoveryToken はトンネルトークンから Tunnel のシークレットを取り出します。
+	CredentialsRecoveryToken CredentialsRecoveryPolicy = "Token"
+	// CredentialsRecoveryRotate は、トンネルトークンを取得できない場合に Tunnel のシークレットを新しく置き換えます。
+	// 置き換え前のシークレットで接続している cloudflared は切断されます。
+	CredentialsRecoveryRotate CredentialsRecoveryPolicy = "Rotate"
+)
+
 // TunnelStatus defines the observed state of Tunnel.
 type TunnelStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	Phase      string             `json:"phase,omitempty"`
+	Phase string `json:"phase,omitempty"`
+
+	// TunnelID は Cloudflare 上の Tunnel の ID です。
+	// +optional
+	TunnelID string `json:"tunnelID,omitempty"`
+
+	// Created は TunnelID の Tunnel をこの operator が作成したかどうかです。
+	// 既存の同名の Tunnel を再利用した場合は false で、リソースを削除しても Cloudflare 上の Tunnel は残します。
+	// +optional
+	Created bool `json:"created,omitempty"`
+
+	// CredentialsSecret は Tunnel の credentials.json を格納した Secret 名です。
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// TunnelHealth は Cloudflare が報告する Tunnel の接続状態です。
+	TunnelHealth `json:",inline"`
+
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// TunnelHealth は Cloudflare が報告する Tunnel の状態と、cloudflared がエッジと確立している接続です。
+// コントローラの --tunnel-health-interval の間隔で取り直します。
+type TunnelHealth struct {
+	// Health は Cloudflare が報告する Tunnel の状態で、healthy、degraded、down、inactive のいずれかです。
+	// +optional
+	Health string `json:"health,omitempty"`
+
+	// Connections は Cloudflare のエッジと確立している接続数です。
+	// +optional
+	Connections int32 `json:"connections,omitempty"`
+
+	// Colos は接続先のデータセンターの名前です。
+	// +optional
+	Colos []string `json:"colos,omitempty"`
+
+	// Versions は接続している cloudflared のバージョンです。
+	// +optional
+	Versions []string `json:"versions,omitempty"`
+}
+
+const (
+	TunnelPhasePending  = "Pending"
+	TunnelPhaseReady    = "Ready"
+	TunnelPhaseFailed   = "Failed"
+	TunnelPhaseDeleting = "Deleting"
+)
+
+const (
+	TypeTunnelReady = "Ready"
+
+	// TypeCredentialsUnavailable は Tunnel の credentials.json を用意できていないことを表す条件です。
+	// Tunnel と、tunnel_name で Tunnel を作成する Cloudflare リソースに記録します。
+	TypeCredentialsUnavailable = "CredentialsUnavailable"
+
+	// TypeTunnelHealthy は Cloudflare が Tunnel を healthy と報告していることを表す条件です。
+	// Tunnel と、tunnel_name または tunnelRef で Tunnel を使う Cloudflare リソースに記録します。
+	TypeTunnelHealthy = "TunnelHealthy"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Tunnel ID",type=string,JSONPath=`.status.tunnelID`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
+// +kubebuilder:printcolumn:name="Connections",type=integer,JSONPath=`.status.connections`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Tunnel is the Schema for the tunnels API.
 type Tunnel struct {
@@ -21,12 +21,15 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -36,8 +39,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
 	"github.com/laininthewired/cloudflare-ingress-controller/internal/controller"
 	webhookcloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/internal/webhook/v1beta1"
 	// +kubebuilder:scaffold:imports
@@ -52,6 +57,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(cloudflarev1beta1.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1.Install(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -64,6 +70,11 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var clusterID string
+	var tunnelHealthInterval time.Duration
+	var resyncPeriod time.Duration
+	apiRateLimit := cfapi.DefaultRateLimit()
+	var apiCacheTTL time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -82,6 +93,23 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&clusterID, "cluster-id", controller.DefaultClusterID,
+		"The identifier of this cluster recorded in the owner TXT records of DNS records. "+
+			"Use a distinct value for each cluster that manages the same zones.")
+	flag.DurationVar(&tunnelHealthInterval, "tunnel-health-interval", controller.DefaultTunnelHealthInterval,
+		"How often the status and connections of each tunnel are polled from Cloudflare.")
+	flag.DurationVar(&resyncPeriod, "resync-period", controller.DefaultResyncPeriod,
+		"How often each Cloudflare resource is reconciled to detect tunnels and DNS records deleted or changed outside the operator. "+
+			"Changes may take up to --cloudflare-api-cache-ttl longer to be noticed.")
+	flag.Float64Var(&apiRateLimit.RequestsPerSecond, "cloudflare-api-qps", cfapi.DefaultRequestsPerSecond,
+		"The sustained rate of Cloudflare API requests per second for each API token.")
+	flag.IntVar(&apiRateLimit.Burst, "cloudflare-api-burst", cfapi.DefaultBurst,
+		"The number of Cloudflare API requests that may be sent at once for each API token.")
+	flag.IntVar(&apiRateLimit.MaxRetries, "cloudflare-api-max-retries", cfapi.DefaultMaxRetries,
+		"How many times a Cloudflare API request is retried after a 429 response, or a 5xx response to a GET, PUT or DELETE.")
+	flag.DurationVar(&apiCacheTTL, "cloudflare-api-cache-ttl", cfapi.DefaultCacheTTL,
+		"How long zones, tunnel lists and DNS records fetched from Cloudflare are reused across reconciles. "+
+			"Set to 0 to disable the cache.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -203,9 +231,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Cloudflare API のクライアントは API トークンごとに使い回してレート制限を共有し、
+	// 呼び出しを metrics エンドポイントで公開するメトリクスに記録する。
+	// 一覧はキャッシュするため、メトリクスには実際に Cloudflare へ送った呼び出しだけが残る
+	apiFactory := cfapi.WithCache(cfapi.WithMetrics(cfapi.NewSharedClient(apiRateLimit)), apiCacheTTL)
+
 	if err = (&controller.CloudflareReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		APIFactory:           apiFactory,
+		ClusterID:            clusterID,
+		TunnelHealthInterval: tunnelHealthInterval,
+		Recorder:             mgr.GetEventRecorderFor("cloudflare-controller"),
+		ResyncPeriod:         resyncPeriod,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cloudflare")
 		os.Exit(1)
@@ -213,18 +251,67 @@ func main() {
 
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = webhookcloudflarev1beta1.SetupCloudflareWebhookWithManager(mgr); err != nil {
+		if err = webhookcloudflarev1beta1.SetupCloudflareWebhookWithManager(mgr, apiFactory); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Cloudflare")
 			os.Exit(1)
 		}
 	}
-	// if err = (&controller.TunnelReconciler{
-	// 	Client: mgr.GetClient(),
-	// 	Scheme: mgr.GetScheme(),
-	// }).SetupWithManager(mgr); err != nil {
-	// 	setupLog.Error(err, "unable to create controller", "controller", "Tunnel")
-	// 	os.Exit(1)
-	// }
+	if err = (&controller.TunnelReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		APIFactory:           apiFactory,
+		TunnelHealthInterval: tunnelHealthInterval,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Tunnel")
+		os.Exit(1)
+	}
+	if err = (&controller.IngressReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("ingress-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+		os.Exit(1)
+	}
+	if err = (&controller.ServiceReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("service-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Service")
+		os.Exit(1)
+	}
+	// Gateway API の CRD がインストールされていないクラスタでは Gateway 系のコントローラを起動しない
+	_, err = mgr.GetRESTMapper().RESTMapping(schema.GroupKind{Group: gatewayv1.GroupName, Kind: "GatewayClass"}, gatewayv1.GroupVersion.Version)
+	switch {
+	case meta.IsNoMatchError(err):
+		setupLog.Info("Gateway API CRDs are not installed, skipping Gateway controllers")
+	case err != nil:
+		setupLog.Error(err, "unable to discover Gateway API")
+		os.Exit(1)
+	default:
+		if err = (&controller.GatewayClassReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GatewayClass")
+			os.Exit(1)
+		}
+		if err = (&controller.GatewayReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+			os.Exit(1)
+		}
+		if err = (&controller.HTTPRouteReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "HTTPRoute")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
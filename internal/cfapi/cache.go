@@ -0,0 +1,257 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// DefaultCacheTTL は Zone、Tunnel の一覧、DNS レコードを Cloudflare から取り直すまでの既定の時間です。
+const DefaultCacheTTL = 5 * time.Minute
+
+// WithCache は Zone の一覧、アカウントごとの Tunnel の一覧、Zone ごとの DNS レコードを ttl の間使い回す ClientFactory を返します。
+// キャッシュは認証情報ごとに Reconcile をまたいで共有し、このクライアントを通した書き込みで破棄します。
+// ttl が 0 以下の場合はキャッシュしません。
+func WithCache(factory ClientFactory, ttl time.Duration) ClientFactory {
+	if ttl <= 0 {
+		return factory
+	}
+	var (
+		mu     sync.Mutex
+		caches = map[Credentials]*apiCache{}
+	)
+	return func(creds Credentials) (API, error) {
+		api, err := factory(creds)
+		if err != nil {
+			return nil, err
+		}
+		// Zone と Tunnel の一覧はアカウント ID で分けるため、AccountID はキーに含めない
+		key := Credentials{APIToken: creds.APIToken, APIKey: creds.APIKey, Email: creds.Email}
+
+		mu.Lock()
+		defer mu.Unlock()
+		cache, ok := caches[key]
+		if !ok {
+			cache = &apiCache{
+				zones:   newTTLCache[[]cf.Zone](ttl),
+				tunnels: newTTLCache[[]cf.Tunnel](ttl),
+				records: newTTLCache[[]cf.DNSRecord](ttl),
+			}
+			caches[key] = cache
+		}
+		return &cachedAPI{API: api, cache: cache}, nil
+	}
+}
+
+// Refresh は、返したコンテキストでの Zone、Tunnel、DNS レコードの一覧の取得を、キャッシュのキーごとに最初の 1 回だけ Cloudflare から取り直すようにします。
+// 取り直した一覧はキャッシュに保存し、同じコンテキストでの以降の取得はそれを使います。
+// Cloudflare 側での変更を確かめるドリフトの検出や再同期で使います。
+func Refresh(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(refreshKey{}).(*refreshed); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, refreshKey{}, &refreshed{keys: map[string]bool{}})
+}
+
+// refreshKey は Refresh が記録する refreshed のコンテキストのキーです。
+type refreshKey struct{}
+
+// refreshed は Refresh のコンテキストで取り直したキャッシュのキーを記録します。
+type refreshed struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+// needsRefresh は ctx が Refresh のコンテキストで、kind の key をまだ取り直していなければ true を返し、取り直したものとして記録します。
+func needsRefresh(ctx context.Context, kind, key string) bool {
+	r, ok := ctx.Value(refreshKey{}).(*refreshed)
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys[kind+"/"+key] {
+		return false
+	}
+	r.keys[kind+"/"+key] = true
+	return true
+}
+
+// apiCache は同じ認証情報のクライアントが共有するキャッシュです。
+// zones と tunnels はアカウント ID、records は Zone ID をキーにします。
+type apiCache struct {
+	zones   *ttlCache[[]cf.Zone]
+	tunnels *ttlCache[[]cf.Tunnel]
+	records *ttlCache[[]cf.DNSRecord]
+}
+
+// cachedAPI は一覧の取得をキャッシュから返し、書き込みのたびに該当するキャッシュを破棄します。
+// キャッシュしない呼び出しはそのまま API に渡します。
+type cachedAPI struct {
+	API
+	cache *apiCache
+}
+
+var _ API = &cachedAPI{}
+
+func (a *cachedAPI) ListAccountZones(ctx context.Context, accountID string) ([]cf.Zone, error) {
+	zones, err := a.cache.zones.get(accountID, needsRefresh(ctx, "zones", accountID), func() ([]cf.Zone, error) {
+		return a.API.ListAccountZones(ctx, accountID)
+	})
+	return slices.Clone(zones), err
+}
+
+// ListTunnels は条件のない一覧だけをキャッシュします。
+func (a *cachedAPI) ListTunnels(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelListParams) ([]cf.Tunnel, *cf.ResultInfo, error) {
+	if !isZeroTunnelListParams(params) {
+		return a.API.ListTunnels(ctx, rc, params)
+	}
+	tunnels, err := a.cache.tunnels.get(rc.Identifier, needsRefresh(ctx, "tunnels", rc.Identifier), func() ([]cf.Tunnel, error) {
+		tunnels, _, err := a.API.ListTunnels(ctx, rc, params)
+		return tunnels, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return slices.Clone(tunnels), resultInfo(len(tunnels)), nil
+}
+
+func (a *cachedAPI) CreateTunnel(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelCreateParams) (cf.Tunnel, error) {
+	defer a.cache.tunnels.invalidate(rc.Identifier)
+	return a.API.CreateTunnel(ctx, rc, params)
+}
+
+func (a *cachedAPI) DeleteTunnel(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) error {
+	defer a.cache.tunnels.invalidate(rc.Identifier)
+	return a.API.DeleteTunnel(ctx, rc, tunnelID)
+}
+
+// ListDNSRecords は Zone のすべての DNS レコードを 1 度に取得してキャッシュし、Type、Name、Content で絞り込んで返します。
+// それ以外の条件がある場合はキャッシュを使いません。
+func (a *cachedAPI) ListDNSRecords(ctx context.Context, rc *cf.ResourceContainer, params cf.ListDNSRecordsParams) ([]cf.DNSRecord, *cf.ResultInfo, error) {
+	if !isCacheableDNSRecordsParams(params) {
+		return a.API.ListDNSRecords(ctx, rc, params)
+	}
+	snapshot, err := a.cache.records.get(rc.Identifier, needsRefresh(ctx, "records", rc.Identifier), func() ([]cf.DNSRecord, error) {
+		records, _, err := a.API.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{})
+		return records, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	records := []cf.DNSRecord{}
+	for _, rec := range snapshot {
+		if (params.Type == "" || rec.Type == params.Type) &&
+			(params.Name == "" || strings.EqualFold(rec.Name, params.Name)) &&
+			(params.Content == "" || rec.Content == params.Content) {
+			records = append(records, rec)
+		}
+	}
+	return records, resultInfo(len(records)), nil
+}
+
+func (a *cachedAPI) CreateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.CreateDNSRecordParams) (cf.DNSRecord, error) {
+	defer a.cache.records.invalidate(rc.Identifier)
+	return a.API.CreateDNSRecord(ctx, rc, params)
+}
+
+func (a *cachedAPI) UpdateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.UpdateDNSRecordParams) (cf.DNSRecord, error) {
+	defer a.cache.records.invalidate(rc.Identifier)
+	return a.API.UpdateDNSRecord(ctx, rc, params)
+}
+
+func (a *cachedAPI) DeleteDNSRecord(ctx context.Context, rc *cf.ResourceContainer, recordID string) error {
+	defer a.cache.records.invalidate(rc.Identifier)
+	return a.API.DeleteDNSRecord(ctx, rc, recordID)
+}
+
+// isZeroTunnelListParams は params が条件もページ指定もない一覧かを返します。
+func isZeroTunnelListParams(params cf.TunnelListParams) bool {
+	return params.Name == "" && params.UUID == "" && params.IsDeleted == nil && params.ExistedAt == nil &&
+		params.IncludePrefix == "" && params.ExcludePrefix == "" && params.ResultInfo == (cf.ResultInfo{})
+}
+
+// isCacheableDNSRecordsParams は params がキャッシュから絞り込める条件だけかを返します。
+func isCacheableDNSRecordsParams(params cf.ListDNSRecordsParams) bool {
+	return params.Proxied == nil && params.Comment == "" && len(params.Tags) == 0 && params.TagMatch == "" &&
+		params.Order == "" && params.Direction == "" && params.Match == "" && params.Priority == nil &&
+		params.ResultInfo == (cf.ResultInfo{})
+}
+
+// resultInfo はキャッシュから返した count 件の一覧を 1 ページとする ResultInfo です。
+func resultInfo(count int) *cf.ResultInfo {
+	return &cf.ResultInfo{Page: 1, PerPage: count, TotalPages: 1, Count: count, Total: count}
+}
+
+// ttlCache はキーごとに取得した値を ttl の間保持します。
+// 取得中に invalidate されたキーは、古い値を保存しないよう世代で見分けます。
+type ttlCache[V any] struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	entries     map[string]ttlEntry[V]
+	generations map[string]uint64
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:         ttl,
+		entries:     map[string]ttlEntry[V]{},
+		generations: map[string]uint64{},
+	}
+}
+
+// get は key の値を返します。期限切れか保持していない場合、または refresh の場合は fetch で取得して保存します。
+// fetch のエラーは保存しません。
+func (c *ttlCache[V]) get(key string, refresh bool, fetch func() (V, error)) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generations[key]
+	c.mu.Unlock()
+	if ok && !refresh && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	if c.generations[key] == generation {
+		c.entries[key] = ttlEntry[V]{value: value, expires: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// invalidate は key の値を破棄します。
+func (c *ttlCache[V]) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.generations[key]++
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi/fake"
+)
+
+const testAccountID = "test-account"
+
+// countingAPI は Cloudflare まで届いた一覧の取得を数えます。
+type countingAPI struct {
+	cfapi.API
+
+	mu    sync.Mutex
+	lists map[string]int
+	// duringList は次の一覧の取得で、Cloudflare から取得した後、キャッシュに保存する前に 1 度だけ呼び出されます。
+	duringList func()
+}
+
+func (a *countingAPI) count(kind string) {
+	a.mu.Lock()
+	a.lists[kind]++
+	hook := a.duringList
+	a.duringList = nil
+	a.mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+}
+
+func (a *countingAPI) ListAccountZones(ctx context.Context, accountID string) ([]cf.Zone, error) {
+	zones, err := a.API.ListAccountZones(ctx, accountID)
+	a.count("zones")
+	return zones, err
+}
+
+func (a *countingAPI) ListTunnels(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelListParams) ([]cf.Tunnel, *cf.ResultInfo, error) {
+	tunnels, info, err := a.API.ListTunnels(ctx, rc, params)
+	a.count("tunnels")
+	return tunnels, info, err
+}
+
+func (a *countingAPI) ListDNSRecords(ctx context.Context, rc *cf.ResourceContainer, params cf.ListDNSRecordsParams) ([]cf.DNSRecord, *cf.ResultInfo, error) {
+	records, info, err := a.API.ListDNSRecords(ctx, rc, params)
+	a.count("records")
+	return records, info, err
+}
+
+// cacheFixture はキャッシュ付きの api と、その裏の fake です。
+type cacheFixture struct {
+	api    cfapi.API
+	fake   *fake.API
+	zoneID string
+}
+
+// cacheStep はキャッシュ付きの api への 1 回の呼び出しです。
+type cacheStep func(ctx context.Context, fx cacheFixture)
+
+func listZones() cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, err := fx.api.ListAccountZones(ctx, testAccountID)
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+func listTunnels(params cf.TunnelListParams) cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, _, err := fx.api.ListTunnels(ctx, cf.AccountIdentifier(testAccountID), params)
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+func createTunnel(name string) cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, err := fx.api.CreateTunnel(ctx, cf.AccountIdentifier(testAccountID), cf.TunnelCreateParams{Name: name, Secret: "c2VjcmV0"})
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+func listRecords(params cf.ListDNSRecordsParams) cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, _, err := fx.api.ListDNSRecords(ctx, cf.ZoneIdentifier(fx.zoneID), params)
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+func createRecord(name string) cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, err := fx.api.CreateDNSRecord(ctx, cf.ZoneIdentifier(fx.zoneID), cf.CreateDNSRecordParams{Type: "CNAME", Name: name, Content: "tunnel.cfargotunnel.com"})
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+// firstRecordID は、キャッシュを通さずに Zone の最初の DNS レコードの ID を返します。
+func firstRecordID(fx cacheFixture) string {
+	records := fx.fake.DNSRecords(fx.zoneID)
+	Expect(records).NotTo(BeEmpty())
+	return records[0].ID
+}
+
+func updateRecord() cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		_, err := fx.api.UpdateDNSRecord(ctx, cf.ZoneIdentifier(fx.zoneID), cf.UpdateDNSRecordParams{ID: firstRecordID(fx), Content: "other.cfargotunnel.com"})
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+func deleteRecord() cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		Expect(fx.api.DeleteDNSRecord(ctx, cf.ZoneIdentifier(fx.zoneID), firstRecordID(fx))).To(Succeed())
+	}
+}
+
+func sleep(d time.Duration) cacheStep {
+	return func(context.Context, cacheFixture) {
+		time.Sleep(d)
+	}
+}
+
+// refreshed は steps を 1 つの cfapi.Refresh のコンテキストで呼び出します。
+func refreshed(steps ...cacheStep) cacheStep {
+	return func(ctx context.Context, fx cacheFixture) {
+		ctx = cfapi.Refresh(ctx)
+		for _, step := range steps {
+			step(ctx, fx)
+		}
+	}
+}
+
+var _ = Describe("API cache", func() {
+	var (
+		ctx      context.Context
+		fakeAPI  *fake.API
+		counting *countingAPI
+		zoneID   string
+	)
+
+	newCachedAPI := func(ttl time.Duration) cfapi.API {
+		factory := cfapi.WithCache(func(cfapi.Credentials) (cfapi.API, error) {
+			return counting, nil
+		}, ttl)
+		api, err := factory(cfapi.Credentials{APIToken: "token", AccountID: testAccountID})
+		Expect(err).NotTo(HaveOccurred())
+		return api
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fakeAPI = fake.NewAPI()
+		zoneID = fakeAPI.AddZone("widgetcorp.tech")
+		counting = &countingAPI{API: fakeAPI, lists: map[string]int{}}
+		_, err := fakeAPI.CreateDNSRecord(ctx, cf.ZoneIdentifier(zoneID), cf.CreateDNSRecordParams{Type: "CNAME", Name: "a.widgetcorp.tech", Content: "tunnel.cfargotunnel.com"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	DescribeTable("counting the listings that reach Cloudflare",
+		func(ttl time.Duration, steps []cacheStep, want map[string]int) {
+			fx := cacheFixture{api: newCachedAPI(ttl), fake: fakeAPI, zoneID: zoneID}
+			for _, step := range steps {
+				step(ctx, fx)
+			}
+			Expect(counting.lists).To(Equal(want))
+		},
+		Entry("serves repeated zone listings from the cache", time.Hour,
+			[]cacheStep{listZones(), listZones()}, map[string]int{"zones": 1}),
+		Entry("serves repeated tunnel listings from the cache", time.Hour,
+			[]cacheStep{listTunnels(cf.TunnelListParams{}), listTunnels(cf.TunnelListParams{})}, map[string]int{"tunnels": 1}),
+		Entry("does not cache tunnel listings with conditions", time.Hour,
+			[]cacheStep{listTunnels(cf.TunnelListParams{Name: "a"}), listTunnels(cf.TunnelListParams{Name: "a"})}, map[string]int{"tunnels": 2}),
+		Entry("lists the tunnels again after creating one", time.Hour,
+			[]cacheStep{listTunnels(cf.TunnelListParams{}), createTunnel("a"), listTunnels(cf.TunnelListParams{})}, map[string]int{"tunnels": 2}),
+		Entry("serves DNS records of any type and name from one listing", time.Hour,
+			[]cacheStep{
+				listRecords(cf.ListDNSRecordsParams{Type: "CNAME", Name: "a.widgetcorp.tech"}),
+				listRecords(cf.ListDNSRecordsParams{Type: "TXT"}),
+				listRecords(cf.ListDNSRecordsParams{}),
+			}, map[string]int{"records": 1}),
+		Entry("does not cache DNS record listings it cannot filter", time.Hour,
+			[]cacheStep{listRecords(cf.ListDNSRecordsParams{Proxied: ptr.To(true)}), listRecords(cf.ListDNSRecordsParams{Proxied: ptr.To(true)})}, map[string]int{"records": 2}),
+		Entry("lists the DNS records again after creating one", time.Hour,
+			[]cacheStep{listRecords(cf.ListDNSRecordsParams{}), createRecord("b.widgetcorp.tech"), listRecords(cf.ListDNSRecordsParams{})}, map[string]int{"records": 2}),
+		Entry("lists the DNS records again after updating one", time.Hour,
+			[]cacheStep{listRecords(cf.ListDNSRecordsParams{}), updateRecord(), listRecords(cf.ListDNSRecordsParams{})}, map[string]int{"records": 2}),
+		Entry("lists the DNS records again after deleting one", time.Hour,
+			[]cacheStep{listRecords(cf.ListDNSRecordsParams{}), deleteRecord(), listRecords(cf.ListDNSRecordsParams{})}, map[string]int{"records": 2}),
+		Entry("lists again once the cached listing expires", 10*time.Millisecond,
+			[]cacheStep{listZones(), listRecords(cf.ListDNSRecordsParams{}), sleep(20 * time.Millisecond), listZones(), listRecords(cf.ListDNSRecordsParams{})},
+			map[string]int{"zones": 2, "records": 2}),
+		Entry("lists once more in a Refresh context and reuses that listing", time.Hour,
+			[]cacheStep{
+				listRecords(cf.ListDNSRecordsParams{}),
+				refreshed(listRecords(cf.ListDNSRecordsParams{Type: "TXT"}), listRecords(cf.ListDNSRecordsParams{Type: "CNAME"})),
+				listRecords(cf.ListDNSRecordsParams{}),
+				listTunnels(cf.TunnelListParams{}),
+				refreshed(listTunnels(cf.TunnelListParams{})),
+			}, map[string]int{"records": 2, "tunnels": 2}),
+	)
+
+	It("should filter the cached DNS records like Cloudflare does", func() {
+		api := newCachedAPI(time.Hour)
+		_, err := fakeAPI.CreateDNSRecord(ctx, cf.ZoneIdentifier(zoneID), cf.CreateDNSRecordParams{Type: "TXT", Name: "_owner.a.widgetcorp.tech", Content: "owner"})
+		Expect(err).NotTo(HaveOccurred())
+
+		records, info, err := api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{Type: "CNAME", Name: "A.widgetcorp.tech"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Name).To(Equal("a.widgetcorp.tech"))
+		Expect(info.Count).To(Equal(1))
+
+		records, _, err = api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{Content: "owner"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].Type).To(Equal("TXT"))
+
+		By("modifying the returned records without touching the cache")
+		records[0].Content = "modified"
+		records, _, err = api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{Type: "TXT"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records[0].Content).To(Equal("owner"))
+		Expect(counting.lists).To(Equal(map[string]int{"records": 1}))
+	})
+
+	It("should not keep a listing fetched before a write through the cache", func() {
+		api := newCachedAPI(time.Hour)
+		By("creating a record while the first listing is in flight")
+		counting.duringList = func() {
+			createRecord("b.widgetcorp.tech")(ctx, cacheFixture{api: api, fake: fakeAPI, zoneID: zoneID})
+		}
+		records, _, err := api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+
+		records, _, err = api.ListDNSRecords(ctx, cf.ZoneIdentifier(zoneID), cf.ListDNSRecordsParams{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(2))
+		Expect(counting.lists).To(Equal(map[string]int{"records": 2}))
+	})
+})
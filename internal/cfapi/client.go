@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cfapi は、コントローラと Webhook が利用する Cloudflare API の最小限のインターフェースを提供します。
+package cfapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	cf "github.com/cloudflare/cloudflare-go"
+)
+
+// API は、Reconciler が実際に呼び出す Tunnel・DNS・Zone 関連の Cloudflare API です。
+// cloudflare-go に欠けている呼び出しは apiClient が補います。
+type API interface {
+	ListTunnels(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelListParams) ([]cf.Tunnel, *cf.ResultInfo, error)
+	GetTunnel(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.Tunnel, error)
+	CreateTunnel(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelCreateParams) (cf.Tunnel, error)
+	DeleteTunnel(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) error
+	GetTunnelToken(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (string, error)
+	RotateTunnelSecret(ctx context.Context, rc *cf.ResourceContainer, tunnelID, tunnelSecret string) error
+	GetTunnelConfiguration(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.TunnelConfigurationResult, error)
+	UpdateTunnelConfiguration(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelConfigurationParams) (cf.TunnelConfigurationResult, error)
+	CleanupTunnelConnections(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) error
+
+	ListAccountZones(ctx context.Context, accountID string) ([]cf.Zone, error)
+
+	ListDNSRecords(ctx context.Context, rc *cf.ResourceContainer, params cf.ListDNSRecordsParams) ([]cf.DNSRecord, *cf.ResultInfo, error)
+	CreateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.CreateDNSRecordParams) (cf.DNSRecord, error)
+	UpdateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.UpdateDNSRecordParams) (cf.DNSRecord, error)
+	DeleteDNSRecord(ctx context.Context, rc *cf.ResourceContainer, recordID string) error
+}
+
+var _ API = &apiClient{}
+
+// apiClient は *cloudflare.API に、cloudflare-go が正しく実装していない呼び出しを補ったものです。
+type apiClient struct {
+	*cf.API
+}
+
+// RotateTunnelSecret は Tunnel のシークレットを置き換えます。
+// cloudflare-go の UpdateTunnel は URL に Tunnel ID を含めないため、Raw で直接 PATCH します。
+func (c *apiClient) RotateTunnelSecret(ctx context.Context, rc *cf.ResourceContainer, tunnelID, tunnelSecret string) error {
+	if rc.Identifier == "" {
+		return cf.ErrMissingAccountID
+	}
+	uri := fmt.Sprintf("/accounts/%s/cfd_tunnel/%s", rc.Identifier, tunnelID)
+	_, err := c.Raw(ctx, http.MethodPatch, uri, cf.TunnelUpdateParams{Secret: tunnelSecret}, nil)
+	return err
+}
+
+// ListAccountZones は accountID のアカウントに属するゾーンだけを返します。
+// cloudflare-go の ListZones はトークンが参照できるすべてのアカウントのゾーンを返すため、アカウントで絞り込みます。
+func (c *apiClient) ListAccountZones(ctx context.Context, accountID string) ([]cf.Zone, error) {
+	res, err := c.ListZonesContext(ctx, cf.WithZoneFilters("", accountID, ""))
+	if err != nil {
+		return nil, err
+	}
+	return res.Result, nil
+}
+
+// Credentials は Cloudflare API クライアントの生成に必要な認証情報です。
+// APIToken が空の場合は APIKey と Email（Global API Key）で認証します。
+type Credentials struct {
+	APIToken  string
+	APIKey    string
+	Email     string
+	AccountID string
+}
+
+// ClientFactory は認証情報から API クライアントを生成します。
+// テストではインメモリの fake を返すファクトリに差し替えます。
+type ClientFactory func(creds Credentials) (API, error)
+
+// NewClient は cloudflare-go のクライアントを生成する既定の ClientFactory です。
+func NewClient(creds Credentials) (API, error) {
+	return newClient(creds)
+}
+
+// newClient は opts を適用した cloudflare-go のクライアントを生成します。
+func newClient(creds Credentials, opts ...cf.Option) (API, error) {
+	var (
+		api *cf.API
+		err error
+	)
+	if creds.APIToken != "" {
+		api, err = cf.NewWithAPIToken(creds.APIToken, opts...)
+	} else {
+		api, err = cf.New(creds.APIKey, creds.Email, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare API client: %w", err)
+	}
+	return &apiClient{API: api}, nil
+}
+
+// IsNotFound は err が Cloudflare API の 404 応答に由来するかを返します。
+func IsNotFound(err error) bool {
+	var notFound *cf.NotFoundError
+	return errors.As(err, &notFound)
+}
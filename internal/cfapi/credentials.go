@@ -0,0 +1,194 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+const (
+	// LegacySecretNamespace と LegacySecretName は、参照を指定しないリソースが使う従来の認証情報 Secret です。
+	LegacySecretNamespace = "default"
+	LegacySecretName      = "cloudflare-api-token"
+)
+
+// CredentialsError は認証情報を解決できなかった理由を表します。
+// Reason はそのまま CredentialsReady 条件の Reason に使います。
+type CredentialsError struct {
+	Reason string
+	Err    error
+}
+
+func (e *CredentialsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+func credentialsError(reason, format string, args ...any) error {
+	return &CredentialsError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// ResolveCredentials は namespace にあるリソースの CredentialsSource から Cloudflare API の認証情報を取得します。
+// accountRef、secretRef、従来の default/cloudflare-api-token の順に参照します。
+func ResolveCredentials(ctx context.Context, c client.Reader, namespace string, src cloudflarev1beta1.CredentialsSource) (Credentials, error) {
+	switch {
+	case src.AccountRef != nil:
+		return accountCredentials(ctx, c, namespace, src.AccountRef.Name)
+	case src.SecretRef != nil:
+		return secretCredentials(ctx, c, namespace, src.SecretRef.Name)
+	default:
+		return secretCredentials(ctx, c, LegacySecretNamespace, LegacySecretName)
+	}
+}
+
+// CloudflareCredentialsSource は Cloudflare リソースの認証情報の取得元と、それを解決する namespace を返します。
+// tunnelRef を指定して認証情報を省略したリソースは、Tunnel の認証情報を Tunnel の namespace で解決します。
+func CloudflareCredentialsSource(ctx context.Context, c client.Reader, cf *cloudflarev1beta1.Cloudflare) (string, cloudflarev1beta1.CredentialsSource, error) {
+	if !InheritsTunnelCredentials(cf) {
+		return cf.Namespace, cf.Spec.CredentialsSource, nil
+	}
+	tunnelKey := TunnelRefKey(*cf)
+	tunnel := &cloudflarev1beta1.Tunnel{}
+	if err := c.Get(ctx, tunnelKey, tunnel); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", cloudflarev1beta1.CredentialsSource{}, credentialsError("TunnelNotFound", "tunnel %s to take the credentials from not found", tunnelKey)
+		}
+		return "", cloudflarev1beta1.CredentialsSource{}, fmt.Errorf("failed to get tunnel %s: %w", tunnelKey, err)
+	}
+	if !TunnelAllowsNamespace(tunnel, cf.Namespace) {
+		return "", cloudflarev1beta1.CredentialsSource{}, credentialsError("TunnelNamespaceNotAllowed", "namespace %s is not allowed to use the credentials of Tunnel %s", cf.Namespace, tunnelKey)
+	}
+	return tunnel.Namespace, tunnel.Spec.CredentialsSource, nil
+}
+
+// InheritsTunnelCredentials は Cloudflare リソースが tunnelRef の Tunnel の認証情報を使うかを返します。
+func InheritsTunnelCredentials(cf *cloudflarev1beta1.Cloudflare) bool {
+	return cf.Spec.TunnelRef != nil && cf.Spec.SecretRef == nil && cf.Spec.AccountRef == nil
+}
+
+// TunnelRefKey は tunnelRef の参照先を返します。namespace を省略した場合は参照元の namespace です。
+func TunnelRefKey(cf cloudflarev1beta1.Cloudflare) types.NamespacedName {
+	key := types.NamespacedName{Namespace: cf.Namespace, Name: cf.Spec.TunnelRef.Name}
+	if cf.Spec.TunnelRef.Namespace != "" {
+		key.Namespace = cf.Spec.TunnelRef.Namespace
+	}
+	return key
+}
+
+// TunnelAllowsNamespace は namespace の Cloudflare リソースが tunnelRef で Tunnel を参照できるかを返します。
+// Tunnel と同じ namespace は常に参照でき、それ以外は Tunnel の allowedNamespaces に含まれる namespace だけです。
+func TunnelAllowsNamespace(tunnel *cloudflarev1beta1.Tunnel, namespace string) bool {
+	return namespace == tunnel.Namespace || slices.Contains(tunnel.Spec.AllowedNamespaces, namespace)
+}
+
+// secretCredentials は account_id と、apiToken または apiKey と email を持つ Secret から認証情報を取得します。
+func secretCredentials(ctx context.Context, c client.Reader, namespace, name string) (Credentials, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return Credentials{}, credentialsError("SecretNotFound", "secret %s/%s not found", namespace, name)
+		}
+		return Credentials{}, fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+
+	creds := Credentials{
+		APIToken:  secretValue(secret, "apiToken"),
+		APIKey:    secretValue(secret, "apiKey"),
+		Email:     secretValue(secret, "email"),
+		AccountID: secretValue(secret, "account_id"),
+	}
+	if creds.AccountID == "" {
+		return Credentials{}, credentialsError("InvalidSecret", "secret %s/%s does not contain key 'account_id'", namespace, name)
+	}
+	if creds.APIToken == "" && (creds.APIKey == "" || creds.Email == "") {
+		return Credentials{}, credentialsError("InvalidSecret", "secret %s/%s must contain 'apiToken', or 'apiKey' and 'email'", namespace, name)
+	}
+	return creds, nil
+}
+
+// accountCredentials は ClusterCloudflareAccount とその参照する Secret から認証情報を取得します。
+func accountCredentials(ctx context.Context, c client.Reader, namespace, name string) (Credentials, error) {
+	account := &cloudflarev1beta1.ClusterCloudflareAccount{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, account); err != nil {
+		if apierrors.IsNotFound(err) {
+			return Credentials{}, credentialsError("AccountNotFound", "ClusterCloudflareAccount %s not found", name)
+		}
+		return Credentials{}, fmt.Errorf("failed to get ClusterCloudflareAccount %s: %w", name, err)
+	}
+
+	allowed := account.Spec.AllowedNamespaces
+	if len(allowed) > 0 && !slices.Contains(allowed, namespace) {
+		return Credentials{}, credentialsError("NamespaceNotAllowed", "namespace %s is not allowed to use ClusterCloudflareAccount %s", namespace, name)
+	}
+
+	creds := Credentials{AccountID: account.Spec.AccountID}
+	switch {
+	case account.Spec.APITokenSecretRef != nil:
+		token, err := secretKeyValue(ctx, c, account.Spec.APITokenSecretRef, "apiToken")
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.APIToken = token
+	case account.Spec.APIKeySecretRef != nil:
+		key, err := secretKeyValue(ctx, c, account.Spec.APIKeySecretRef, "apiKey")
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.APIKey = key
+		creds.Email = account.Spec.Email
+	default:
+		return Credentials{}, credentialsError("InvalidAccount", "ClusterCloudflareAccount %s has neither apiTokenSecretRef nor apiKeySecretRef", name)
+	}
+	return creds, nil
+}
+
+// secretKeyValue は SecretKeyReference が指す値を返します。キーが省略されていれば defaultKey を使います。
+func secretKeyValue(ctx context.Context, c client.Reader, ref *cloudflarev1beta1.SecretKeyReference, defaultKey string) (string, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", credentialsError("SecretNotFound", "secret %s/%s not found", ref.Namespace, ref.Name)
+		}
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	value := secretValue(secret, key)
+	if value == "" {
+		return "", credentialsError("InvalidSecret", "secret %s/%s does not contain key '%s'", ref.Namespace, ref.Name, key)
+	}
+	return value, nil
+}
+
+func secretValue(secret *corev1.Secret, key string) string {
+	return strings.TrimSpace(string(secret.Data[key]))
+}
@@ -0,0 +1,571 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake は、Tunnel・Zone・DNS レコードをメモリ上で再現する cfapi.API の実装です。
+// envtest のスイートから実際の Cloudflare アカウントなしで作成・更新・削除の一連の流れを検証できます。
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+type tunnel struct {
+	accountID string
+	cf.Tunnel
+	// config は UpdateTunnelConfiguration で登録されたリモート設定で、version は更新の度に増えます。
+	config  cf.TunnelConfiguration
+	version int
+}
+
+// API はインメモリの Cloudflare API です。ゼロ値ではなく NewAPI で生成してください。
+type API struct {
+	mu sync.Mutex
+
+	nextID  int
+	tunnels map[string]*tunnel
+	// zones はゾーン名からゾーン ID への対応です。
+	zones map[string]string
+	// zoneAccounts はゾーン ID から、AddAccountZone で指定したアカウント ID への対応です。
+	zoneAccounts map[string]string
+	// records はゾーン ID ごとの DNS レコードです。
+	records map[string]map[string]cf.DNSRecord
+	// credentials は Factory に最後に渡された認証情報です。
+	credentials cfapi.Credentials
+	// denyTunnelTokens が true の間は GetTunnelToken が 403 を返します。
+	denyTunnelTokens bool
+	// failGetTunnel が true の間は GetTunnel が 503 を返します。
+	failGetTunnel bool
+	// fillConfigDefaults が true の間は、UpdateTunnelConfiguration で登録した設定に既定値を補って保存します。
+	fillConfigDefaults bool
+}
+
+var _ cfapi.API = &API{}
+
+// NewAPI は空のインメモリ API を生成します。
+func NewAPI() *API {
+	return &API{
+		tunnels:      map[string]*tunnel{},
+		zones:        map[string]string{},
+		zoneAccounts: map[string]string{},
+		records:      map[string]map[string]cf.DNSRecord{},
+	}
+}
+
+// Factory は、認証情報に関わらず常にこの fake を返す ClientFactory です。
+func (f *API) Factory() cfapi.ClientFactory {
+	return func(creds cfapi.Credentials) (cfapi.API, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.credentials = creds
+		return f, nil
+	}
+}
+
+// Credentials は Factory に最後に渡された認証情報を返します。
+func (f *API) Credentials() cfapi.Credentials {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.credentials
+}
+
+// AddZone はどのアカウントからも見えるゾーンを登録し、そのゾーン ID を返します。
+func (f *API) AddZone(name string) string {
+	return f.AddAccountZone("", name)
+}
+
+// AddAccountZone は accountID のアカウントに属するゾーンを登録し、そのゾーン ID を返します。
+func (f *API) AddAccountZone(accountID, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.zones[name]; ok {
+		return id
+	}
+	id := f.newID()
+	f.zones[name] = id
+	f.zoneAccounts[id] = accountID
+	f.records[id] = map[string]cf.DNSRecord{}
+	return id
+}
+
+// Tunnel は ID で Tunnel を返します。削除済みの Tunnel も DeletedAt 付きで返します。
+func (f *API) Tunnel(id string) (cf.Tunnel, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tunnels[id]
+	if !ok {
+		return cf.Tunnel{}, false
+	}
+	return t.Tunnel, true
+}
+
+// SetTunnelConnections は cloudflared が接続した状態を再現するため、Tunnel の接続を置き換えます。
+func (f *API) SetTunnelConnections(id string, conns []cf.TunnelConnection) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tunnels[id]
+	if !ok {
+		return
+	}
+	t.Connections = conns
+	if len(conns) > 0 {
+		t.Status = "healthy"
+	} else {
+		t.Status = "inactive"
+	}
+}
+
+// SetTunnelStatus は Cloudflare が報告する Tunnel の状態（healthy、degraded、down、inactive）を置き換えます。
+func (f *API) SetTunnelStatus(id, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, ok := f.tunnels[id]; ok {
+		t.Status = status
+	}
+}
+
+// TunnelConfiguration は Tunnel のリモート設定とそのバージョンを返します。
+func (f *API) TunnelConfiguration(id string) (cf.TunnelConfiguration, int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tunnels[id]
+	if !ok {
+		return cf.TunnelConfiguration{}, 0, false
+	}
+	return t.config, t.version, true
+}
+
+// DNSRecords はゾーン内の全レコードを名前順で返します。
+func (f *API) DNSRecords(zoneID string) []cf.DNSRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return sortedRecords(f.records[zoneID])
+}
+
+func (f *API) ListTunnels(_ context.Context, rc *cf.ResourceContainer, params cf.TunnelListParams) ([]cf.Tunnel, *cf.ResultInfo, error) {
+	if rc.Identifier == "" {
+		return []cf.Tunnel{}, &cf.ResultInfo{}, cf.ErrMissingAccountID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var tunnels []cf.Tunnel
+	for _, t := range f.tunnels {
+		if t.accountID != rc.Identifier {
+			continue
+		}
+		if params.Name != "" && t.Name != params.Name {
+			continue
+		}
+		if params.UUID != "" && t.ID != params.UUID {
+			continue
+		}
+		if params.IsDeleted != nil && *params.IsDeleted != (t.DeletedAt != nil) {
+			continue
+		}
+		listed := t.Tunnel
+		// 実際の API と同様に、一覧ではシークレットを返しません。
+		listed.Secret = ""
+		tunnels = append(tunnels, listed)
+	}
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].ID < tunnels[j].ID })
+	return tunnels, &cf.ResultInfo{Count: len(tunnels), Total: len(tunnels)}, nil
+}
+
+func (f *API) GetTunnel(_ context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.Tunnel, error) {
+	if rc.Identifier == "" {
+		return cf.Tunnel{}, cf.ErrMissingAccountID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failGetTunnel {
+		return cf.Tunnel{}, requestError(http.StatusServiceUnavailable, "service temporarily unavailable")
+	}
+	t, ok := f.tunnels[tunnelID]
+	if !ok || t.accountID != rc.Identifier {
+		return cf.Tunnel{}, notFoundError("tunnel not found")
+	}
+	got := t.Tunnel
+	got.Secret = ""
+	return got, nil
+}
+
+// FailGetTunnel は、Cloudflare の障害を模して GetTunnel を失敗させます。
+func (f *API) FailGetTunnel(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failGetTunnel = fail
+}
+
+// FillConfigDefaults は、登録された設定に既定値を補って返す Cloudflare を模して、
+// UpdateTunnelConfiguration の ingress ルールに cloudflared の既定の originRequest を書き込みます。
+func (f *API) FillConfigDefaults(fill bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fillConfigDefaults = fill
+}
+
+// DenyTunnelTokens は、トークンの読み取り権限がない API トークンを模して GetTunnelToken を失敗させます。
+func (f *API) DenyTunnelTokens(deny bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.denyTunnelTokens = deny
+}
+
+func (f *API) GetTunnelToken(_ context.Context, rc *cf.ResourceContainer, tunnelID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.denyTunnelTokens {
+		return "", requestError(http.StatusForbidden, "not authorized to read the tunnel token")
+	}
+
+	t, err := f.liveTunnel(rc, tunnelID)
+	if err != nil {
+		return "", err
+	}
+	return cfapi.EncodeTunnelToken(cfapi.TunnelToken{
+		AccountTag:   t.accountID,
+		TunnelID:     t.ID,
+		TunnelSecret: t.Secret,
+	})
+}
+
+func (f *API) RotateTunnelSecret(_ context.Context, rc *cf.ResourceContainer, tunnelID, tunnelSecret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.liveTunnel(rc, tunnelID)
+	if err != nil {
+		return err
+	}
+	t.Secret = tunnelSecret
+	return nil
+}
+
+func (f *API) GetTunnelConfiguration(_ context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.TunnelConfigurationResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.liveTunnel(rc, tunnelID)
+	if err != nil {
+		return cf.TunnelConfigurationResult{}, err
+	}
+	return cf.TunnelConfigurationResult{TunnelID: t.ID, Config: t.config, Version: t.version}, nil
+}
+
+func (f *API) UpdateTunnelConfiguration(_ context.Context, rc *cf.ResourceContainer, params cf.TunnelConfigurationParams) (cf.TunnelConfigurationResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.liveTunnel(rc, params.TunnelID)
+	if err != nil {
+		return cf.TunnelConfigurationResult{}, err
+	}
+	t.config = params.Config
+	if f.fillConfigDefaults {
+		t.config = withConfigDefaults(params.Config)
+	}
+	t.version++
+	t.RemoteConfig = true
+	return cf.TunnelConfigurationResult{TunnelID: t.ID, Config: t.config, Version: t.version}, nil
+}
+
+// withConfigDefaults は ingress ルールの省略された originRequest の設定を cloudflared の既定値で埋めた設定を返します。
+func withConfigDefaults(config cf.TunnelConfiguration) cf.TunnelConfiguration {
+	ingress := make([]cf.UnvalidatedIngressRule, 0, len(config.Ingress))
+	for _, rule := range config.Ingress {
+		o := cf.OriginRequestConfig{}
+		if rule.OriginRequest != nil {
+			o = *rule.OriginRequest
+		}
+		o.ConnectTimeout = orDefault(o.ConnectTimeout, cf.TunnelDuration{Duration: 30 * time.Second})
+		o.TLSTimeout = orDefault(o.TLSTimeout, cf.TunnelDuration{Duration: 10 * time.Second})
+		o.TCPKeepAlive = orDefault(o.TCPKeepAlive, cf.TunnelDuration{Duration: 30 * time.Second})
+		o.KeepAliveConnections = orDefault(o.KeepAliveConnections, 100)
+		o.KeepAliveTimeout = orDefault(o.KeepAliveTimeout, cf.TunnelDuration{Duration: 90 * time.Second})
+		o.NoTLSVerify = orDefault(o.NoTLSVerify, false)
+		o.Http2Origin = orDefault(o.Http2Origin, false)
+		rule.OriginRequest = &o
+		ingress = append(ingress, rule)
+	}
+	config.Ingress = ingress
+	return config
+}
+
+// orDefault は v が nil の場合に既定値へのポインタを返します。
+func orDefault[T any](v *T, def T) *T {
+	if v == nil {
+		return &def
+	}
+	return v
+}
+
+func (f *API) CreateTunnel(_ context.Context, rc *cf.ResourceContainer, params cf.TunnelCreateParams) (cf.Tunnel, error) {
+	if rc.Identifier == "" {
+		return cf.Tunnel{}, cf.ErrMissingAccountID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.tunnels {
+		if t.accountID == rc.Identifier && t.Name == params.Name && t.DeletedAt == nil {
+			return cf.Tunnel{}, requestError(http.StatusConflict, "tunnel with name already exists")
+		}
+	}
+	now := time.Now()
+	t := &tunnel{
+		accountID: rc.Identifier,
+		Tunnel: cf.Tunnel{
+			ID:           string(uuid.NewUUID()),
+			Name:         params.Name,
+			Secret:       params.Secret,
+			CreatedAt:    &now,
+			TunnelType:   "cfd_tunnel",
+			Status:       "inactive",
+			RemoteConfig: params.ConfigSrc == "cloudflare",
+		},
+	}
+	f.tunnels[t.ID] = t
+	return t.Tunnel, nil
+}
+
+func (f *API) DeleteTunnel(_ context.Context, rc *cf.ResourceContainer, tunnelID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.liveTunnel(rc, tunnelID)
+	if err != nil {
+		return err
+	}
+	if len(t.Connections) > 0 {
+		return requestError(http.StatusBadRequest, "cannot delete a tunnel with active connections")
+	}
+	now := time.Now()
+	t.DeletedAt = &now
+	return nil
+}
+
+func (f *API) CleanupTunnelConnections(_ context.Context, rc *cf.ResourceContainer, tunnelID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.liveTunnel(rc, tunnelID)
+	if err != nil {
+		return err
+	}
+	t.Connections = nil
+	return nil
+}
+
+func (f *API) ListAccountZones(_ context.Context, accountID string) ([]cf.Zone, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var zones []cf.Zone
+	for name, id := range f.zones {
+		if account := f.zoneAccounts[id]; account != "" && account != accountID {
+			continue
+		}
+		zones = append(zones, cf.Zone{ID: id, Name: name, Account: cf.Account{ID: f.zoneAccounts[id]}})
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+	return zones, nil
+}
+
+func (f *API) ListDNSRecords(_ context.Context, rc *cf.ResourceContainer, params cf.ListDNSRecordsParams) ([]cf.DNSRecord, *cf.ResultInfo, error) {
+	if rc.Identifier == "" {
+		return nil, nil, cf.ErrMissingZoneID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zone, ok := f.records[rc.Identifier]
+	if !ok {
+		return nil, nil, notFoundError("zone not found")
+	}
+	var records []cf.DNSRecord
+	for _, rec := range sortedRecords(zone) {
+		if params.Type != "" && rec.Type != params.Type {
+			continue
+		}
+		if params.Name != "" && rec.Name != params.Name {
+			continue
+		}
+		if params.Content != "" && rec.Content != params.Content {
+			continue
+		}
+		if params.Comment != "" && rec.Comment != params.Comment {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, &cf.ResultInfo{Count: len(records), Total: len(records)}, nil
+}
+
+func (f *API) CreateDNSRecord(_ context.Context, rc *cf.ResourceContainer, params cf.CreateDNSRecordParams) (cf.DNSRecord, error) {
+	if rc.Identifier == "" {
+		return cf.DNSRecord{}, cf.ErrMissingZoneID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	zone, ok := f.records[rc.Identifier]
+	if !ok {
+		return cf.DNSRecord{}, notFoundError("zone not found")
+	}
+	for _, rec := range zone {
+		if rec.Name == params.Name && (rec.Type == "CNAME" || params.Type == "CNAME") {
+			return cf.DNSRecord{}, requestError(http.StatusBadRequest, "a record with the same name already exists")
+		}
+	}
+	now := time.Now()
+	rec := cf.DNSRecord{
+		ID:         f.newID(),
+		Type:       params.Type,
+		Name:       params.Name,
+		Content:    params.Content,
+		TTL:        params.TTL,
+		Proxied:    params.Proxied,
+		Comment:    params.Comment,
+		Tags:       params.Tags,
+		CreatedOn:  now,
+		ModifiedOn: now,
+	}
+	if rec.TTL == 0 {
+		rec.TTL = 1
+	}
+	zone[rec.ID] = rec
+	return rec, nil
+}
+
+func (f *API) UpdateDNSRecord(_ context.Context, rc *cf.ResourceContainer, params cf.UpdateDNSRecordParams) (cf.DNSRecord, error) {
+	if rc.Identifier == "" {
+		return cf.DNSRecord{}, cf.ErrMissingZoneID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rec, ok := f.records[rc.Identifier][params.ID]
+	if !ok {
+		return cf.DNSRecord{}, notFoundError("record not found")
+	}
+	if params.Type != "" {
+		rec.Type = params.Type
+	}
+	if params.Name != "" {
+		rec.Name = params.Name
+	}
+	if params.Content != "" {
+		rec.Content = params.Content
+	}
+	if params.TTL != 0 {
+		rec.TTL = params.TTL
+	}
+	if params.Proxied != nil {
+		rec.Proxied = params.Proxied
+	}
+	if params.Comment != nil {
+		rec.Comment = *params.Comment
+	}
+	if params.Tags != nil {
+		rec.Tags = params.Tags
+	}
+	rec.ModifiedOn = time.Now()
+	f.records[rc.Identifier][rec.ID] = rec
+	return rec, nil
+}
+
+func (f *API) DeleteDNSRecord(_ context.Context, rc *cf.ResourceContainer, recordID string) error {
+	if rc.Identifier == "" {
+		return cf.ErrMissingZoneID
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.records[rc.Identifier][recordID]; !ok {
+		return notFoundError("record not found")
+	}
+	delete(f.records[rc.Identifier], recordID)
+	return nil
+}
+
+func (f *API) liveTunnel(rc *cf.ResourceContainer, tunnelID string) (*tunnel, error) {
+	if rc.Identifier == "" {
+		return nil, cf.ErrMissingAccountID
+	}
+	t, ok := f.tunnels[tunnelID]
+	if !ok || t.accountID != rc.Identifier || t.DeletedAt != nil {
+		return nil, notFoundError("tunnel not found")
+	}
+	return t, nil
+}
+
+func (f *API) newID() string {
+	f.nextID++
+	return fmt.Sprintf("%032x", f.nextID)
+}
+
+func sortedRecords(zone map[string]cf.DNSRecord) []cf.DNSRecord {
+	records := make([]cf.DNSRecord, 0, len(zone))
+	for _, rec := range zone {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// notFoundError と requestError は、実際のクライアントと同じくポインタ型のエラーを返します。
+func notFoundError(msg string) error {
+	err := cf.NewNotFoundError(&cf.Error{
+		Type:          cf.ErrorTypeNotFound,
+		StatusCode:    http.StatusNotFound,
+		Errors:        []cf.ResponseInfo{{Message: msg}},
+		ErrorMessages: []string{msg},
+	})
+	return &err
+}
+
+func requestError(status int, msg string) error {
+	err := cf.NewRequestError(&cf.Error{
+		Type:          cf.ErrorTypeRequest,
+		StatusCode:    status,
+		Errors:        []cf.ResponseInfo{{Message: msg}},
+		ErrorMessages: []string{msg},
+	})
+	return &err
+}
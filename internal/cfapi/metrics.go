@@ -0,0 +1,205 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// apiRequests は Cloudflare API の呼び出し回数です。code は HTTP のステータスコードで、
+	// 応答を受け取れなかった場合は "error" です。
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudflared_operator_cloudflare_api_requests_total",
+		Help: "Number of Cloudflare API calls by operation and HTTP status code.",
+	}, []string{"operation", "code"})
+
+	// apiRequestDuration は Cloudflare API の呼び出しにかかった時間です。
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudflared_operator_cloudflare_api_request_duration_seconds",
+		Help:    "Duration of Cloudflare API calls by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// apiRateLimited は Cloudflare API がレート制限（429）で拒否した呼び出しの回数です。
+	apiRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudflared_operator_cloudflare_api_rate_limited_total",
+		Help: "Number of Cloudflare API calls rejected by the rate limit, by operation.",
+	}, []string{"operation"})
+
+	// apiRetries は 429 と 5xx の応答を受けて Cloudflare API のリクエストを再試行した回数です。
+	apiRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudflared_operator_cloudflare_api_retries_total",
+		Help: "Number of Cloudflare API requests retried after a 429 or 5xx response, by HTTP status code.",
+	}, []string{"code"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequests, apiRequestDuration, apiRateLimited, apiRetries)
+}
+
+// WithMetrics は factory が生成する API クライアントの呼び出しを Prometheus のメトリクスに記録する ClientFactory を返します。
+func WithMetrics(factory ClientFactory) ClientFactory {
+	return func(creds Credentials) (API, error) {
+		api, err := factory(creds)
+		if err != nil {
+			return nil, err
+		}
+		return &instrumentedAPI{api: api}, nil
+	}
+}
+
+// instrumentedAPI は API の呼び出しごとに回数、所要時間、レート制限を記録します。
+type instrumentedAPI struct {
+	api API
+}
+
+var _ API = &instrumentedAPI{}
+
+// observe は operation の呼び出し結果を記録します。start は呼び出しを始めた時刻です。
+func observe(operation string, start time.Time, err error) {
+	apiRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	code := statusCode(err)
+	apiRequests.WithLabelValues(operation, code).Inc()
+	if code == strconv.Itoa(http.StatusTooManyRequests) {
+		apiRateLimited.WithLabelValues(operation).Inc()
+	}
+}
+
+// statusCode は呼び出し結果の HTTP ステータスコードを返します。
+// cloudflare-go は成功時のステータスコードを返さないため、成功は 200 として扱います。
+func statusCode(err error) string {
+	if err == nil {
+		return strconv.Itoa(http.StatusOK)
+	}
+	var cfErr *cf.Error
+	if errors.As(err, &cfErr) {
+		if cfErr.ClientRateLimited() {
+			return strconv.Itoa(http.StatusTooManyRequests)
+		}
+		if cfErr.StatusCode != 0 {
+			return strconv.Itoa(cfErr.StatusCode)
+		}
+	}
+	return "error"
+}
+
+func (a *instrumentedAPI) ListTunnels(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelListParams) ([]cf.Tunnel, *cf.ResultInfo, error) {
+	start := time.Now()
+	tunnels, info, err := a.api.ListTunnels(ctx, rc, params)
+	observe("ListTunnels", start, err)
+	return tunnels, info, err
+}
+
+func (a *instrumentedAPI) GetTunnel(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.Tunnel, error) {
+	start := time.Now()
+	tunnel, err := a.api.GetTunnel(ctx, rc, tunnelID)
+	observe("GetTunnel", start, err)
+	return tunnel, err
+}
+
+func (a *instrumentedAPI) CreateTunnel(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelCreateParams) (cf.Tunnel, error) {
+	start := time.Now()
+	tunnel, err := a.api.CreateTunnel(ctx, rc, params)
+	observe("CreateTunnel", start, err)
+	return tunnel, err
+}
+
+func (a *instrumentedAPI) DeleteTunnel(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) error {
+	start := time.Now()
+	err := a.api.DeleteTunnel(ctx, rc, tunnelID)
+	observe("DeleteTunnel", start, err)
+	return err
+}
+
+func (a *instrumentedAPI) GetTunnelToken(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (string, error) {
+	start := time.Now()
+	token, err := a.api.GetTunnelToken(ctx, rc, tunnelID)
+	observe("GetTunnelToken", start, err)
+	return token, err
+}
+
+func (a *instrumentedAPI) RotateTunnelSecret(ctx context.Context, rc *cf.ResourceContainer, tunnelID, tunnelSecret string) error {
+	start := time.Now()
+	err := a.api.RotateTunnelSecret(ctx, rc, tunnelID, tunnelSecret)
+	observe("RotateTunnelSecret", start, err)
+	return err
+}
+
+func (a *instrumentedAPI) GetTunnelConfiguration(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) (cf.TunnelConfigurationResult, error) {
+	start := time.Now()
+	result, err := a.api.GetTunnelConfiguration(ctx, rc, tunnelID)
+	observe("GetTunnelConfiguration", start, err)
+	return result, err
+}
+
+func (a *instrumentedAPI) UpdateTunnelConfiguration(ctx context.Context, rc *cf.ResourceContainer, params cf.TunnelConfigurationParams) (cf.TunnelConfigurationResult, error) {
+	start := time.Now()
+	result, err := a.api.UpdateTunnelConfiguration(ctx, rc, params)
+	observe("UpdateTunnelConfiguration", start, err)
+	return result, err
+}
+
+func (a *instrumentedAPI) CleanupTunnelConnections(ctx context.Context, rc *cf.ResourceContainer, tunnelID string) error {
+	start := time.Now()
+	err := a.api.CleanupTunnelConnections(ctx, rc, tunnelID)
+	observe("CleanupTunnelConnections", start, err)
+	return err
+}
+
+func (a *instrumentedAPI) ListAccountZones(ctx context.Context, accountID string) ([]cf.Zone, error) {
+	start := time.Now()
+	zones, err := a.api.ListAccountZones(ctx, accountID)
+	observe("ListAccountZones", start, err)
+	return zones, err
+}
+
+func (a *instrumentedAPI) ListDNSRecords(ctx context.Context, rc *cf.ResourceContainer, params cf.ListDNSRecordsParams) ([]cf.DNSRecord, *cf.ResultInfo, error) {
+	start := time.Now()
+	records, info, err := a.api.ListDNSRecords(ctx, rc, params)
+	observe("ListDNSRecords", start, err)
+	return records, info, err
+}
+
+func (a *instrumentedAPI) CreateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.CreateDNSRecordParams) (cf.DNSRecord, error) {
+	start := time.Now()
+	record, err := a.api.CreateDNSRecord(ctx, rc, params)
+	observe("CreateDNSRecord", start, err)
+	return record, err
+}
+
+func (a *instrumentedAPI) UpdateDNSRecord(ctx context.Context, rc *cf.ResourceContainer, params cf.UpdateDNSRecordParams) (cf.DNSRecord, error) {
+	start := time.Now()
+	record, err := a.api.UpdateDNSRecord(ctx, rc, params)
+	observe("UpdateDNSRecord", start, err)
+	return record, err
+}
+
+func (a *instrumentedAPI) DeleteDNSRecord(ctx context.Context, rc *cf.ResourceContainer, recordID string) error {
+	start := time.Now()
+	err := a.api.DeleteDNSRecord(ctx, rc, recordID)
+	observe("DeleteDNSRecord", start, err)
+	return err
+}
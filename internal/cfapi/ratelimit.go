@@ -0,0 +1,220 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/time/rate"
+)
+
+// Cloudflare API の既定のレート制限です。Cloudflare は API トークンごとに 5 分間で 1200 リクエストまで受け付けます。
+const (
+	DefaultRequestsPerSecond = 1200.0 / 300
+	DefaultBurst             = 20
+	DefaultMaxRetries        = 5
+	DefaultMinBackoff        = time.Second
+	DefaultMaxBackoff        = time.Minute
+)
+
+// RateLimit は Cloudflare API の呼び出しを認証情報ごとに制限し、失敗した呼び出しを再試行する設定です。
+type RateLimit struct {
+	// RequestsPerSecond はトークンバケットに補充される 1 秒あたりのリクエスト数です。
+	RequestsPerSecond float64
+	// Burst はトークンバケットの大きさで、続けて送れるリクエストの数です。
+	Burst int
+	// MaxRetries は 429 と、GET、PUT、DELETE への 5xx の応答を再試行する最大回数です。
+	MaxRetries int
+	// MinBackoff と MaxBackoff は Retry-After がない場合に再試行までに待つ時間の下限と上限です。
+	// Retry-After が MaxBackoff より長い場合も MaxBackoff だけ待って再試行します。
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRateLimit は Cloudflare の API トークンごとの制限に合わせた既定の RateLimit を返します。
+func DefaultRateLimit() RateLimit {
+	return RateLimit{
+		RequestsPerSecond: DefaultRequestsPerSecond,
+		Burst:             DefaultBurst,
+		MaxRetries:        DefaultMaxRetries,
+		MinBackoff:        DefaultMinBackoff,
+		MaxBackoff:        DefaultMaxBackoff,
+	}
+}
+
+// NewSharedClient は認証情報ごとに 1 つだけ API クライアントを生成し、Reconcile をまたいで使い回す ClientFactory を返します。
+// 同じ認証情報のクライアントは 1 つのトークンバケットを共有し、429 の応答と、冪等なリクエストへの 5xx の応答を再試行します。
+// 再試行とレート制限はこちらで行うため、cloudflare-go 自身の制限と再試行は無効にします。
+func NewSharedClient(limit RateLimit) ClientFactory {
+	var (
+		mu      sync.Mutex
+		clients = map[Credentials]API{}
+	)
+	return func(creds Credentials) (API, error) {
+		// AccountID はクライアントの生成に使わないため、キーに含めない
+		key := Credentials{APIToken: creds.APIToken, APIKey: creds.APIKey, Email: creds.Email}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if api, ok := clients[key]; ok {
+			return api, nil
+		}
+		api, err := newClient(creds,
+			cf.HTTPClient(&http.Client{Transport: newRetryTransport(http.DefaultTransport, limit)}),
+			cf.UsingRateLimit(math.Inf(1)),
+			cf.UsingRetryPolicy(0, 0, 0),
+		)
+		if err != nil {
+			return nil, err
+		}
+		clients[key] = api
+		return api, nil
+	}
+}
+
+// retryTransport はトークンバケットでリクエストを制限し、retryable な応答をジッター付きの指数バックオフで再試行します。
+// 応答に Retry-After がある場合はその時間だけ待ちます。
+type retryTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+	limit   RateLimit
+}
+
+func newRetryTransport(base http.RoundTripper, limit RateLimit) *retryTransport {
+	return &retryTransport{
+		base:    base,
+		limiter: rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), max(limit.Burst, 1)),
+		limit:   limit,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("failed to wait for the Cloudflare API rate limit: %w", err)
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || !retryable(req.Method, resp.StatusCode) {
+			return resp, err
+		}
+		apiRetries.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+		// 本文を読み直せないリクエストは再試行できない
+		if attempt >= t.limit.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return nil, retriesExhausted(resp, attempt)
+		}
+
+		delay := t.backoff(attempt, resp.Header.Get("Retry-After"))
+		discard(resp)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("aborted while backing off from HTTP %d: %w", resp.StatusCode, ctx.Err())
+		}
+	}
+}
+
+// retryable は method のリクエストへの statusCode の応答を再試行するかを返します。
+// 429 はリクエストが処理されていないため常に再試行します。5xx は処理されたかわからないため、
+// 繰り返しても結果の変わらない GET、PUT、DELETE だけを再試行します。
+func retryable(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if statusCode < http.StatusInternalServerError {
+		return false
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff は attempt 回目の再試行までに待つ時間を返します。
+// Retry-After があれば MaxBackoff を上限としてそれに従い、なければ MinBackoff から倍々に増やした時間の半分から全部までの間で無作為に選びます。
+func (t *retryTransport) backoff(attempt int, retryAfter string) time.Duration {
+	if d, ok := parseRetryAfter(retryAfter); ok {
+		return max(min(d, t.limit.MaxBackoff), 0)
+	}
+	d := t.limit.MinBackoff << attempt
+	if d <= 0 || d > t.limit.MaxBackoff {
+		d = t.limit.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter は Retry-After ヘッダの秒数か HTTP 日付を待ち時間に変換します。
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// retriesExhausted は再試行しきった応答を、ステータスコードを保った *cf.Error に変換します。
+// cloudflare-go は 429 と 5xx の応答をステータスコードのないエラーにするため、こちらで返します。
+func retriesExhausted(resp *http.Response, retries int) error {
+	discard(resp)
+	errorType := cf.ErrorTypeService
+	if resp.StatusCode == http.StatusTooManyRequests {
+		errorType = cf.ErrorTypeRateLimit
+	}
+	return &cf.Error{
+		Type:       errorType,
+		StatusCode: resp.StatusCode,
+		RayID:      resp.Header.Get("cf-ray"),
+		Errors: []cf.ResponseInfo{{
+			Message: fmt.Sprintf("received HTTP %d from Cloudflare API after %d retries", resp.StatusCode, retries),
+		}},
+	}
+}
+
+// discard は接続を使い回せるよう応答の本文を読み捨てて閉じます。
+func discard(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi_test
+
+import (
+	"context"
+	goerrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+// scriptedServer は statuses の順にステータスコードを返し、受け取ったリクエストの本文を記録する httptest サーバです。
+// statuses を返しきった後は 200 を返します。
+type scriptedServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	bodies     []string
+}
+
+func newScriptedServer(retryAfter string, statuses ...int) *scriptedServer {
+	s := &scriptedServer{statuses: statuses, retryAfter: retryAfter}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		status := http.StatusOK
+		if len(s.bodies) < len(s.statuses) {
+			status = s.statuses[len(s.bodies)]
+		}
+		s.bodies = append(s.bodies, string(body))
+		s.mu.Unlock()
+		if status >= http.StatusBadRequest && s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+// requests は受け取ったリクエストの本文を返します。
+func (s *scriptedServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+// testRateLimit は待ち時間を短くした RateLimit です。
+func testRateLimit() cfapi.RateLimit {
+	return cfapi.RateLimit{
+		RequestsPerSecond: 1000,
+		Burst:             10,
+		MaxRetries:        2,
+		MinBackoff:        time.Millisecond,
+		MaxBackoff:        10 * time.Millisecond,
+	}
+}
+
+var _ = Describe("Retry transport", func() {
+	send := func(ctx context.Context, server *scriptedServer, limit cfapi.RateLimit, method string) (*http.Response, error) {
+		var body io.Reader
+		if method != http.MethodGet && method != http.MethodDelete {
+			body = strings.NewReader(`{"name":"tunnel"}`)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, server.URL, body)
+		Expect(err).NotTo(HaveOccurred())
+		client := &http.Client{Transport: cfapi.NewRetryTransport(http.DefaultTransport, limit)}
+		resp, err := client.Do(req)
+		if err == nil {
+			DeferCleanup(resp.Body.Close)
+		}
+		return resp, err
+	}
+
+	DescribeTable("retrying the responses of Cloudflare",
+		func(method string, statuses []int, wantRequests, wantStatus int) {
+			server := newScriptedServer("", statuses...)
+			DeferCleanup(server.Close)
+
+			resp, err := send(context.Background(), server, testRateLimit(), method)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(wantStatus))
+			requests := server.requests()
+			Expect(requests).To(HaveLen(wantRequests))
+			for _, body := range requests {
+				Expect(body).To(Equal(requests[0]), "every attempt should send the same body")
+			}
+		},
+		Entry("does not retry a successful response", http.MethodGet, nil, 1, http.StatusOK),
+		Entry("does not retry a client error", http.MethodGet, []int{http.StatusNotFound}, 1, http.StatusNotFound),
+		Entry("retries a 5xx to GET", http.MethodGet, []int{http.StatusServiceUnavailable}, 2, http.StatusOK),
+		Entry("retries a 5xx to PUT with the same body", http.MethodPut, []int{http.StatusInternalServerError, http.StatusBadGateway}, 3, http.StatusOK),
+		Entry("retries a 5xx to DELETE", http.MethodDelete, []int{http.StatusBadGateway}, 2, http.StatusOK),
+		Entry("does not retry a 5xx to POST", http.MethodPost, []int{http.StatusServiceUnavailable}, 1, http.StatusServiceUnavailable),
+		Entry("does not retry a 5xx to PATCH", http.MethodPatch, []int{http.StatusInternalServerError}, 1, http.StatusInternalServerError),
+		Entry("retries a 429 to POST with the same body", http.MethodPost, []int{http.StatusTooManyRequests}, 2, http.StatusOK),
+	)
+
+	DescribeTable("giving up after MaxRetries",
+		func(method string, status int, wantType cf.ErrorType) {
+			statuses := []int{status, status, status, status}
+			server := newScriptedServer("", statuses...)
+			DeferCleanup(server.Close)
+
+			_, err := send(context.Background(), server, testRateLimit(), method)
+			var cfErr *cf.Error
+			Expect(goerrors.As(err, &cfErr)).To(BeTrue(), "expected *cloudflare.Error, got %v", err)
+			Expect(cfErr.StatusCode).To(Equal(status))
+			Expect(cfErr.Type).To(Equal(wantType))
+			Expect(server.requests()).To(HaveLen(testRateLimit().MaxRetries + 1))
+		},
+		Entry("returns the status of a 429", http.MethodPost, http.StatusTooManyRequests, cf.ErrorTypeRateLimit),
+		Entry("returns the status of a 5xx", http.MethodGet, http.StatusServiceUnavailable, cf.ErrorTypeService),
+	)
+
+	DescribeTable("waiting before a retry",
+		func(retryAfter string, minBackoff, maxBackoff, wantAtLeast, wantAtMost time.Duration) {
+			server := newScriptedServer(retryAfter, http.StatusTooManyRequests)
+			DeferCleanup(server.Close)
+			limit := testRateLimit()
+			limit.MinBackoff = minBackoff
+			limit.MaxBackoff = maxBackoff
+
+			start := time.Now()
+			resp, err := send(context.Background(), server, limit, http.MethodGet)
+			elapsed := time.Since(start)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(elapsed).To(BeNumerically(">=", wantAtLeast))
+			Expect(elapsed).To(BeNumerically("<", wantAtMost))
+		},
+		Entry("backs off for at least half of MinBackoff without Retry-After", "", 400*time.Millisecond, 400*time.Millisecond, 200*time.Millisecond, 5*time.Second),
+		Entry("follows Retry-After in seconds", "1", time.Millisecond, time.Minute, time.Second, 5*time.Second),
+		Entry("follows Retry-After of zero seconds instead of the backoff", "0", time.Hour, time.Hour, time.Duration(0), 5*time.Second),
+		Entry("follows Retry-After as an HTTP date in the past", "Mon, 02 Jan 2006 15:04:05 GMT", time.Hour, time.Hour, time.Duration(0), 5*time.Second),
+		Entry("ignores a malformed Retry-After", "soon", 400*time.Millisecond, 400*time.Millisecond, 200*time.Millisecond, 5*time.Second),
+		Entry("caps Retry-After in seconds at MaxBackoff", "3600", time.Millisecond, 200*time.Millisecond, 200*time.Millisecond, 5*time.Second),
+		Entry("caps Retry-After as an HTTP date at MaxBackoff", "Fri, 31 Dec 9999 23:59:59 GMT", time.Millisecond, 200*time.Millisecond, 200*time.Millisecond, 5*time.Second),
+	)
+
+	It("should stop backing off when the context is canceled", func() {
+		server := newScriptedServer("", http.StatusTooManyRequests)
+		DeferCleanup(server.Close)
+		limit := testRateLimit()
+		limit.MinBackoff = time.Hour
+		limit.MaxBackoff = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := send(ctx, server, limit, http.MethodGet)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(server.requests()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,56 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cfapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// TunnelToken は `cloudflared tunnel run --token` に渡すトンネルトークンの中身です。
+// トークンはこの構造体の JSON を base64 エンコードしたものです。
+type TunnelToken struct {
+	AccountTag   string `json:"a"`
+	TunnelID     string `json:"t"`
+	TunnelSecret string `json:"s"`
+}
+
+// EncodeTunnelToken はトンネルトークンを文字列にエンコードします。
+func EncodeTunnelToken(token TunnelToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecodeTunnelToken は GetTunnelToken が返したトークンをデコードします。
+func DecodeTunnelToken(token string) (TunnelToken, error) {
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return TunnelToken{}, fmt.Errorf("failed to decode tunnel token: %w", err)
+	}
+	var decoded TunnelToken
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return TunnelToken{}, fmt.Errorf("failed to decode tunnel token: %w", err)
+	}
+	if decoded.TunnelID == "" || decoded.TunnelSecret == "" {
+		return TunnelToken{}, fmt.Errorf("tunnel token is missing the tunnel ID or secret")
+	}
+	return decoded, nil
+}
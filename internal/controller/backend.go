@@ -0,0 +1,153 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// unavailableBackendStatus は参照先の Service が見つからないルールに返すステータスコードです。
+const unavailableBackendStatus = 503
+
+// resolveBackends は Cloudflare リソースの serviceRef を参照先の Service のポート番号に解決したコピーを返します。
+// 見つからない Service やポートを参照するルールは 503 を返すルールに置き換え、その参照を missing に列挙します。
+func resolveBackends(ctx context.Context, c client.Reader, cf *cloudflarev1beta1.Cloudflare) (*cloudflarev1beta1.Cloudflare, []string, error) {
+	resolved := cf.DeepCopy()
+	var missing []string
+	for i := range resolved.Spec.Ingress {
+		rule := &resolved.Spec.Ingress[i]
+		if rule.ServiceRef == nil {
+			continue
+		}
+		reason, err := resolveServiceRef(ctx, c, rule.ServiceRef, cf.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reason != "" {
+			missing = append(missing, reason)
+			rule.ServiceRef = nil
+			rule.Service = fmt.Sprintf("http_status:%d", unavailableBackendStatus)
+		}
+	}
+	fallback, reason, err := resolveFallback(ctx, c, resolved.Spec.Fallback, cf.Namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reason != "" {
+		missing = append(missing, reason)
+	}
+	resolved.Spec.Fallback = fallback
+	return resolved, missing, nil
+}
+
+// resolveFallback は fallback の serviceRef を解決します。参照先が見つからない場合は 503 を返す fallback に置き換えます。
+func resolveFallback(ctx context.Context, c client.Reader, fallback *cloudflarev1beta1.FallbackService, namespace string) (*cloudflarev1beta1.FallbackService, string, error) {
+	if fallback == nil || fallback.ServiceRef == nil {
+		return fallback, "", nil
+	}
+	fallback = fallback.DeepCopy()
+	reason, err := resolveServiceRef(ctx, c, fallback.ServiceRef, namespace)
+	if err != nil || reason == "" {
+		return fallback, "", err
+	}
+	return &cloudflarev1beta1.FallbackService{HTTPStatus: ptr.To(int32(unavailableBackendStatus))}, reason, nil
+}
+
+// resolveServiceRef は ref の namespace を補い、名前で指定したポートを番号に書き換えます。
+// Service やポートが見つからない場合は、その理由を返します。
+func resolveServiceRef(ctx context.Context, c client.Reader, ref *cloudflarev1beta1.ServiceReference, namespace string) (string, error) {
+	if ref.Namespace == "" {
+		ref.Namespace = namespace
+	}
+	key := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+
+	var svc corev1.Service
+	if err := c.Get(ctx, key, &svc); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("service %s not found", key), nil
+		}
+		return "", err
+	}
+	// ExternalName の Service はポートを持たないため、番号の指定だけを受け付ける
+	if svc.Spec.Type == corev1.ServiceTypeExternalName && ref.Port.Type == intstr.Int {
+		return "", nil
+	}
+	for _, port := range svc.Spec.Ports {
+		if (ref.Port.Type == intstr.String && port.Name == ref.Port.StrVal) ||
+			(ref.Port.Type == intstr.Int && port.Port == ref.Port.IntVal) {
+			ref.Port = intstr.FromInt32(port.Port)
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("port %s of service %s not found", ref.Port.String(), key), nil
+}
+
+// referencesService は Cloudflare リソースのルールか fallback が key の Service を参照しているかを返します。
+func referencesService(cf cloudflarev1beta1.Cloudflare, key types.NamespacedName) bool {
+	for _, rule := range cf.Spec.Ingress {
+		if serviceRefMatches(rule.ServiceRef, cf.Namespace, key) {
+			return true
+		}
+	}
+	return fallbackReferencesService(cf.Spec.Fallback, cf.Namespace, key)
+}
+
+// fallbackReferencesService は fallback が key の Service を参照しているかを返します。
+func fallbackReferencesService(fallback *cloudflarev1beta1.FallbackService, namespace string, key types.NamespacedName) bool {
+	return fallback != nil && serviceRefMatches(fallback.ServiceRef, namespace, key)
+}
+
+func serviceRefMatches(ref *cloudflarev1beta1.ServiceReference, namespace string, key types.NamespacedName) bool {
+	if ref == nil || ref.Name != key.Name {
+		return false
+	}
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	return namespace == key.Namespace
+}
+
+// backendCondition は serviceRef の解決結果を BackendNotFound 条件に変換します。
+func backendCondition(missing []string, generation int64) metav1.Condition {
+	if len(missing) == 0 {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeBackendNotFound,
+			Status:             metav1.ConditionFalse,
+			Reason:             "BackendsResolved",
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeBackendNotFound,
+		Status:             metav1.ConditionTrue,
+		Reason:             "BackendNotFound",
+		Message:            strings.Join(missing, "; "),
+		ObservedGeneration: generation,
+	}
+}
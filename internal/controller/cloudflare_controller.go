@@ -18,10 +18,9 @@ package controller
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	goerrors "errors"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -32,37 +31,88 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	appsv1apply "k8s.io/client-go/applyconfigurations/apps/v1"
 	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	metav1apply "k8s.io/client-go/applyconfigurations/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	"github.com/cloudflare/cloudflare-go"
 	cf "github.com/cloudflare/cloudflare-go"
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
-	"gopkg.in/yaml.v3"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
 )
 
 // CloudflareReconciler reconciles a Cloudflare object
 type CloudflareReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIFactory は Cloudflare API クライアントを生成します。nil の場合は cfapi.NewClient を使います。
+	APIFactory cfapi.ClientFactory
+
+	// ClusterID は DNS レコードの所有者 ID に含めるクラスタの識別子です。空の場合は DefaultClusterID を使います。
+	// 同じゾーンを複数のクラスタで管理する場合は、クラスタごとに異なる値を指定します。
+	ClusterID string
+
+	// TunnelHealthInterval は tunnel_name の Tunnel の接続状態を Cloudflare から取り直す間隔です。0 の場合は DefaultTunnelHealthInterval です。
+	TunnelHealthInterval time.Duration
+
+	// Recorder は Tunnel・DNS レコード・設定の変更と Cloudflare API の失敗を Event として記録します。nil の場合は記録しません。
+	Recorder record.EventRecorder
+
+	// ResyncPeriod は Tunnel と DNS レコードが外部から削除・変更されていないかを確かめるため、定期的に Reconcile する間隔です。
+	// 0 の場合は DefaultResyncPeriod です。
+	ResyncPeriod time.Duration
 }
 
 // IngressRule は単一のIngressルールを表します。
 type IngressRule struct {
-	// Hostname はホスト名です。必須フィールドです。
+	// Hostname はホスト名です。省略するか "*" の場合は全てのホスト名に一致します。
 	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
 
+	// Path はパスに一致させる正規表現です。
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
 	// Service はサービスのURLです。必須フィールドです。
 	Service string `json:"service" yaml:"service"`
+
+	// OriginRequest は origin への接続設定です。spec.originRequest とルールの設定をまとめたものです。
+	OriginRequest *OriginRequestConfig `json:"originRequest,omitempty" yaml:"originRequest,omitempty"`
+}
+
+// OriginRequestConfig は cloudflared の設定ファイルの originRequest を表します。時間は "30s" のような形式で書きます。
+type OriginRequestConfig struct {
+	ConnectTimeout         string        `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty"`
+	TLSTimeout             string        `json:"tlsTimeout,omitempty" yaml:"tlsTimeout,omitempty"`
+	TCPKeepAlive           string        `json:"tcpKeepAlive,omitempty" yaml:"tcpKeepAlive,omitempty"`
+	NoHappyEyeballs        *bool         `json:"noHappyEyeballs,omitempty" yaml:"noHappyEyeballs,omitempty"`
+	KeepAliveConnections   *int32        `json:"keepAliveConnections,omitempty" yaml:"keepAliveConnections,omitempty"`
+	KeepAliveTimeout       string        `json:"keepAliveTimeout,omitempty" yaml:"keepAliveTimeout,omitempty"`
+	HTTPHostHeader         string        `json:"httpHostHeader,omitempty" yaml:"httpHostHeader,omitempty"`
+	OriginServerName       string        `json:"originServerName,omitempty" yaml:"originServerName,omitempty"`
+	CAPool                 string        `json:"caPool,omitempty" yaml:"caPool,omitempty"`
+	NoTLSVerify            *bool         `json:"noTLSVerify,omitempty" yaml:"noTLSVerify,omitempty"`
+	HTTP2Origin            *bool         `json:"http2Origin,omitempty" yaml:"http2Origin,omitempty"`
+	DisableChunkedEncoding *bool         `json:"disableChunkedEncoding,omitempty" yaml:"disableChunkedEncoding,omitempty"`
+	ProxyType              string        `json:"proxyType,omitempty" yaml:"proxyType,omitempty"`
+	Access                 *AccessConfig `json:"access,omitempty" yaml:"access,omitempty"`
+}
+
+// AccessConfig は originRequest の access（Cloudflare Access の JWT 検証）を表します。
+type AccessConfig struct {
+	Required bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	TeamName string   `json:"teamName" yaml:"teamName"`
+	AudTag   []string `json:"audTag,omitempty" yaml:"audTag,omitempty"`
 }
 
 // CloudflareSpec はクラウドフレアの仕様を表します。
@@ -90,140 +140,617 @@ type CloudflareConfig struct {
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;update;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Cloudflare object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.0/pkg/reconcile
-
+// Reconcile は Cloudflare リソースの Tunnel、cloudflared の設定と Deployment、DNS レコードを作成・更新し、結果を status に書き込みます。
+// 削除中のリソースは、Cloudflare 側の DNS レコードと Tunnel を削除してから Finalizer を外します。
 func (r *CloudflareReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	var cf cloudflarev1beta1.Cloudflare
 	err := r.Get(ctx, req.NamespacedName, &cf)
 	if errors.IsNotFound(err) {
+		deleteCloudflareMetrics(req.Namespace, req.Name)
 		return ctrl.Result{}, nil
 	}
-	// var tunnel cloudflarev1beta1.Tunnel
-
-	// err := r.Get(ctx, req.NamespacedName, &tunnel)
-	// test := "test2"
-	logger.Info("aaa3", "tunnel", req.NamespacedName)
-
-	err = r.reconcileTunnel(ctx, cf)
 	if err != nil {
-		result, err2 := r.updateStatus(ctx, cf)
-		logger.Error(err2, "unable to update status")
-		return result, err
+		logger.Error(err, "unable to get Cloudflare", "name", req.NamespacedName)
+		return ctrl.Result{}, err
 	}
 
-	if err != nil {
-		logger.Error(err, "unable to get Cloudflare", "name", req.NamespacedName)
+	// 削除は認証情報より先に扱い、Secret が先に削除されていても Finalizer を外せるようにする
+	if !cf.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeCloudflare(ctx, &cf)
+	}
+
+	// 途中でエラーになった場合も含めて最後に Ready 条件を更新する
+	defer r.reconcileReady(ctx, &cf)
+
+	// Tunnel から認証情報を引き継げない理由は reconcileWithTunnelRef が TunnelReady 条件で報告する
+	if err := r.reconcileCredentials(ctx, &cf); err != nil && !isTunnelCredentialsError(err) {
+		logger.Error(err, "unable to resolve Cloudflare credentials")
 		return ctrl.Result{}, err
 	}
 
-	finalizerName := "finalizer.cloudflare.laininthewired.github.io"
-	if !controllerutil.ContainsFinalizer(&cf, finalizerName) {
-		controllerutil.AddFinalizer(&cf, finalizerName)
+	// 共有 Tunnel を参照する場合は Tunnel の作成・削除を Tunnel コントローラに任せる
+	if cf.Spec.TunnelRef != nil {
+		return r.reconcileWithTunnelRef(ctx, &cf)
+	}
+
+	// Tunnel を作成する前に Finalizer を付け、作成直後に削除されても Tunnel を残さないようにする
+	if !controllerutil.ContainsFinalizer(&cf, cloudflareFinalizerName) {
+		controllerutil.AddFinalizer(&cf, cloudflareFinalizerName)
 		err = r.Update(ctx, &cf)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
-	if !cf.ObjectMeta.DeletionTimestamp.IsZero() {
-		fmt.Println("start delete")
-		if err := r.deleteDNSRecord(ctx, cf); err != nil {
+
+	drift := r.newDriftReport(&cf)
+	err = r.reconcileTunnel(ctx, &cf, drift)
+	if goerrors.Is(err, errTunnelDrifted) {
+		logger.Info("tunnel was deleted or replaced outside the operator", "tunnelID", cf.Status.TunnelID)
+		return ctrl.Result{RequeueAfter: resyncPeriod(r.ResyncPeriod)}, r.setCondition(ctx, &cf, drift.condition(cf.Generation))
+	}
+	if err != nil {
+		logger.Error(err, "unable to reconcile tunnel")
+		return ctrl.Result{}, err
+	}
+
+	tunnelID := cf.Status.TunnelID
+
+	resolved, err := r.reconcileBackends(ctx, &cf)
+	if err != nil {
+		logger.Error(err, "unable to resolve backend Services")
+		return ctrl.Result{}, err
+	}
+
+	conn := inlineConnector(resolved, tunnelID)
+	err = r.reconcileConnector(ctx, conn)
+	if updateErr := r.setCondition(ctx, &cf, configCondition(err, cf.Generation)); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	if err != nil {
+		logger.Error(err, "unable to reconcile cloudflared")
+		r.eventsFor(&cf).warning(eventConfigError, "Failed to apply the cloudflared configuration: %v", err)
+		return ctrl.Result{}, err
+	}
+
+	// DNS レコードの作成／更新
+	hostnames, err := r.reconcileDNSRecord(ctx, cf, tunnelID, managedHostnames(cf), drift)
+	if err != nil {
+		logger.Error(err, "failed to reconcile DNS records")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileDNSEndpoint(ctx, &cf, tunnelID); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Tunnel の接続状態を取り直し、Tunnel と DNS レコードが外部から変更されていないか確かめるため、一定間隔で再度 Reconcile する
+	requeueAfter := min(tunnelHealthInterval(r.TunnelHealthInterval), resyncPeriod(r.ResyncPeriod))
+	return ctrl.Result{RequeueAfter: requeueAfter}, r.reconcileStatus(ctx, &cf, conn, hostnames, drift)
+}
+
+// finalizeCloudflare は削除中の Cloudflare リソースの DNS レコードと Tunnel を削除し、Finalizer を外します。
+// 認証情報の Secret などが先に削除されて Cloudflare API を使えない場合は、Cloudflare 側の削除を諦めて Event に記録します。
+// 共有 Tunnel の設定からこのリソースのルールを外す処理は、認証情報に関わらず行います。
+func (r *CloudflareReconciler) finalizeCloudflare(ctx context.Context, cf *cloudflarev1beta1.Cloudflare) error {
+	logger := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(cf, cloudflareFinalizerName) {
+		return nil
+	}
+
+	_, _, err := r.cloudflareAPI(ctx, cf)
+	cleanup := err == nil
+	var credsErr *cfapi.CredentialsError
+	if goerrors.As(err, &credsErr) {
+		logger.Info("leaving resources in Cloudflare because the credentials are unavailable", "reason", credsErr.Reason)
+		leftover := "tunnel and DNS records"
+		if cf.Spec.TunnelRef != nil {
+			leftover = "DNS records"
+		}
+		r.eventsFor(cf).warning(eventCleanupSkipped, "Left the %s in Cloudflare because the credentials are unavailable: %v", leftover, err)
+	} else if err != nil {
+		return err
+	}
+
+	if cf.Spec.TunnelRef != nil {
+		if err := r.releaseTunnel(ctx, cf, cfapi.TunnelRefKey(*cf), cleanup); err != nil {
+			logger.Error(err, "failed to release shared tunnel during finalization", "tunnel", cfapi.TunnelRefKey(*cf))
+			return err
+		}
+	} else if cleanup {
+		if err := r.deleteDNSRecord(ctx, *cf, nil); err != nil {
 			logger.Error(err, "failed to delete DNS records during finalization")
-			return ctrl.Result{}, err
+			return err
 		}
-		if err := r.deleteTunnel(ctx, cf); err != nil {
+		if err := r.deleteTunnel(ctx, *cf); err != nil {
 			logger.Error(err, "failed to delete tunnel during finalization")
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(cf, cloudflareFinalizerName)
+	return r.Update(ctx, cf)
+}
+
+// reconcileCredentials は参照先から認証情報を取得できるかを確認し、結果を CredentialsReady 条件に記録します。
+func (r *CloudflareReconciler) reconcileCredentials(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare) error {
+	_, _, err := r.cloudflareAPI(ctx, cloudflare)
+	if meta.SetStatusCondition(&cloudflare.Status.Conditions, credentialsCondition(err, cloudflare.Generation)) {
+		if updateErr := r.Status().Update(ctx, cloudflare); updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// reconcileBackends は serviceRef を解決し、見つからない Service やポートを BackendNotFound 条件に記録します。
+// 戻り値は serviceRef を解決した Cloudflare リソースのコピーで、connector の描画に使います。
+func (r *CloudflareReconciler) reconcileBackends(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare) (*cloudflarev1beta1.Cloudflare, error) {
+	resolved, missing, err := resolveBackends(ctx, r.Client, cloudflare)
+	if err != nil {
+		return nil, err
+	}
+	if meta.SetStatusCondition(&cloudflare.Status.Conditions, backendCondition(missing, cloudflare.Generation)) {
+		if err := r.Status().Update(ctx, cloudflare); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// reconcileWithTunnelRef は tunnelRef で共有 Tunnel を参照する Cloudflare リソースを調整します。
+// 同じ Tunnel を参照する全リソースのルールをまとめた設定と Deployment は Tunnel リソースが所有します。
+func (r *CloudflareReconciler) reconcileWithTunnelRef(ctx context.Context, cf *cloudflarev1beta1.Cloudflare) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	tunnelKey := cfapi.TunnelRefKey(*cf)
+
+	if !controllerutil.ContainsFinalizer(cf, cloudflareFinalizerName) {
+		controllerutil.AddFinalizer(cf, cloudflareFinalizerName)
+		if err := r.Update(ctx, cf); err != nil {
 			return ctrl.Result{}, err
 		}
-		controllerutil.RemoveFinalizer(&cf, finalizerName)
-		err = r.Update(ctx, &cf)
+	}
+
+	// tunnelRef が付け替えられた場合は、以前の Tunnel の設定からこのリソースのルールを外す
+	if previous, ok := cf.Annotations[tunnelRefAnnotation]; ok && previous != tunnelKey.String() {
+		if err := r.releaseTunnel(ctx, cf, parseTunnelRefAnnotation(previous), true); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var tunnel cloudflarev1beta1.Tunnel
+	err := r.Get(ctx, tunnelKey, &tunnel)
+	if err == nil && !cfapi.TunnelAllowsNamespace(&tunnel, cf.Namespace) {
+		logger.Info("the referenced Tunnel does not allow this namespace", "tunnel", tunnelKey)
+		return ctrl.Result{}, r.rejectTunnelRef(ctx, cf, tunnelKey)
+	}
+	if errors.IsNotFound(err) || (err == nil && tunnel.Status.TunnelID == "") {
+		logger.Info("waiting for the referenced Tunnel to become ready", "tunnel", tunnelKey)
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, r.setCondition(ctx, cf, metav1.Condition{
+			Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "TunnelNotReady",
+			Message:            fmt.Sprintf("Tunnel %s is not ready", tunnelKey),
+			ObservedGeneration: cf.Generation,
+		})
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	tunnelID := tunnel.Status.TunnelID
+	// status の Tunnel ID を更新する前に、外部からの変更を確かめる基準を記録する
+	drift := r.newDriftReport(cf)
+
+	if cf.Annotations[tunnelRefAnnotation] == tunnelKey.String() {
+		message, pending, err := r.tunnelRefDrift(ctx, cf, &tunnel)
 		if err != nil {
+			logger.Error(err, "unable to check the tunnel of the referenced Tunnel", "tunnel", tunnelKey)
+			return ctrl.Result{}, err
+		}
+		switch {
+		case message != "" && drift.reportOnly():
+			logger.Info("tunnel was deleted or replaced outside the operator", "tunnel", tunnelKey, "tunnelID", cf.Status.TunnelID)
+			drift.detect(message)
+			if err := r.setCondition(ctx, cf, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "TunnelDrifted",
+				Message:            message,
+				ObservedGeneration: cf.Generation,
+			}); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: resyncPeriod(r.ResyncPeriod)}, r.setCondition(ctx, cf, drift.condition(cf.Generation))
+		case pending:
+			// 作り直した Tunnel を Tunnel コントローラが status に記録するまで DNS レコードを向け直さない
+			logger.Info("waiting for the referenced Tunnel to recreate its tunnel", "tunnel", tunnelKey)
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, r.setCondition(ctx, cf, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "TunnelNotReady",
+				Message:            fmt.Sprintf("%s; waiting for Tunnel %s to recreate it", message, tunnelKey),
+				ObservedGeneration: cf.Generation,
+			})
+		case message != "":
+			drift.correct(message)
+		}
+	}
+
+	if cf.Annotations[tunnelRefAnnotation] != tunnelKey.String() {
+		if cf.Annotations == nil {
+			cf.Annotations = map[string]string{}
+		}
+		cf.Annotations[tunnelRefAnnotation] = tunnelKey.String()
+		if err := r.Update(ctx, cf); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	ready := tunnelReadyCondition(tunnelID, nil, cf.Generation)
+	inherited := inheritedCredentials(cf, &tunnel)
+	if cf.Status.TunnelID != tunnelID || cf.Status.TunnelName != cloudflareTunnelName(tunnel) ||
+		!equality.Semantic.DeepEqual(cf.Status.TunnelCredentials, inherited) ||
+		meta.SetStatusCondition(&cf.Status.Conditions, ready) {
+		cf.Status.TunnelID = tunnelID
+		cf.Status.TunnelName = cloudflareTunnelName(tunnel)
+		cf.Status.TunnelCredentials = inherited
+		meta.SetStatusCondition(&cf.Status.Conditions, ready)
+		if err := r.Status().Update(ctx, cf); err != nil {
 			return ctrl.Result{}, err
 		}
-		// Finalizer の解除処理をここで実施（必要に応じて）
-		return ctrl.Result{}, nil
 	}
 
-	err = r.reconcileConfigMap(ctx, cf)
+	if _, err := r.reconcileBackends(ctx, cf); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	bound, err := r.boundCloudflares(ctx, tunnelKey)
 	if err != nil {
-		result, err2 := r.updateStatus(ctx, cf)
-		logger.Error(err2, "unable to update status")
-		return result, err
+		return ctrl.Result{}, err
+	}
+	admitted, rejected := claimHostnames(&tunnel, bound)
+	err = r.reconcileTunnelConnector(ctx, &tunnel, bound)
+	config := sharedConfigCondition(err, rejected[client.ObjectKeyFromObject(cf)], cf.Generation)
+	if previous := meta.FindStatusCondition(cf.Status.Conditions, cloudflarev1beta1.TypeConfigReady); (config.Reason == "HostnameConflict" || config.Reason == "HostnameRequired") &&
+		(previous == nil || previous.Reason != config.Reason || previous.Message != config.Message) {
+		reason := eventHostnameConflict
+		if config.Reason == "HostnameRequired" {
+			reason = eventHostnameRequired
+		}
+		r.eventsFor(cf).warning(reason, "Left some rules out of Tunnel %s: %s", tunnelKey, config.Message)
+	}
+	if updateErr := r.setCondition(ctx, cf, config); updateErr != nil {
+		return ctrl.Result{}, updateErr
 	}
-	err = r.reconcileDeployment(ctx, cf)
 	if err != nil {
-		result, err2 := r.updateStatus(ctx, cf)
-		logger.Error(err2, "unable to update status")
-		return result, err
+		logger.Error(err, "unable to reconcile cloudflared", "tunnel", tunnelKey)
+		r.eventsFor(cf).warning(eventConfigError, "Failed to apply the cloudflared configuration of Tunnel %s: %v", tunnelKey, err)
+		return ctrl.Result{}, err
 	}
 
-	// DNS レコードの作成／更新
-	if err := r.reconcileDNSRecord(ctx, cf); err != nil {
+	// 他のリソースが先に使っているホスト名の DNS レコードは作成しない
+	claimed := *cf
+	hostnames := map[string]bool{}
+	for _, item := range admitted {
+		for hostname := range managedHostnames(item) {
+			hostnames[hostname] = true
+		}
+		if item.Namespace == cf.Namespace && item.Name == cf.Name {
+			claimed.Spec.Ingress = item.Spec.Ingress
+		}
+	}
+	statuses, err := r.reconcileDNSRecord(ctx, claimed, tunnelID, hostnames, drift)
+	if err != nil {
 		logger.Error(err, "failed to reconcile DNS records")
 		return ctrl.Result{}, err
 	}
+	if err := r.reconcileDNSEndpoint(ctx, cf, tunnelID); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// DNS レコードが外部から変更されていないか確かめるため、一定間隔で再度 Reconcile する
+	return ctrl.Result{RequeueAfter: resyncPeriod(r.ResyncPeriod)}, r.reconcileStatus(ctx, cf, sharedConnector(&tunnel, bound), statuses, drift)
+}
+
+// tunnelRefDrift は、status の Tunnel が外部から削除・置き換えられていれば、その内容を返します。
+// Tunnel コントローラが status をまだ新しい Tunnel に更新していない場合は pending を true にします。
+// Cloudflare 上の Tunnel は、キャッシュを使わずに Tunnel リソースの認証情報で確かめます。
+func (r *CloudflareReconciler) tunnelRefDrift(ctx context.Context, cf *cloudflarev1beta1.Cloudflare, tunnel *cloudflarev1beta1.Tunnel) (string, bool, error) {
+	name := cloudflareTunnelName(*tunnel)
+	// Tunnel リソースの tunnelName を変えた場合は別の Tunnel を使うため、外部からの変更として扱わない
+	if cf.Status.TunnelID == "" || cf.Status.TunnelName != name {
+		return "", false, nil
+	}
+	if tunnel.Status.TunnelID != cf.Status.TunnelID {
+		return fmt.Sprintf("tunnel %s was replaced outside the operator by %s (previously %s)", name, tunnel.Status.TunnelID, cf.Status.TunnelID), false, nil
+	}
+
+	api, accountID, err := newCloudflareAPI(ctx, r.Client, r.APIFactory, tunnel.Namespace, tunnel.Spec.CredentialsSource)
+	if err != nil {
+		return "", false, err
+	}
+	tunnelID, err := findTunnel(cfapi.Refresh(ctx), api, accountID, name)
+	switch {
+	case err != nil:
+		return "", false, err
+	case tunnelID == "":
+		return fmt.Sprintf("tunnel %s (%s) was deleted outside the operator", name, cf.Status.TunnelID), true, nil
+	case tunnelID != cf.Status.TunnelID:
+		return fmt.Sprintf("tunnel %s was replaced outside the operator by %s (previously %s)", name, tunnelID, cf.Status.TunnelID), true, nil
+	}
+	return "", false, nil
+}
+
+// rejectTunnelRef は、参照先の Tunnel がこのリソースの namespace を許可していない場合に TunnelReady を NamespaceNotAllowed にします。
+// 許可されていた間に Tunnel に紐付いていた場合は、Tunnel からこのリソースを外して DNS レコードを削除します。
+func (r *CloudflareReconciler) rejectTunnelRef(ctx context.Context, cf *cloudflarev1beta1.Cloudflare, tunnelKey types.NamespacedName) error {
+	released := false
+	if cf.Annotations[tunnelRefAnnotation] == tunnelKey.String() {
+		if err := r.releaseTunnel(ctx, cf, tunnelKey, true); err != nil {
+			return err
+		}
+		delete(cf.Annotations, tunnelRefAnnotation)
+		if err := r.Update(ctx, cf); err != nil {
+			return err
+		}
+		released = true
+	}
+
+	message := fmt.Sprintf("namespace %s is not allowed to use Tunnel %s; add it to spec.allowedNamespaces of the Tunnel", cf.Namespace, tunnelKey)
+	if previous := meta.FindStatusCondition(cf.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady); previous == nil || previous.Reason != "NamespaceNotAllowed" {
+		r.eventsFor(cf).warning(eventTunnelRefRejected, "%s", message)
+	}
+	// ルールはどの Tunnel の設定にも載らないため、ConfigReady も False にする
+	changed := false
+	for _, conditionType := range []string{cloudflarev1beta1.TypeCloudflareTunnelReady, cloudflarev1beta1.TypeConfigReady} {
+		changed = meta.SetStatusCondition(&cf.Status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NamespaceNotAllowed",
+			Message:            message,
+			ObservedGeneration: cf.Generation,
+		}) || changed
+	}
+	if released {
+		// 外した Tunnel と削除した DNS レコードを status に残さない
+		cf.Status.TunnelID = ""
+		cf.Status.TunnelName = ""
+		cf.Status.TunnelCredentials = nil
+		cf.Status.Hostnames = nil
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, cf)
+}
+
+// releaseTunnel は共有 Tunnel からこのリソースを外します。
+// このリソースが所有する DNS レコードは、Tunnel リソースが既に削除されていてもこのリソースの認証情報で削除し、
+// 同じ Tunnel を共有する他のリソースが使うホスト名は所有だけを外して CNAME を残します。
+// 設定と Deployment の描画し直しだけは Tunnel リソースが残っている場合に行います。
+// deleteDNS が false の場合（認証情報を使えない場合）は DNS レコードに触れません。
+func (r *CloudflareReconciler) releaseTunnel(ctx context.Context, cf *cloudflarev1beta1.Cloudflare, tunnelKey types.NamespacedName, deleteDNS bool) error {
+	bound, err := r.boundCloudflares(ctx, tunnelKey)
+	if err != nil {
+		return err
+	}
+	var remaining []cloudflarev1beta1.Cloudflare
+	keep := map[string]bool{}
+	for _, item := range bound {
+		if item.Namespace == cf.Namespace && item.Name == cf.Name {
+			continue
+		}
+		remaining = append(remaining, item)
+		for hostname := range managedHostnames(item) {
+			keep[hostname] = true
+		}
+	}
+
+	// Tunnel ID が無ければ、まだ DNS レコードを作成していない
+	if deleteDNS && cloudflareTunnelID(cf) != "" {
+		// tunnelRef が付け替えられていても、外す Tunnel の認証情報で削除する
+		released := *cf
+		released.Spec.TunnelRef = &cloudflarev1beta1.TunnelReference{Name: tunnelKey.Name, Namespace: tunnelKey.Namespace}
+		if err := r.deleteDNSRecord(ctx, released, keep); err != nil {
+			return err
+		}
+	}
+
+	var tunnel cloudflarev1beta1.Tunnel
+	err = r.Get(ctx, tunnelKey, &tunnel)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tunnel.Status.TunnelID == "" || !tunnel.ObjectMeta.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	return r.reconcileTunnelConnector(ctx, &tunnel, remaining)
+}
+
+// reconcileTunnelConnector は共有 Tunnel 用の設定と Deployment を、参照している全リソースのルールで描画します。
+// 各リソースと Tunnel の fallback の serviceRef はここで解決します。BackendNotFound 条件は各リソースの Reconcile で記録します。
+func (r *CloudflareReconciler) reconcileTunnelConnector(ctx context.Context, tunnel *cloudflarev1beta1.Tunnel, bound []cloudflarev1beta1.Cloudflare) error {
+	resolvedBound := make([]cloudflarev1beta1.Cloudflare, 0, len(bound))
+	for i := range bound {
+		resolved, _, err := resolveBackends(ctx, r.Client, &bound[i])
+		if err != nil {
+			return err
+		}
+		resolvedBound = append(resolvedBound, *resolved)
+	}
 
-	// TODO(user): your logic here
+	tunnel = tunnel.DeepCopy()
+	fallback, _, err := resolveFallback(ctx, r.Client, tunnel.Spec.Fallback, tunnel.Namespace)
+	if err != nil {
+		return err
+	}
+	tunnel.Spec.Fallback = fallback
+	return r.reconcileConnector(ctx, sharedConnector(tunnel, resolvedBound))
+}
 
-	return ctrl.Result{}, nil
+// reconcileConnector は connector の ingress 設定と cloudflared の Deployment を描画します。
+// リモート管理の Tunnel では設定を Cloudflare に登録し、不要になった ConfigMap を削除します。
+func (r *CloudflareReconciler) reconcileConnector(ctx context.Context, conn connector) error {
+	if conn.remote {
+		if err := r.reconcileRemoteConfig(ctx, conn); err != nil {
+			return err
+		}
+		if err := r.deleteConfigMap(ctx, conn); err != nil {
+			return err
+		}
+	} else if err := r.reconcileConfigMap(ctx, conn); err != nil {
+		return err
+	}
+	return r.reconcileDeployment(ctx, conn)
 }
 
-func (r *CloudflareReconciler) reconcileConfigMap(ctx context.Context, cloudflare cloudflarev1beta1.Cloudflare) error {
+// reconcileRemoteConfig は Tunnel の設定 API に ingress ルールを登録します。登録済みの内容と既定値を除いて同じ場合は何もしません。
+// 登録した場合は connector の所有者に ConfigUpdated の Event を記録します。
+func (r *CloudflareReconciler) reconcileRemoteConfig(ctx context.Context, conn connector) error {
 	logger := log.FromContext(ctx)
 
-	cm := &corev1.ConfigMap{}
-	cm.SetNamespace(cloudflare.Namespace)
-	cm.SetName("cloudflare-" + cloudflare.Name)
+	api, accountID, err := newCloudflareAPI(ctx, r.Client, r.APIFactory, conn.owner.GetNamespace(), conn.credentials)
+	if err != nil {
+		return err
+	}
+	rc := cf.AccountIdentifier(accountID)
+	desired := remoteConfig(conn)
 
-	var ingressRules []IngressRule
+	current, err := api.GetTunnelConfiguration(ctx, rc, conn.tunnelID)
+	if err != nil {
+		return fmt.Errorf("failed to get tunnel configuration: %w", err)
+	}
+	if equality.Semantic.DeepEqual(normalizedIngress(current.Config.Ingress), normalizedIngress(desired.Ingress)) {
+		return nil
+	}
 
-	for _, content := range cloudflare.Spec.Ingress {
-		ingressRule := IngressRule{
-			Hostname: content.Hostname, // Hostnameが空の場合は省略可能
-			Service:  content.Service,
+	result, err := api.UpdateTunnelConfiguration(ctx, rc, cf.TunnelConfigurationParams{
+		TunnelID: conn.tunnelID,
+		Config:   desired,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update tunnel configuration: %w", err)
+	}
+	logger.Info("reconcile tunnel configuration successfully", "tunnelID", conn.tunnelID, "version", result.Version)
+	r.eventsFor(conn.owner).normal(eventConfigUpdated, "Pushed version %d of the ingress configuration of tunnel %s to Cloudflare", result.Version, conn.tunnelID)
+	return nil
+}
+
+// deleteConfigMap は connector の ConfigMap が残っていれば削除します。
+func (r *CloudflareReconciler) deleteConfigMap(ctx context.Context, conn connector) error {
+	cm := &corev1.ConfigMap{}
+	cm.SetNamespace(conn.owner.GetNamespace())
+	cm.SetName(conn.name)
+	return client.IgnoreNotFound(r.Delete(ctx, cm))
+}
+
+// boundCloudflares は指定した Tunnel を tunnelRef で参照している、削除中でない Cloudflare リソースを名前順で返します。
+func (r *CloudflareReconciler) boundCloudflares(ctx context.Context, tunnelKey types.NamespacedName) ([]cloudflarev1beta1.Cloudflare, error) {
+	var list cloudflarev1beta1.CloudflareList
+	if err := r.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	var bound []cloudflarev1beta1.Cloudflare
+	for _, item := range list.Items {
+		if item.Spec.TunnelRef == nil || cfapi.TunnelRefKey(item) != tunnelKey {
+			continue
 		}
-		ingressRules = append(ingressRules, ingressRule)
+		if !item.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		bound = append(bound, item)
+	}
+	sort.Slice(bound, func(i, j int) bool {
+		if bound[i].Namespace != bound[j].Namespace {
+			return bound[i].Namespace < bound[j].Namespace
+		}
+		return bound[i].Name < bound[j].Name
+	})
+	return bound, nil
+}
+
+// cloudflaresForTunnel は Tunnel の変更時に、その Tunnel を参照する Cloudflare リソースを再調整対象にします。
+func (r *CloudflareReconciler) cloudflaresForTunnel(ctx context.Context, obj client.Object) []reconcile.Request {
+	bound, err := r.boundCloudflares(ctx, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Cloudflare resources for Tunnel", "tunnel", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(bound))
+	for _, item := range bound {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
+
+// cloudflaresForDeployment は cloudflared の Deployment の変更時に、そのレプリカ数を status に記録する Cloudflare リソースを再調整対象にします。
+// 共有 Tunnel の Deployment の場合は、その Tunnel を共有する全てのリソースを対象にします。
+func (r *CloudflareReconciler) cloudflaresForDeployment(ctx context.Context, obj client.Object) []reconcile.Request {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.APIVersion != cloudflarev1beta1.GroupVersion.String() {
+		return nil
 	}
-	ingressRule := IngressRule{
-		// Hostname: "" // Hostnameが空の場合は省略可能
-		Service: "http_status:404",
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name}
+	switch owner.Kind {
+	case "Cloudflare":
+		return []reconcile.Request{{NamespacedName: key}}
+	case "Tunnel":
+		tunnel := &cloudflarev1beta1.Tunnel{}
+		tunnel.SetNamespace(key.Namespace)
+		tunnel.SetName(key.Name)
+		return r.cloudflaresForTunnel(ctx, tunnel)
 	}
-	ingressRules = append(ingressRules, ingressRule)
-	// 構造体をYAMLにシリアライズ
+	return nil
+}
+
+// cloudflaresForService は Service の変更時に、その Service を serviceRef で参照する Cloudflare リソースを再調整対象にします。
+// Tunnel の fallback が参照している場合は、その Tunnel を共有する全てのリソースを対象にします。
+func (r *CloudflareReconciler) cloudflaresForService(ctx context.Context, obj client.Object) []reconcile.Request {
+	logger := log.FromContext(ctx)
+	key := client.ObjectKeyFromObject(obj)
 
-	annotations := cloudflare.GetAnnotations()
-	if annotations == nil {
-		return fmt.Errorf("annotations not found on Tunnel resource")
+	var list cloudflarev1beta1.CloudflareList
+	if err := r.List(ctx, &list); err != nil {
+		logger.Error(err, "unable to list Cloudflare resources for Service", "service", key)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, item := range list.Items {
+		if referencesService(item, key) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+		}
 	}
 
-	tunnelID, ok := annotations["cloudflare.io/tunnel-id"]
-	if !ok {
-		return fmt.Errorf("annotation cloudflare.io/tunnel-id not found on Tunnel resource")
+	var tunnels cloudflarev1beta1.TunnelList
+	if err := r.List(ctx, &tunnels); err != nil {
+		logger.Error(err, "unable to list Tunnels for Service", "service", key)
+		return requests
 	}
-	spec := CloudflareConfig{
-		Tunnel:          tunnelID, // 必須フィールドを設定
-		CredentialsFile: "/etc/cloudflared/creds/credentials.json",
-		Ingress:         ingressRules,
-		Metrics:         "0.0.0.0:2000",
+	for _, tunnel := range tunnels.Items {
+		if fallbackReferencesService(tunnel.Spec.Fallback, tunnel.Namespace, key) {
+			requests = append(requests, r.cloudflaresForTunnel(ctx, &tunnel)...)
+		}
 	}
+	return requests
+}
 
-	yamlBytes, err := yaml.Marshal(&spec)
+func (r *CloudflareReconciler) reconcileConfigMap(ctx context.Context, conn connector) error {
+	logger := log.FromContext(ctx)
+
+	cm := &corev1.ConfigMap{}
+	cm.SetNamespace(conn.owner.GetNamespace())
+	cm.SetName(conn.name)
+
+	yamlString, err := renderConfig(conn)
 	if err != nil {
 		logger.Error(err, "configmap marshal error")
+		return err
 	}
-	// YAML文字列を出力
-	yamlString := string(yamlBytes)
-	fmt.Println(yamlString)
 
 	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cm, func() error {
 		if cm.Data == nil {
@@ -232,7 +759,7 @@ func (r *CloudflareReconciler) reconcileConfigMap(ctx context.Context, cloudflar
 
 		cm.Data["config.yaml"] = yamlString
 
-		return ctrl.SetControllerReference(&cloudflare, cm, r.Scheme)
+		return ctrl.SetControllerReference(conn.owner, cm, r.Scheme)
 	})
 
 	if err != nil {
@@ -242,114 +769,138 @@ func (r *CloudflareReconciler) reconcileConfigMap(ctx context.Context, cloudflar
 
 	if op != controllerutil.OperationResultNone {
 		logger.Info("reconcile ConfigMap successfully", "op", op)
+		r.eventsFor(conn.owner).normal(eventConfigUpdated, "ConfigMap %s with the cloudflared configuration was %s", cm.Name, op)
 	}
 
 	return nil
 }
 
-// func (r *CloudflareReconciler) reconcileSecret(ctx context.Context, cloudflare cloudflarev1beta1.Cloudflare) error {
-// 	logger := log.FromContext(ctx)
-// 	sc := &corev1.Secret{}
-// 	sc.SetName("cloudflare-" + cloudflare.Name)
-// 	sc.SetNamespace(cloudflare.Namespace)
-// 	logger.Info("test")
-
-// 	return nil
-// }
-
-func (r *CloudflareReconciler) reconcileDeployment(ctx context.Context, cloudlfare cloudflarev1beta1.Cloudflare) error {
+func (r *CloudflareReconciler) reconcileDeployment(ctx context.Context, conn connector) error {
 	logger := log.FromContext(ctx)
-	depName := "cloudflare-" + cloudlfare.Name
+	depName := conn.name
+	namespace := conn.owner.GetNamespace()
 	cloudflareimage := "cloudflare/cloudflared:2025.1.0"
-	owner, err := controllerReference(cloudlfare, r.Scheme)
+	owner, err := controllerReference(conn.owner, r.Scheme)
 	if err != nil {
 		return err
 	}
-	deployment := appsv1apply.Deployment(depName, cloudlfare.Namespace).
-		WithLabels(map[string]string{
-			"app.kubernetes.io/name":       "cloudflare",
-			"app.kubernetes.io/instance":   cloudlfare.Name,
-			"app.kubernetes.io/created-by": "cloudflared-operator-controller-manager",
-		}).
-		WithOwnerReferences(owner).
-		WithSpec(appsv1apply.DeploymentSpec().
-			WithReplicas(cloudlfare.Spec.Replicas).
-			WithSelector(metav1apply.LabelSelector().
-				WithMatchLabels(map[string]string{
-					"app.kubernetes.io/name":       "cloudflare",
-					"app.kubernetes.io/instance":   cloudlfare.Name,
-					"app.kubernetes.io/created-by": "cloudflared-operator-controller-manager",
-				}),
+
+	container := corev1apply.Container().
+		WithName("cloudflared").
+		WithImage(cloudflareimage).
+		// WithCommand("/bin/sh").
+		// WithArgs("-c", "sleep 3600").
+		WithLivenessProbe(
+			corev1apply.Probe().
+				WithHTTPGet(
+					corev1apply.HTTPGetAction().
+						WithPath("/ready").
+						WithPort(intstr.FromInt(2000)),
+				).
+				WithFailureThreshold(1).
+				WithInitialDelaySeconds(10).
+				WithPeriodSeconds(10),
+		)
+	// WithTTY(true).   // TTY を有効化
+	// WithStdin(true). // Stdin を有効化
+	podSpec := corev1apply.PodSpec()
+	podAnnotations := map[string]string{}
+
+	if conn.remote {
+		// リモート管理の Tunnel は設定を Cloudflare から受け取るため、トークンだけで起動する
+		container = container.
+			WithArgs(
+				"tunnel",
+				"--metrics",
+				"0.0.0.0:2000",
+				"--loglevel",
+				"debug",
+				"run",
+				"--token",
+				"$(TUNNEL_TOKEN)",
 			).
-			WithTemplate(corev1apply.PodTemplateSpec().
-				WithLabels(map[string]string{
-					"app.kubernetes.io/name":       "cloudflare",
-					"app.kubernetes.io/instance":   cloudlfare.Name,
-					"app.kubernetes.io/created-by": "cloudflared-operator-controller-manager",
-					//  trigger to restart
-					"kubectl.kubernetes.io/restartedAt": strings.ReplaceAll(time.Now().Format(time.RFC3339), ":", "-"),
-				}).
-				WithSpec(corev1apply.PodSpec().
-					WithContainers(
-						corev1apply.Container().
-							WithName("cloudflared").
-							WithImage(cloudflareimage).
-							WithArgs(
-								"tunnel",
-								"--config",
-								"/etc/cloudflared/config/config.yaml",
-								"--http2-origin",
-								"--loglevel",
-								"debug",
-								"run",
-							).
-							// WithCommand("/bin/sh").
-							// WithArgs("-c", "sleep 3600").
-							WithLivenessProbe(
-								corev1apply.Probe().
-									WithHTTPGet(
-										corev1apply.HTTPGetAction().
-											WithPath("/ready").
-											WithPort(intstr.FromInt(2000)),
-									).
-									WithFailureThreshold(1).
-									WithInitialDelaySeconds(10).
-									WithPeriodSeconds(10),
-							).
-							// WithTTY(true).   // TTY を有効化
-							// WithStdin(true). // Stdin を有効化
-							WithVolumeMounts(
-								corev1apply.VolumeMount().
-									WithName("config").
-									WithMountPath("/etc/cloudflared/config").
-									WithReadOnly(true),
-								corev1apply.VolumeMount().
-									WithName("creds").
-									WithMountPath("/etc/cloudflared/creds").
-									WithReadOnly(true),
-							),
-					).
-					WithVolumes(
-						corev1apply.Volume().
-							WithName("creds").
-							WithSecret(
-								corev1apply.SecretVolumeSource().
-									WithSecretName("cloudflare-"+cloudlfare.Spec.TunnelName),
+			WithEnv(corev1apply.EnvVar().
+				WithName("TUNNEL_TOKEN").
+				WithValueFrom(corev1apply.EnvVarSource().
+					WithSecretKeyRef(corev1apply.SecretKeySelector().
+						WithName(conn.secretName).
+						WithKey(tunnelTokenKey),
+					),
+				),
+			)
+		podSpec = podSpec.WithContainers(container)
+	} else {
+		config, err := renderConfig(conn)
+		if err != nil {
+			return err
+		}
+		// 設定が変わった時だけ Pod を再起動する
+		podAnnotations[configHashAnnotation] = configHash(config)
+
+		container = container.
+			WithArgs(
+				"tunnel",
+				"--config",
+				"/etc/cloudflared/config/config.yaml",
+				"--http2-origin",
+				"--loglevel",
+				"debug",
+				"run",
+			).
+			WithVolumeMounts(
+				corev1apply.VolumeMount().
+					WithName("config").
+					WithMountPath("/etc/cloudflared/config").
+					WithReadOnly(true),
+				corev1apply.VolumeMount().
+					WithName("creds").
+					WithMountPath("/etc/cloudflared/creds").
+					WithReadOnly(true),
+			)
+		podSpec = podSpec.
+			WithContainers(container).
+			WithVolumes(
+				corev1apply.Volume().
+					WithName("creds").
+					WithSecret(
+						corev1apply.SecretVolumeSource().
+							WithSecretName(conn.secretName).
+							WithItems(
+								corev1apply.KeyToPath().
+									WithKey("credentials.json").
+									WithPath("credentials.json"),
 							),
-						corev1apply.Volume().
-							WithName("config").
-							WithConfigMap(
-								corev1apply.ConfigMapVolumeSource().
-									WithName("cloudflare-"+cloudlfare.Name).
-									WithItems(
-										corev1apply.KeyToPath().
-											WithKey("config.yaml").
-											WithPath("config.yaml"),
-									),
+					),
+				corev1apply.Volume().
+					WithName("config").
+					WithConfigMap(
+						corev1apply.ConfigMapVolumeSource().
+							WithName(conn.name).
+							WithItems(
+								corev1apply.KeyToPath().
+									WithKey("config.yaml").
+									WithPath("config.yaml"),
 							),
 					),
-				),
-			),
+			)
+	}
+
+	template := corev1apply.PodTemplateSpec().
+		WithLabels(conn.labels).
+		WithSpec(podSpec)
+	if len(podAnnotations) > 0 {
+		template = template.WithAnnotations(podAnnotations)
+	}
+
+	deployment := appsv1apply.Deployment(depName, namespace).
+		WithLabels(conn.labels).
+		WithOwnerReferences(owner).
+		WithSpec(appsv1apply.DeploymentSpec().
+			WithReplicas(conn.replicas).
+			WithSelector(metav1apply.LabelSelector().
+				WithMatchLabels(conn.labels),
+			).
+			WithTemplate(template),
 		)
 	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
 	if err != nil {
@@ -360,7 +911,7 @@ func (r *CloudflareReconciler) reconcileDeployment(ctx context.Context, cloudlfa
 	}
 
 	var current appsv1.Deployment
-	err = r.Get(ctx, client.ObjectKey{Namespace: cloudlfare.Namespace, Name: depName}, &current)
+	err = r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: depName}, &current)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
@@ -382,459 +933,361 @@ func (r *CloudflareReconciler) reconcileDeployment(ctx context.Context, cloudlfa
 		logger.Error(err, "unable to create or update Deployment")
 		return err
 	}
-	logger.Info("reconcile Deployment successfully", "name", cloudlfare.Name)
+	logger.Info("reconcile Deployment successfully", "name", depName)
 	return nil
 }
-func (r *CloudflareReconciler) updateStatus(ctx context.Context, Cloudflare cloudflarev1beta1.Cloudflare) (ctrl.Result, error) {
-	meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-		Type:   cloudflarev1beta1.TypeCloudflareViewAvailable,
-		Status: metav1.ConditionTrue,
-		Reason: "OK",
-	})
-	meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-		Type:   cloudflarev1beta1.TypeCloudflareViewDegraded,
-		Status: metav1.ConditionFalse,
-		Reason: "OK",
-	})
-
-	var cm corev1.ConfigMap
-	err := r.Get(ctx, client.ObjectKey{Namespace: Cloudflare.Namespace, Name: "cloudflare-" + Cloudflare.Name}, &cm)
-	if errors.IsNotFound(err) {
-		meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-			Type:    cloudflarev1beta1.TypeCloudflareViewDegraded,
-			Status:  metav1.ConditionTrue,
-			Reason:  "Reconciling",
-			Message: "ConfigMap not found",
-		})
-		meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-			Type:   cloudflarev1beta1.TypeCloudflareViewAvailable,
-			Status: metav1.ConditionFalse,
-			Reason: "Reconciling",
-		})
-	} else if err != nil {
-		return ctrl.Result{}, err
-	}
-
-	var dep appsv1.Deployment
-	err = r.Get(ctx, client.ObjectKey{Namespace: Cloudflare.Namespace, Name: "viewer-" + Cloudflare.Name}, &dep)
-	if errors.IsNotFound(err) {
-		meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-			Type:    cloudflarev1beta1.TypeCloudflareViewDegraded,
-			Status:  metav1.ConditionTrue,
-			Reason:  "Reconciling",
-			Message: "Deployment not found",
-		})
-		meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-			Type:   cloudflarev1beta1.TypeCloudflareViewAvailable,
-			Status: metav1.ConditionFalse,
-			Reason: "Reconciling",
-		})
-	} else if err != nil {
-		return ctrl.Result{}, err
-	}
-
-	result := ctrl.Result{}
-	if dep.Status.AvailableReplicas == 0 {
-		meta.SetStatusCondition(&Cloudflare.Status.Conditions, metav1.Condition{
-			Type:    cloudflarev1beta1.TypeCloudflareViewAvailable,
-			Status:  metav1.ConditionFalse,
-			Reason:  "Unavailable",
-			Message: "AvailableReplicas is 0",
-		})
-		result = ctrl.Result{Requeue: true}
-	}
-
-	err = r.Status().Update(ctx, &Cloudflare)
-	return result, err
-}
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CloudflareReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cloudflarev1beta1.Cloudflare{}).
+		Owns(&corev1.Secret{}).
+		Watches(&cloudflarev1beta1.Tunnel{}, handler.EnqueueRequestsFromMapFunc(r.cloudflaresForTunnel)).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.cloudflaresForService)).
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.cloudflaresForDeployment)).
 		Named("cloudflare").
 		Complete(r)
 }
-func controllerReference(cloudflare cloudflarev1beta1.Cloudflare, scheme *runtime.Scheme) (*metav1apply.OwnerReferenceApplyConfiguration, error) {
-	gvk, err := apiutil.GVKForObject(&cloudflare, scheme)
+func controllerReference(owner client.Object, scheme *runtime.Scheme) (*metav1apply.OwnerReferenceApplyConfiguration, error) {
+	gvk, err := apiutil.GVKForObject(owner, scheme)
 	if err != nil {
 		return nil, err
 	}
 	ref := metav1apply.OwnerReference().
 		WithAPIVersion(gvk.GroupVersion().String()).
 		WithKind(gvk.Kind).
-		WithName(cloudflare.Name).
-		WithUID(cloudflare.GetUID()).
+		WithName(owner.GetName()).
+		WithUID(owner.GetUID()).
 		WithBlockOwnerDeletion(true).
 		WithController(true)
 	return ref, nil
 }
 
-// make docker-build
-// kind load docker-image controller:latest
-// kubectl logs -n cloudflared-operator-system deployments/cloudflared-operator-controller-manager -c manager -f
-// kubectl rollout restart -n cloudflared-operator-system deployment cloudflared-operator-controller-manager
-// kc delete cloudflares cloudflare-sample
-
-// extractZoneFromHostname はホスト名からゾーン名（例："a.qpid.jp" → "qpid.jp"）を単純に抽出します。
-// ※ 実際は publicsuffix パッケージなどを利用して正確に判定してください。
-func extractZoneFromHostname(hostname string) (string, error) {
-	parts := strings.Split(hostname, ".")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid hostname: %s", hostname)
+// cloudflareAPI は Cloudflare リソースの認証情報と Reconciler の APIFactory で Cloudflare API クライアントを生成します。
+func (r *CloudflareReconciler) cloudflareAPI(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare) (cfapi.API, string, error) {
+	namespace, src, err := r.credentialsSource(ctx, cloudflare)
+	if err != nil {
+		return nil, "", err
 	}
-	zone := fmt.Sprintf("%s.%s", parts[len(parts)-2], parts[len(parts)-1])
-	return zone, nil
+	return newCloudflareAPI(ctx, r.Client, r.APIFactory, namespace, src)
 }
 
-// getAPITokenFromSecret は、指定された namespace/name の Secret から API トークン（キー "apiToken"）を取得します。
-func (r *CloudflareReconciler) getAPITokenFromSecret(ctx context.Context) (string, string, error) {
-	// ここでは固定値として設定しています。必要に応じて CRD の Spec や ConfigMap 等から動的に取得してください。
-	secret := &corev1.Secret{}
-	secretName := "cloudflare-api-token"
-	secretNamespace := "default"
-	if err := r.Get(ctx, client.ObjectKey{Namespace: secretNamespace, Name: secretName}, secret); err != nil {
-		return "", "", fmt.Errorf("failed to get secret %s/%s: %w", secretNamespace, secretName, err)
+// credentialsSource は Cloudflare リソースの認証情報の取得元と、それを解決する namespace を返します。
+// tunnelRef を指定して認証情報を省略したリソースは Tunnel の認証情報を Tunnel の namespace で解決し、
+// Tunnel リソースが削除されたか namespace を許可しなくなった場合は、status に記録した以前の取得元を使います。
+func (r *CloudflareReconciler) credentialsSource(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare) (string, cloudflarev1beta1.CredentialsSource, error) {
+	namespace, src, err := cfapi.CloudflareCredentialsSource(ctx, r.Client, cloudflare)
+	if isTunnelCredentialsError(err) && cloudflare.Status.TunnelCredentials != nil {
+		inherited := cloudflare.Status.TunnelCredentials
+		return inherited.Namespace, inherited.CredentialsSource, nil
 	}
-	apiTokenBytes, ok := secret.Data["apiToken"]
-	accountIDBytes, ok := secret.Data["account_id"]
-	if !ok {
-		return "", "", fmt.Errorf("secret %s/%s does not contain key 'apiToken'", secretNamespace, secretName)
-	}
-	apiToken := strings.TrimSpace(string(apiTokenBytes))
-	accountID := strings.TrimSpace(string(accountIDBytes))
-
-	return apiToken, accountID, nil
+	return namespace, src, err
 }
 
-func (r *CloudflareReconciler) reconcileDNSRecord(ctx context.Context, cloudflare cloudflarev1beta1.Cloudflare) error {
+// reconcileDNSRecord は dnsManagement が Managed の ingress ルールのホスト名ごとに Tunnel を指す CNAME を作成／更新します。
+// 変更や削除は所有者の TXT レコードでこのリソースのものと分かるレコードだけに行い、
+// 他の所有者のレコードとの衝突や失敗はホスト名ごとの状態として返します。
+// tunnelHostnames は同じ Tunnel を共有する全リソースのホスト名で、ここに含まれるレコードは所有を外しても CNAME を残します。
+// 以前に同期したレコードが外部から削除・変更されていた場合は drift に記録し、driftPolicy が Report なら元に戻さずに Drifted とします。
+func (r *CloudflareReconciler) reconcileDNSRecord(ctx context.Context, cloudflare cloudflarev1beta1.Cloudflare, tunnelID string, tunnelHostnames map[string]bool, drift *driftReport) ([]cloudflarev1beta1.HostnameStatus, error) {
 	logger := log.FromContext(ctx)
 
-	// API トークンは Secret から取得する
-	apiToken, _, err := r.getAPITokenFromSecret(ctx)
+	// API クライアントは Cloudflare リソースが参照する認証情報から生成する
+	api, accountID, err := r.cloudflareAPI(ctx, &cloudflare)
 	if err != nil {
-		return err
-	}
-
-	// API クライアントの初期化
-	api, err := cf.NewWithAPIToken(apiToken)
-	if err != nil {
-		return fmt.Errorf("failed to create Cloudflare API client: %w", err)
+		return nil, err
 	}
-
-	annotations := cloudflare.GetAnnotations()
-	if annotations == nil {
-		return fmt.Errorf("annotations not found on Tunnel resource")
+	zones := newZoneResolver(api, accountID)
+	events := r.eventsFor(&cloudflare)
+	registry := &dnsRegistry{
+		api:    api,
+		owner:  r.dnsOwnerFor(&cloudflare),
+		target: fmt.Sprintf("%s.cfargotunnel.com", tunnelID),
+		events: events,
 	}
-
-	tunnelID, ok := annotations["cloudflare.io/tunnel-id"]
-	if !ok {
-		return fmt.Errorf("annotation cloudflare.io/tunnel-id not found on Tunnel resource")
+	previous := map[string]cloudflarev1beta1.HostnameStatus{}
+	for _, status := range cloudflare.Status.Hostnames {
+		previous[status.Hostname] = status
 	}
-
-	// tunnelID := cloudflare.Spec.TunnelID
-	// if tunnelID == "" {
-	// 	return fmt.Errorf("tunnelID is empty in CRD spec")
-	// }
-	targetCNAME := fmt.Sprintf("%s.cfargotunnel.com", tunnelID)
+	// 外部からの変更は、Zone ごとに DNS レコードの一覧をキャッシュを使わずに 1 度取り直して確かめる
+	driftCtx := cfapi.Refresh(ctx)
 
 	// CRD の ingress ルールをゾーン毎にグループ化（key: zoneID、value: 対象ホストの存在マップ）
 	desiredRecords := make(map[string]map[string]bool)
+	var statuses []cloudflarev1beta1.HostnameStatus
+	seen := map[string]bool{}
 
 	for _, rule := range cloudflare.Spec.Ingress {
-		if rule.Hostname == "" {
+		if matchesAnyHost(rule.Hostname) || seen[rule.Hostname] {
 			continue
 		}
-		zoneName, err := extractZoneFromHostname(rule.Hostname)
-		if err != nil {
-			logger.Error(err, "failed to extract zone from hostname", "hostname", rule.Hostname)
-			continue
+		seen[rule.Hostname] = true
+		management := dnsManagementFor(&cloudflare, rule)
+		managed := management == cloudflarev1beta1.DNSManagementManaged
+		status := cloudflarev1beta1.HostnameStatus{Hostname: rule.Hostname}
+		if !managed {
+			status.State = cloudflarev1beta1.DNSRecordUnmanaged
+			status.Message = fmt.Sprintf("dnsManagement is %s", management)
+			statuses = append(statuses, status)
 		}
-		zoneID, err := api.ZoneIDByName(zoneName)
+
+		zoneID, err := zones.zoneID(ctx, rule)
 		if err != nil {
-			logger.Error(err, "failed to get zone ID", "zoneName", zoneName)
+			if managed {
+				logger.Error(err, "failed to resolve zone", "hostname", rule.Hostname)
+				events.warning(eventDNSRecordError, "Failed to resolve the zone of %s: %v", rule.Hostname, err)
+				status.State = cloudflarev1beta1.DNSRecordError
+				status.Message = err.Error()
+				statuses = append(statuses, status)
+			}
 			continue
 		}
-		// 記録用マップ
+		// 記録用マップ。Managed 以外のルールのゾーンも、以前に作成したレコードを削除するために含める
 		if desiredRecords[zoneID] == nil {
 			desiredRecords[zoneID] = make(map[string]bool)
 		}
-		desiredRecords[zoneID][rule.Hostname] = true
-
-		// zoneID を ResourceContainer 型に変換して渡す
-		resourceContainer := &cf.ResourceContainer{Identifier: zoneID}
-
-		// DNS レコードの取得（ListDNSRecords は (records, resp, error) を返す）
-		listParams := cf.ListDNSRecordsParams{
-			Type: "CNAME",
-			Name: rule.Hostname,
-		}
-		records, _, err := api.ListDNSRecords(ctx, resourceContainer, listParams)
-		if err != nil {
-			logger.Error(err, "failed to list DNS records", "hostname", rule.Hostname)
+		if !managed {
 			continue
 		}
-
-		if len(records) == 0 {
-			// レコードがなければ作成
-			proxied := true
-			createParams := cf.CreateDNSRecordParams{
-				Type:    "CNAME",
-				Name:    rule.Hostname,
-				Content: targetCNAME,
-				TTL:     120,
-				Proxied: &proxied,
-			}
-			_, err := api.CreateDNSRecord(ctx, resourceContainer, createParams)
-			if err != nil {
-				logger.Error(err, "failed to create DNS record", "hostname", rule.Hostname)
-				continue
-			}
-			logger.Info("DNS record created", "hostname", rule.Hostname, "content", targetCNAME)
-		} else {
-			// 存在するレコードについて、最初のものを対象とする
-			record := records[0]
-			if record.Content != targetCNAME {
-				proxied := false
-				updateParams := cf.UpdateDNSRecordParams{
-					ID:      record.ID,
-					Type:    "CNAME",
-					Name:    rule.Hostname,
-					Content: targetCNAME,
-					TTL:     120,
-					Proxied: &proxied,
-				}
-				updatedRecord, err := api.UpdateDNSRecord(ctx, resourceContainer, updateParams)
-				if err != nil {
-					logger.Error(err, "failed to update DNS record", "hostname", rule.Hostname)
+		desiredRecords[zoneID][rule.Hostname] = true
+		status.ZoneID = zoneID
+		status.Zone = zones.zoneName(ctx, zoneID)
+
+		settings := dnsSettingsFor(cloudflare.Spec.DNS, rule.DNS)
+		if last := previous[rule.Hostname]; drift.checksDNS(tunnelID) &&
+			(last.State == cloudflarev1beta1.DNSRecordSynced || last.State == cloudflarev1beta1.DNSRecordDrifted) {
+			// 確かめられなかった場合は、同じ呼び出しをする ensure で失敗を記録する
+			drifted, err := registry.drift(driftCtx, zoneID, rule.Hostname, settings)
+			if err == nil && drifted != "" {
+				if drift.reportOnly() {
+					drift.detect(drifted)
+					status.State = cloudflarev1beta1.DNSRecordDrifted
+					status.Message = drifted
+					status.RecordID = last.RecordID
+					statuses = append(statuses, status)
 					continue
 				}
-				logger.Info("DNS record updated", "hostname", rule.Hostname, "content", updatedRecord.Content)
-			} else {
-				logger.Info("DNS record is already up-to-date", "hostname", rule.Hostname)
+				drift.correct(drifted)
 			}
 		}
-	}
 
-	// 各ゾーンごとに、CRD に存在しないホストのレコードを削除する
-	for zoneID, desiredHostnames := range desiredRecords {
-		resourceContainer := &cf.ResourceContainer{Identifier: zoneID}
-		listParams := cf.ListDNSRecordsParams{
-			Type: "CNAME",
+		recordID, conflict, err := registry.ensure(ctx, zoneID, rule.Hostname, settings)
+		switch {
+		case err != nil:
+			logger.Error(err, "failed to reconcile DNS record", "hostname", rule.Hostname)
+			events.warning(eventDNSRecordError, "Failed to reconcile the DNS record of %s: %v", rule.Hostname, err)
+			status.State = cloudflarev1beta1.DNSRecordError
+			status.Message = err.Error()
+		case conflict != "":
+			logger.Info("DNS record is owned by others", "hostname", rule.Hostname, "conflict", conflict)
+			// 同じ衝突を Reconcile の度に記録しないよう、新たに衝突した場合だけ記録する
+			if last := previous[rule.Hostname]; last.State != cloudflarev1beta1.DNSRecordConflict || last.Message != conflict {
+				events.warning(eventDNSRecordConflict, "Left the DNS record of %s untouched: %s", rule.Hostname, conflict)
+			}
+			status.State = cloudflarev1beta1.DNSRecordConflict
+			status.Message = conflict
+		default:
+			status.State = cloudflarev1beta1.DNSRecordSynced
+			status.RecordID = recordID
 		}
-		records, _, err := api.ListDNSRecords(ctx, resourceContainer, listParams)
+		statuses = append(statuses, status)
+	}
+
+	// 各ゾーンごとに、このリソースが所有していて CRD に存在しないホストのレコードを削除する
+	for zoneID, desired := range desiredRecords {
+		owned, err := registry.owned(ctx, zoneID)
 		if err != nil {
 			logger.Error(err, "failed to list DNS records for cleanup", "zoneID", zoneID)
 			continue
 		}
-		for _, rec := range records {
-			// 今回の tunnel 用レコードで、かつ CRD に存在しなければ削除
-			if rec.Content == targetCNAME {
-				if _, exists := desiredHostnames[rec.Name]; !exists {
-					err = api.DeleteDNSRecord(ctx, resourceContainer, rec.ID)
-					if err != nil {
-						logger.Error(err, "failed to delete DNS record", "hostname", rec.Name, "recordID", rec.ID)
-						continue
-					}
-					logger.Info("DNS record deleted", "hostname", rec.Name, "recordID", rec.ID)
-				}
+		for _, hostname := range owned {
+			if desired[hostname] {
+				continue
+			}
+			if err := registry.release(ctx, zoneID, hostname, tunnelHostnames[hostname]); err != nil {
+				logger.Error(err, "failed to delete DNS record", "hostname", hostname)
+				events.warning(eventDNSRecordError, "Failed to delete the DNS record of %s: %v", hostname, err)
 			}
 		}
 	}
 
-	return nil
+	return statuses, nil
 }
 
-// deleteDNSRecord は、CRD 削除時に CRD 内の ingress ルールに対応する DNS レコードを削除します。
-func (r *CloudflareReconciler) deleteDNSRecord(ctx context.Context, cfCR cloudflarev1beta1.Cloudflare) error {
+// reconcileStatus は cloudflared の Deployment のレプリカ数とホスト名ごとの DNS レコードの状態を status に記録し、
+// DeploymentAvailable、DNSSynced、Drifted、TunnelHealthy 条件と Tunnel の接続状態を更新します。
+// conn は Cloudflare リソースのルールを描画した connector で、tunnelRef の場合は共有 Tunnel のものです。
+func (r *CloudflareReconciler) reconcileStatus(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare, conn connector, hostnames []cloudflarev1beta1.HostnameStatus, drift *driftReport) error {
+	var dep appsv1.Deployment
+	err := r.Get(ctx, client.ObjectKey{Namespace: conn.owner.GetNamespace(), Name: conn.name}, &dep)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	status := cloudflare.Status.DeepCopy()
+	status.Replicas = conn.replicas
+	status.ReadyReplicas = dep.Status.ReadyReplicas
+	status.Hostnames = hostnames
+	status.ObservedGeneration = cloudflare.Generation
+	var conflicts []string
+	for _, hostname := range hostnames {
+		if hostname.State == cloudflarev1beta1.DNSRecordConflict {
+			conflicts = append(conflicts, hostname.Message)
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, dnsConflictCondition(conflicts, cloudflare.Generation))
+	meta.SetStatusCondition(&status.Conditions, dnsSyncedCondition(hostnames, cloudflare.Generation))
+	meta.SetStatusCondition(&status.Conditions, drift.condition(cloudflare.Generation))
+	var found *appsv1.Deployment
+	if err == nil {
+		found = &dep
+	}
+	meta.SetStatusCondition(&status.Conditions, deploymentCondition(conn.name, found, conn.replicas, cloudflare.Generation))
+	health, healthy := r.tunnelHealth(ctx, cloudflare, conn)
+	status.TunnelHealth = health
+	meta.SetStatusCondition(&status.Conditions, healthy)
+	recordCloudflareMetrics(cloudflare.Namespace, cloudflare.Name, status)
+
+	if equality.Semantic.DeepEqual(status, &cloudflare.Status) {
+		return nil
+	}
+	cloudflare.Status = *status
+	return r.Status().Update(ctx, cloudflare)
+}
+
+// deleteDNSRecord は、CRD 削除時に CRD 内の ingress ルールに対応し、このリソースが所有する DNS レコードを削除します。
+// keep に含まれるホスト名は、同じ Tunnel を共有する他のリソースが使っているため所有だけを外して CNAME を残します。
+func (r *CloudflareReconciler) deleteDNSRecord(ctx context.Context, cfCR cloudflarev1beta1.Cloudflare, keep map[string]bool) error {
 	logger := log.FromContext(ctx)
 
-	apiToken, _, err := r.getAPITokenFromSecret(ctx)
+	api, accountID, err := r.cloudflareAPI(ctx, &cfCR)
 	if err != nil {
 		return err
 	}
-	api, err := cf.NewWithAPIToken(apiToken)
-	if err != nil {
-		return fmt.Errorf("failed to create Cloudflare API client: %w", err)
-	}
+	zones := newZoneResolver(api, accountID)
+	events := r.eventsFor(&cfCR)
+	registry := &dnsRegistry{api: api, owner: r.dnsOwnerFor(&cfCR), events: events}
 
 	for _, rule := range cfCR.Spec.Ingress {
-		if rule.Hostname == "" {
-			continue
-		}
-		zoneName, err := extractZoneFromHostname(rule.Hostname)
-		if err != nil {
-			logger.Error(err, "failed to extract zone from hostname", "hostname", rule.Hostname)
-			continue
-		}
-		zoneID, err := api.ZoneIDByName(zoneName)
-		if err != nil {
-			logger.Error(err, "failed to get zone ID", "zoneName", zoneName)
+		if matchesAnyHost(rule.Hostname) {
 			continue
 		}
-		resourceContainer := &cf.ResourceContainer{Identifier: zoneID}
-		listParams := cf.ListDNSRecordsParams{
-			Type: "CNAME",
-			Name: rule.Hostname,
-		}
-		records, _, err := api.ListDNSRecords(ctx, resourceContainer, listParams)
+		zoneID, err := zones.zoneID(ctx, rule)
 		if err != nil {
-			logger.Error(err, "failed to list DNS records", "hostname", rule.Hostname)
+			logger.Error(err, "failed to resolve zone", "hostname", rule.Hostname)
 			continue
 		}
-		for _, record := range records {
-			err = api.DeleteDNSRecord(ctx, resourceContainer, record.ID)
-			if err != nil {
-				logger.Error(err, "failed to delete DNS record", "hostname", rule.Hostname, "recordID", record.ID)
-				continue
-			}
-			logger.Info("DNS record deleted", "hostname", rule.Hostname, "recordID", record.ID)
+		if err := registry.release(ctx, zoneID, rule.Hostname, keep[rule.Hostname]); err != nil {
+			logger.Error(err, "failed to delete DNS record", "hostname", rule.Hostname)
+			events.warning(eventDNSRecordError, "Failed to delete the DNS record of %s: %v", rule.Hostname, err)
 		}
 	}
 
 	return nil
 }
 
-func (r *CloudflareReconciler) reconcileTunnel(ctx context.Context, cloudflare cloudflarev1beta1.Cloudflare) error {
+// reconcileTunnel は tunnel_name の Tunnel を作成（同名があれば再利用）し、その credentials Secret を
+// Cloudflare リソースと同じ namespace に作成します。Secret は Cloudflare リソースの削除時にガベージコレクションされます。
+// status の Tunnel が外部から削除・置き換えられていた場合は drift に記録し、driftPolicy が Report なら errTunnelDrifted を返します。
+func (r *CloudflareReconciler) reconcileTunnel(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare, drift *driftReport) error {
 	logger := log.FromContext(ctx)
-	tunnelID, tunnelSecret, accountID, err := r.createTunnel(ctx, cloudflare.Spec.TunnelName)
+	api, accountID, err := r.cloudflareAPI(ctx, cloudflare)
 	if err != nil {
-		return fmt.Errorf("failed to create Cloudflare tunnel: %w", err)
+		return err
 	}
-	fmt.Println("tunnel Secret:", tunnelSecret)
-
-	// CRのannotationにtunnelIDを追加する
-	if cloudflare.Annotations == nil {
-		cloudflare.Annotations = map[string]string{}
+	// 以前に作成・再利用した Tunnel が残っているかは、キャッシュを使わずに確かめる
+	findCtx := ctx
+	if cloudflare.Status.TunnelID != "" {
+		findCtx = cfapi.Refresh(ctx)
 	}
-	cloudflare.Annotations["cloudflare.io/tunnel-id"] = tunnelID
-
-	// CRの更新を実施
-	if err := r.Update(ctx, &cloudflare); err != nil {
-		logger.Error(err, "failed to update Tunnel resource with tunnel ID annotation")
-		return err
+	tunnelID, err := findTunnel(findCtx, api, accountID, cloudflare.Spec.TunnelName)
+	// tunnel_name を変えた場合は別の Tunnel を使うため、外部からの変更として扱わない
+	if err == nil && cloudflare.Status.TunnelID != "" && cloudflare.Status.TunnelName == cloudflare.Spec.TunnelName &&
+		tunnelID != cloudflare.Status.TunnelID {
+		message := fmt.Sprintf("tunnel %s (%s) was deleted outside the operator", cloudflare.Spec.TunnelName, cloudflare.Status.TunnelID)
+		if tunnelID != "" {
+			message = fmt.Sprintf("tunnel %s was replaced outside the operator by %s (previously %s)", cloudflare.Spec.TunnelName, tunnelID, cloudflare.Status.TunnelID)
+		}
+		if drift.reportOnly() {
+			drift.detect(message)
+			if err := r.setCondition(ctx, cloudflare, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "TunnelDrifted",
+				Message:            message,
+				ObservedGeneration: cloudflare.Generation,
+			}); err != nil {
+				return err
+			}
+			return errTunnelDrifted
+		}
+		drift.correct(message)
 	}
-
-	if tunnelSecret == "" {
-		fmt.Println("secret:", tunnelSecret)
-		return nil
+	var tunnelSecret string
+	if err == nil && tunnelID == "" {
+		tunnelID, tunnelSecret, err = createTunnel(ctx, api, accountID, cloudflare.Spec.TunnelName, cloudflare.Spec.ConfigSource)
 	}
-
-	// if tunnelSecret != "" {
-	// 	// 例: Secret 名は "cloudflare-" + tunnelName としているのでそれを取得
-	// 	var existingSecret corev1.Secret
-	// 	secretName := "cloudflare-" + cloudflare.Spec.TunnelName
-	// 	if err := r.Get(ctx, client.ObjectKey{Namespace: "default", Name: secretName}, &existingSecret); err != nil {
-	// 		return fmt.Errorf("tunnelSecret is empty and failed to retrieve existing secret: %w", err)
-	// 	}
-	// 	// 既存の credentials.json を取得して JSON の TunnelSecret を抜き出す処理を実装するか、
-	// 	// 単純に既存の Secret をそのまま利用する
-	// 	op, err := ctrl.CreateOrUpdate(ctx, r.Client, existingSecret, func() error {
-	// 		return ctrl.SetControllerReference(&cloudflare, secret, r.Scheme)
-	// 	})
-	// 	tunnelSecret = extractTunnelSecret(existingSecret.Data["credentials.json"])
-	// 	if tunnelSecret == "" {
-	// 		return fmt.Errorf("tunnelSecret is empty even after checking existing secret")
-	// 	}
-	// }
-
-	c := fmt.Sprintf(`{"AccountTag":"%s","TunnelSecret":"%s","TunnelID":"%s"}`, accountID, tunnelSecret, tunnelID)
-	// cb := []byte(c)
-	// credentialBase64 := base64.StdEncoding.EncodeToString(cb)
-	data := map[string][]byte{
-		"credentials.json": []byte(c),
-	}
-
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "cloudflare-" + cloudflare.Spec.TunnelName,
-			Namespace: "default",
-		},
-		Data: data,
-	}
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, secret, func() error {
-		return ctrl.SetControllerReference(&cloudflare, secret, r.Scheme)
-	})
-
 	if err != nil {
-		logger.Error(err, "unable to create or update Secret")
+		err = fmt.Errorf("failed to create Cloudflare tunnel: %w", err)
+		r.eventsFor(cloudflare).warning(eventTunnelError, "Failed to create tunnel %s: %v", cloudflare.Spec.TunnelName, err)
+		if updateErr := r.setCondition(ctx, cloudflare, tunnelReadyCondition("", err, cloudflare.Generation)); updateErr != nil {
+			return updateErr
+		}
 		return err
 	}
 
-	if op != controllerutil.OperationResultNone {
-		logger.Info("reconcile Secret successfully", "op", op)
-	}
-	return nil
-}
-
-func (r *CloudflareReconciler) createTunnel(ctx context.Context, tunnelName string) (string, string, string, error) {
-	// logger := log.FromContext(ctx)
-	apiToken, accountID, err := r.getAPITokenFromSecret(ctx)
-	if err != nil {
-		return "", "", "", err
-	}
-	api, err := cf.NewWithAPIToken(apiToken)
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to create Cloudflare API client: %w", err)
+	// Tunnel の ID と名前を status に記録する。シークレットが返るのは新規作成した場合だけ
+	if cloudflare.Status.TunnelID != tunnelID {
+		if tunnelSecret != "" {
+			r.eventsFor(cloudflare).normal(eventTunnelCreated, "Created tunnel %s (%s)", cloudflare.Spec.TunnelName, tunnelID)
+		} else {
+			r.eventsFor(cloudflare).normal(eventTunnelAdopted, "Adopted existing tunnel %s (%s)", cloudflare.Spec.TunnelName, tunnelID)
+		}
 	}
-
-	randSecret := make([]byte, 32)
-	if _, err := rand.Read(randSecret); err != nil {
-		return "", "", "", err
+	if cloudflare.Status.TunnelID != tunnelID || cloudflare.Status.TunnelName != cloudflare.Spec.TunnelName {
+		cloudflare.Status.TunnelID = tunnelID
+		cloudflare.Status.TunnelName = cloudflare.Spec.TunnelName
+		if err := r.Status().Update(ctx, cloudflare); err != nil {
+			logger.Error(err, "failed to update Cloudflare status with tunnel ID")
+			return err
+		}
 	}
-	tunnelSecret := base64.StdEncoding.EncodeToString(randSecret)
 
-	rc := cloudflare.AccountIdentifier(accountID)
-
-	listparam := cloudflare.TunnelListParams{}
-	tunnels, _, err := api.ListTunnels(ctx, rc, listparam)
+	err = reconcileTunnelCredentials(ctx, r.Client, r.Scheme, cloudflare, inlineSecretName(cloudflare), api, accountID, tunnelID, tunnelSecret, cloudflare.Spec.CredentialsRecovery)
 	if err != nil {
-		return "", "", "", err
-	}
-	for _, v := range tunnels {
-		fmt.Println("v.name:", v.Name, " tunnelsecret", v.Secret, "status:", v.Status, "DeletedAt", v.DeletedAt)
-		if v.Name == tunnelName && v.DeletedAt == nil {
-			return v.ID, v.Secret, accountID, nil
-		}
+		r.eventsFor(cloudflare).warning(eventTunnelError, "Failed to prepare the credentials of tunnel %s: %v", tunnelID, err)
 	}
-
-	params := cloudflare.TunnelCreateParams{
-		Name:   tunnelName,
-		Secret: tunnelSecret,
-		// Indicates if this is a locally or remotely configured tunnel "local" or "cloudflare"
-		ConfigSrc: "local",
+	// CredentialsUnavailable は TunnelReady と並べて、credentials.json を用意できない理由を Tunnel リソースと同じ形で記録する
+	changed := meta.SetStatusCondition(&cloudflare.Status.Conditions, tunnelReadyCondition(tunnelID, err, cloudflare.Generation))
+	if cond, ok := tunnelCredentialsCondition(err, cloudflare.Generation); ok {
+		changed = meta.SetStatusCondition(&cloudflare.Status.Conditions, cond) || changed
 	}
-
-	tunnel, err := api.CreateTunnel(ctx, rc, params)
-	if err != nil {
-		return "", "", "", err
-
+	if changed {
+		if updateErr := r.Status().Update(ctx, cloudflare); updateErr != nil {
+			return updateErr
+		}
 	}
-	fmt.Println("aaaaaaaaaaaa")
-	return tunnel.ID, tunnelSecret, accountID, nil
+	return err
 }
+
 func (r *CloudflareReconciler) deleteTunnel(ctx context.Context, crf cloudflarev1beta1.Cloudflare) error {
 	logger := log.FromContext(ctx)
-	apiToken, accountID, err := r.getAPITokenFromSecret(ctx)
-	if err != nil {
-		return err
-	}
-	api, err := cf.NewWithAPIToken(apiToken)
-	if err != nil {
-		return fmt.Errorf("failed to create Cloudflare API client: %w", err)
+	// Tunnel を作成する前に削除された場合は、削除するものがない
+	if cloudflareTunnelID(&crf) == "" {
+		return nil
 	}
-
-	rc := cloudflare.AccountIdentifier(accountID)
-
-	err = api.CleanupTunnelConnections(ctx, rc, crf.Annotations["cloudflare.io/tunnel-id"])
+	api, accountID, err := r.cloudflareAPI(ctx, &crf)
 	if err != nil {
 		return err
 	}
-	err = api.DeleteTunnel(ctx, rc, crf.Annotations["cloudflare.io/tunnel-id"])
+
+	err = removeTunnel(ctx, api, accountID, cloudflareTunnelID(&crf))
 	if err != nil {
+		r.eventsFor(&crf).warning(eventTunnelError, "Failed to delete tunnel %s: %v", cloudflareTunnelID(&crf), err)
 		return err
 	}
 
-	logger.Info("DNS record deleted", "tunnelID", crf.Annotations["cloudflare.io/tunnel-id"])
+	logger.Info("tunnel deleted", "tunnelID", cloudflareTunnelID(&crf))
+	r.eventsFor(&crf).normal(eventTunnelDeleted, "Deleted tunnel %s", cloudflareTunnelID(&crf))
 	return nil
 }
@@ -18,21 +18,36 @@ package controller
 
 import (
 	"context"
+	"slices"
+	"time"
 
+	cf "github.com/cloudflare/cloudflare-go"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi/fake"
+	"gopkg.in/yaml.v3"
 )
 
 var _ = Describe("Cloudflare Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
+		const tunnelName = "test-tunnel"
 		// const testNamespace = "test"
 
 		ctx := context.Background()
@@ -43,9 +58,42 @@ var _ = Describe("Cloudflare Controller", func() {
 		}
 		cloudflare := &cloudflarev1beta1.Cloudflare{}
 
+		var (
+			fakeAPI              *fake.API
+			zoneID               string
+			recorder             *record.FakeRecorder
+			controllerReconciler *CloudflareReconciler
+		)
+
 		BeforeEach(func() {
+			fakeAPI = fake.NewAPI()
+			zoneID = fakeAPI.AddZone("widgetcorp.tech")
+			recorder = record.NewFakeRecorder(100)
+			controllerReconciler = &CloudflareReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				APIFactory: fakeAPI.Factory(),
+				Recorder:   recorder,
+			}
+
+			By("creating the Cloudflare API token Secret")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cloudflare-api-token",
+					Namespace: "default",
+				},
+				StringData: map[string]string{
+					"apiToken":   "test-token",
+					"account_id": "test-account",
+				},
+			}
+			err := k8sClient.Create(ctx, secret)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
 			By("creating the custom resource for the Kind Cloudflare")
-			err := k8sClient.Get(ctx, typeNamespacedName, cloudflare)
+			err = k8sClient.Get(ctx, typeNamespacedName, cloudflare)
 			if err != nil && errors.IsNotFound(err) {
 				resource := &cloudflarev1beta1.Cloudflare{
 					ObjectMeta: metav1.ObjectMeta{
@@ -53,6 +101,8 @@ var _ = Describe("Cloudflare Controller", func() {
 						Namespace: "default",
 					},
 					Spec: cloudflarev1beta1.CloudflareSpec{
+						TunnelName: tunnelName,
+						Replicas:   1,
 						Ingress: []cloudflarev1beta1.IngressRule{
 							{
 								Hostname: "gitlab.widgetcorp.tech",
@@ -79,24 +129,1145 @@ var _ = Describe("Cloudflare Controller", func() {
 			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &cloudflarev1beta1.Cloudflare{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				return
+			}
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance Cloudflare")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
 		})
+
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
-			controllerReconciler := &CloudflareReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("checking the tunnel and DNS records in Cloudflare")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			tunnelID := cloudflare.Status.TunnelID
+			Expect(tunnelID).NotTo(BeEmpty())
+			tunnel, ok := fakeAPI.Tunnel(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(tunnel.Name).To(Equal(tunnelName))
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(2))
+			for _, rec := range records {
+				Expect(rec.Content).To(Equal(tunnelID + ".cfargotunnel.com"))
 			}
 
+			By("checking the owner records of the DNS records")
+			owners := dnsRecordsOfType(fakeAPI, zoneID, "TXT")
+			Expect(owners).To(HaveLen(2))
+			for _, rec := range owners {
+				owner, ok := parseDNSOwner(rec.Content)
+				Expect(ok).To(BeTrue())
+				Expect(owner.ID).To(Equal(DefaultClusterID + "/" + string(cloudflare.UID)))
+				Expect(owner.Resource).To(Equal("default/" + resourceName))
+			}
+			Expect(meta.IsStatusConditionFalse(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDNSRecordConflict)).To(BeTrue())
+
+			By("checking the status of the resource")
+			Expect(cloudflare.Status.TunnelName).To(Equal(tunnelName))
+			Expect(cloudflare.Status.ObservedGeneration).To(Equal(cloudflare.Generation))
+			Expect(cloudflare.Status.Replicas).To(Equal(int32(1)))
+			Expect(cloudflare.Status.Hostnames).To(Equal([]cloudflarev1beta1.HostnameStatus{
+				{Hostname: "gitlab.widgetcorp.tech", Zone: "widgetcorp.tech", ZoneID: zoneID, RecordID: records[1].ID, State: cloudflarev1beta1.DNSRecordSynced},
+				{Hostname: "gitlab-ssh.widgetcorp.tech", Zone: "widgetcorp.tech", ZoneID: zoneID, RecordID: records[0].ID, State: cloudflarev1beta1.DNSRecordSynced},
+			}))
+
+			By("checking the recorded events")
+			events := drainEvents(recorder)
+			Expect(events).To(ContainElement("Normal TunnelCreated Created tunnel " + tunnelName + " (" + tunnelID + ")"))
+			Expect(events).To(ContainElement("Normal DNSRecordCreated Created CNAME gitlab.widgetcorp.tech pointing to " + tunnelID + ".cfargotunnel.com"))
+			Expect(events).To(ContainElement(HavePrefix("Normal ConfigUpdated ConfigMap cloudflare-" + resourceName)))
+
+			By("checking the metrics of the resource")
+			Expect(testutil.ToFloat64(managedTunnelsGauge.WithLabelValues("default", resourceName))).To(Equal(1.0))
+			Expect(testutil.ToFloat64(managedHostnamesGauge.WithLabelValues("default", resourceName))).To(Equal(2.0))
+			Expect(testutil.ToFloat64(managedDNSRecordsGauge.WithLabelValues("default", resourceName))).To(Equal(2.0))
+			Expect(testutil.ToFloat64(outOfSyncDNSRecordsGauge.WithLabelValues("default", resourceName))).To(Equal(0.0))
+		})
+
+		It("should report each condition and become Ready once the Deployment is available", func() {
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("checking the conditions before cloudflared becomes available")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			for _, conditionType := range []string{
+				cloudflarev1beta1.TypeCredentialsReady,
+				cloudflarev1beta1.TypeCloudflareTunnelReady,
+				cloudflarev1beta1.TypeConfigReady,
+				cloudflarev1beta1.TypeDNSSynced,
+			} {
+				cond := meta.FindStatusCondition(cloudflare.Status.Conditions, conditionType)
+				Expect(cond).NotTo(BeNil(), conditionType)
+				Expect(cond.Status).To(Equal(metav1.ConditionTrue), conditionType)
+				Expect(cond.ObservedGeneration).To(Equal(cloudflare.Generation), conditionType)
+			}
+			cond := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDeploymentAvailable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("ReplicasUnavailable"))
+			ready := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeCloudflareReady)
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("ReplicasUnavailable"))
+			Expect(ready.Message).To(HavePrefix(cloudflarev1beta1.TypeDeploymentAvailable + ": "))
+			Expect(meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeCloudflareViewAvailable)).To(BeNil())
+
+			By("making the Deployment available")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, dep)).To(Succeed())
+			dep.Status.Replicas = 1
+			dep.Status.ReadyReplicas = 1
+			dep.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDeploymentAvailable)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(cloudflare.Status.Conditions, cloudflarev1beta1.TypeCloudflareReady)).To(BeTrue())
+			Expect(cloudflare.Status.ReadyReplicas).To(Equal(int32(1)))
+		})
+
+		It("should poll the health of the tunnel from Cloudflare", func() {
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(DefaultTunnelHealthInterval))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(cloudflare.Status.Health).To(Equal("inactive"))
+			Expect(meta.IsStatusConditionFalse(cloudflare.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)).To(BeTrue())
+
+			By("connecting cloudflared to the tunnel")
+			fakeAPI.SetTunnelConnections(cloudflare.Status.TunnelID, []cf.TunnelConnection{
+				{ColoName: "nrt01", ClientVersion: "2025.1.0"},
+				{ColoName: "kix01", ClientVersion: "2025.1.0"},
+			})
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(cloudflare.Status.TunnelHealth).To(Equal(cloudflarev1beta1.TunnelHealth{
+				Health:      "healthy",
+				Connections: 2,
+				Colos:       []string{"kix01", "nrt01"},
+				Versions:    []string{"2025.1.0"},
+			}))
+			cond := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Message).To(Equal("tunnel is healthy with 2 connections to kix01, nrt01"))
+		})
+
+		It("should not modify DNS records owned by others and report the conflict", func() {
+			rc := &cf.ResourceContainer{Identifier: zoneID}
+			_, err := fakeAPI.CreateDNSRecord(ctx, rc, cf.CreateDNSRecordParams{
+				Type: "CNAME", Name: "gitlab.widgetcorp.tech", Content: "elsewhere.example.com",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = fakeAPI.CreateDNSRecord(ctx, rc, cf.CreateDNSRecordParams{
+				Type: "CNAME", Name: "gitlab-ssh.widgetcorp.tech", Content: "other-tunnel.cfargotunnel.com",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			other := dnsOwner{ID: "other-cluster/uid", Resource: "default/other"}
+			_, err = fakeAPI.CreateDNSRecord(ctx, rc, cf.CreateDNSRecordParams{
+				Type: "TXT", Name: registryName("gitlab-ssh.widgetcorp.tech"), Content: other.txt(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("checking that the foreign records are untouched")
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Content).To(Equal("other-tunnel.cfargotunnel.com"))
+			Expect(records[1].Content).To(Equal("elsewhere.example.com"))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "TXT")).To(HaveLen(1))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cond := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDNSRecordConflict)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Message).To(ContainSubstring("gitlab.widgetcorp.tech already has a CNAME to elsewhere.example.com"))
+			Expect(cond.Message).To(ContainSubstring("gitlab-ssh.widgetcorp.tech is owned by default/other (other-cluster/uid)"))
+			Expect(cloudflare.Status.Hostnames).To(HaveLen(2))
+			for _, hostname := range cloudflare.Status.Hostnames {
+				Expect(hostname.State).To(Equal(cloudflarev1beta1.DNSRecordConflict))
+				Expect(hostname.RecordID).To(BeEmpty())
+			}
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DNSRecordConflict Left the DNS record of gitlab.widgetcorp.tech untouched")))
+			Expect(testutil.ToFloat64(managedDNSRecordsGauge.WithLabelValues("default", resourceName))).To(Equal(0.0))
+			Expect(testutil.ToFloat64(outOfSyncDNSRecordsGauge.WithLabelValues("default", resourceName))).To(Equal(2.0))
+
+			By("checking that an unchanged conflict is not reported again")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(recorder)).NotTo(ContainElement(HavePrefix("Warning DNSRecordConflict")))
+
+			By("checking that deleting the resource keeps the foreign records")
+			Expect(k8sClient.Delete(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(2))
+		})
+
+		It("should recreate the credentials Secret from the tunnel token when it is deleted", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+
+			By("checking the Secret lives next to the resource and is owned by it")
+			secretKey := types.NamespacedName{Name: "cloudflare-" + tunnelName, Namespace: cloudflare.Namespace}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(metav1.IsControlledBy(secret, cloudflare)).To(BeTrue())
+			credentials := secret.Data["credentials.json"]
+
+			By("deleting the Secret and reconciling again")
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			recreated := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretKey, recreated)).To(Succeed())
+			Expect(recreated.Data["credentials.json"]).To(MatchJSON(credentials))
+		})
+
+		It("should push the ingress rules to Cloudflare when the configuration is remote", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			connectorKey := types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, connectorKey, &corev1.ConfigMap{})).To(Succeed())
+
+			By("switching to the remote configuration")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.ConfigSource = cloudflarev1beta1.ConfigSourceCloudflare
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, connectorKey, &corev1.ConfigMap{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			tunnelID := cloudflare.Status.TunnelID
+			config, version, ok := fakeAPI.TunnelConfiguration(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal(1))
+			Expect(config.Ingress[0].Hostname).To(Equal("gitlab.widgetcorp.tech"))
+			Expect(config.Ingress[len(config.Ingress)-1].Service).To(Equal("http_status:404"))
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, connectorKey, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Args).To(ContainElement("--token"))
+			Expect(dep.Spec.Template.Spec.Volumes).To(BeEmpty())
+			template := dep.Spec.Template.DeepCopy()
+
+			By("changing the ingress rules without rolling the pods")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress = cloudflare.Spec.Ingress[1:]
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			config, version, _ = fakeAPI.TunnelConfiguration(tunnelID)
+			Expect(version).To(Equal(2))
+			Expect(config.Ingress[0].Hostname).To(Equal("gitlab-ssh.widgetcorp.tech"))
+			Expect(k8sClient.Get(ctx, connectorKey, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(Equal(template.Annotations))
+		})
+
+		It("should not push the remote configuration again when Cloudflare fills in the defaults", func() {
+			fakeAPI.FillConfigDefaults(true)
+			DeferCleanup(fakeAPI.FillConfigDefaults, false)
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.ConfigSource = cloudflarev1beta1.ConfigSourceCloudflare
+			cloudflare.Spec.Ingress[0].OriginRequest = &cloudflarev1beta1.OriginRequest{
+				ConnectTimeout: &metav1.Duration{Duration: 10 * time.Second},
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			tunnelID := cloudflare.Status.TunnelID
+			config, version, ok := fakeAPI.TunnelConfiguration(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal(1))
+			Expect(config.Ingress[0].OriginRequest.ConnectTimeout.Duration).To(Equal(10 * time.Second))
+			Expect(config.Ingress[1].OriginRequest.ConnectTimeout.Duration).To(Equal(30 * time.Second))
+
+			By("reconciling again with the configuration Cloudflare returned")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, version, _ = fakeAPI.TunnelConfiguration(tunnelID)
+			Expect(version).To(Equal(1))
+		})
+
+		It("should render the global and per-rule originRequest settings into config.yaml", func() {
+			By("setting originRequest globally and overriding it on one rule")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.OriginRequest = &cloudflarev1beta1.OriginRequest{
+				ConnectTimeout: &metav1.Duration{Duration: 10 * time.Second},
+				NoTLSVerify:    ptr.To(true),
+			}
+			cloudflare.Spec.Ingress[0].OriginRequest = &cloudflarev1beta1.OriginRequest{
+				NoTLSVerify:    ptr.To(false),
+				HTTPHostHeader: ptr.To("gitlab.internal"),
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, cm)).To(Succeed())
+			config := CloudflareConfig{}
+			Expect(yaml.Unmarshal([]byte(cm.Data["config.yaml"]), &config)).To(Succeed())
+			Expect(config.Ingress[0].OriginRequest).To(Equal(&OriginRequestConfig{
+				ConnectTimeout: "10s",
+				NoTLSVerify:    ptr.To(false),
+				HTTPHostHeader: "gitlab.internal",
+			}))
+			Expect(config.Ingress[1].OriginRequest).To(Equal(&OriginRequestConfig{
+				ConnectTimeout: "10s",
+				NoTLSVerify:    ptr.To(true),
+			}))
+		})
+
+		It("should render path rules and end the ingress with the configured fallback", func() {
+			By("replacing the trailing catch-all rule with a path rule and a fallback Service")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress = []cloudflarev1beta1.IngressRule{
+				{Hostname: "gitlab.widgetcorp.tech", Path: "^/api/", Service: "http://gitlab-api:8080"},
+				{Hostname: "gitlab.widgetcorp.tech", Service: "http://localhost:80"},
+			}
+			cloudflare.Spec.Fallback = &cloudflarev1beta1.FallbackService{
+				ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "default-backend", Port: intstr.FromString("http")},
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			backend := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "default-backend", Namespace: "default"},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "http", Port: 8080}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, backend)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, backend)
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, cm)).To(Succeed())
+			config := CloudflareConfig{}
+			Expect(yaml.Unmarshal([]byte(cm.Data["config.yaml"]), &config)).To(Succeed())
+			Expect(config.Ingress).To(HaveLen(3))
+			Expect(config.Ingress[0].Path).To(Equal("^/api/"))
+			Expect(config.Ingress[2]).To(Equal(IngressRule{Service: "http://default-backend.default.svc:8080"}))
+		})
+
+		It("should end the ingress with a rule for any hostname without a DNS record for it", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress = []cloudflarev1beta1.IngressRule{
+				{Hostname: "gitlab.widgetcorp.tech", Service: "http://localhost:80"},
+				{Hostname: "*", Service: "http_status:403"},
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, cm)).To(Succeed())
+			config := CloudflareConfig{}
+			Expect(yaml.Unmarshal([]byte(cm.Data["config.yaml"]), &config)).To(Succeed())
+			Expect(config.Ingress).To(Equal([]IngressRule{
+				{Hostname: "gitlab.widgetcorp.tech", Service: "http://localhost:80"},
+				{Hostname: "*", Service: "http_status:403"},
+			}))
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Name).To(Equal("gitlab.widgetcorp.tech"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			for _, h := range cloudflare.Status.Hostnames {
+				Expect(h.Hostname).NotTo(Equal("*"))
+			}
+		})
+
+		It("should report BackendNotFound until the referenced Service exists", func() {
+			By("pointing a rule at a Service that does not exist")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress[0].Service = ""
+			cloudflare.Spec.Ingress[0].ServiceRef = &cloudflarev1beta1.ServiceReference{
+				Name: "gitlab",
+				Port: intstr.FromString("web"),
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(cloudflare.Status.Conditions, cloudflarev1beta1.TypeBackendNotFound)).To(BeTrue())
+			cm := &corev1.ConfigMap{}
+			connectorKey := types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}
+			Expect(k8sClient.Get(ctx, connectorKey, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("http_status:503"))
+
+			By("creating the Service with the named port")
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "gitlab", Namespace: "default"},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "web", Port: 8181}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, svc)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(cloudflare.Status.Conditions, cloudflarev1beta1.TypeBackendNotFound)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, connectorKey, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("service: http://gitlab.default.svc:8181"))
+		})
+
+		It("should apply the DNS settings and restore records that drifted from them", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.DNS = &cloudflarev1beta1.DNSSettings{
+				Proxied: ptr.To(false),
+				TTL:     ptr.To(int32(300)),
+				Comment: "managed by cloudflared-operator",
+				Tags:    []string{"env:test"},
+			}
+			cloudflare.Spec.Ingress[1].DNS = &cloudflarev1beta1.DNSSettings{Proxied: ptr.To(true)}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(2))
+			// gitlab-ssh.widgetcorp.tech overrides proxied, so its TTL is automatic
+			Expect(records[0].Name).To(Equal("gitlab-ssh.widgetcorp.tech"))
+			Expect(*records[0].Proxied).To(BeTrue())
+			Expect(records[0].TTL).To(Equal(1))
+			Expect(records[0].Comment).To(Equal("managed by cloudflared-operator"))
+			Expect(records[1].Name).To(Equal("gitlab.widgetcorp.tech"))
+			Expect(*records[1].Proxied).To(BeFalse())
+			Expect(records[1].TTL).To(Equal(300))
+			Expect(records[1].Tags).To(Equal([]string{"env:test"}))
+
+			By("changing the record outside of the operator")
+			_, err = fakeAPI.UpdateDNSRecord(ctx, &cf.ResourceContainer{Identifier: zoneID}, cf.UpdateDNSRecordParams{
+				ID:      records[1].ID,
+				Proxied: ptr.To(true),
+				TTL:     1,
+				Comment: ptr.To(""),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			records = dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(*records[1].Proxied).To(BeFalse())
+			Expect(records[1].TTL).To(Equal(300))
+			Expect(records[1].Comment).To(Equal("managed by cloudflared-operator"))
+
+			By("checking that the drift was reported as corrected")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			drifted := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted)
+			Expect(drifted).NotTo(BeNil())
+			Expect(drifted.Status).To(Equal(metav1.ConditionFalse))
+			Expect(drifted.Reason).To(Equal("DriftCorrected"))
+			Expect(drifted.Message).To(ContainSubstring("CNAME gitlab.widgetcorp.tech were changed outside the operator"))
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DriftCorrected the TTL, proxy status, comment or tags of CNAME gitlab.widgetcorp.tech")))
+		})
+
+		It("should only report tunnels and DNS records deleted outside the operator when driftPolicy is Report", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.DriftPolicy = cloudflarev1beta1.DriftPolicyReport
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			tunnelID := cloudflare.Status.TunnelID
+			Expect(meta.IsStatusConditionFalse(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted)).To(BeTrue())
+
+			By("deleting a DNS record outside of the operator")
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(2))
+			Expect(fakeAPI.DeleteDNSRecord(ctx, &cf.ResourceContainer{Identifier: zoneID}, records[1].ID)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(DefaultTunnelHealthInterval))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(1))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(cloudflare.Status.Hostnames[0].State).To(Equal(cloudflarev1beta1.DNSRecordDrifted))
+			Expect(cloudflare.Status.Hostnames[0].Message).To(Equal("CNAME gitlab.widgetcorp.tech was deleted outside the operator"))
+			Expect(cloudflare.Status.Hostnames[1].State).To(Equal(cloudflarev1beta1.DNSRecordSynced))
+			drifted := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted)
+			Expect(drifted).NotTo(BeNil())
+			Expect(drifted.Status).To(Equal(metav1.ConditionTrue))
+			Expect(drifted.Reason).To(Equal("DriftDetected"))
+			Expect(meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDNSSynced).Reason).To(Equal("DNSRecordDrifted"))
+			Expect(testutil.ToFloat64(outOfSyncDNSRecordsGauge.WithLabelValues("default", resourceName))).To(Equal(1.0))
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DriftDetected CNAME gitlab.widgetcorp.tech was deleted outside the operator")))
+
+			By("reconciling again without recording the same drift twice")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(1))
+			Expect(drainEvents(recorder)).NotTo(ContainElement(HavePrefix("Warning DriftDetected")))
+
+			By("deleting the tunnel outside of the operator")
+			Expect(fakeAPI.DeleteTunnel(ctx, cf.AccountIdentifier("test-account"), tunnelID)).To(Succeed())
+			result, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(DefaultResyncPeriod))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(cloudflare.Status.TunnelID).To(Equal(tunnelID))
+			tunnelReady := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady)
+			Expect(tunnelReady.Status).To(Equal(metav1.ConditionFalse))
+			Expect(tunnelReady.Reason).To(Equal("TunnelDrifted"))
+			Expect(meta.IsStatusConditionTrue(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(cloudflare.Status.Conditions, cloudflarev1beta1.TypeCloudflareReady)).To(BeTrue())
+
+			By("switching driftPolicy to Correct")
+			cloudflare.Spec.DriftPolicy = cloudflarev1beta1.DriftPolicyCorrect
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			Expect(cloudflare.Status.TunnelID).NotTo(Equal(tunnelID))
+			records = dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(2))
+			for _, rec := range records {
+				Expect(rec.Content).To(Equal(cloudflare.Status.TunnelID + ".cfargotunnel.com"))
+			}
+			drifted = meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted)
+			Expect(drifted.Status).To(Equal(metav1.ConditionFalse))
+			Expect(drifted.Reason).To(Equal("DriftCorrected"))
+			Expect(drifted.Message).To(Equal("tunnel " + tunnelName + " (" + tunnelID + ") was deleted outside the operator"))
+		})
+
+		It("should hand the hostnames over to external-dns or leave them alone", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.DNSManagement = cloudflarev1beta1.DNSManagementExternalDNS
+			cloudflare.Spec.Ingress[1].DNSManagement = cloudflarev1beta1.DNSManagementDisabled
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("checking that no DNS record was created through the Cloudflare API")
+			Expect(fakeAPI.DNSRecords(zoneID)).To(BeEmpty())
+
+			By("checking the DNSEndpoint for external-dns")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			endpoint := &unstructured.Unstructured{}
+			endpoint.SetGroupVersionKind(dnsEndpointGVK)
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, endpoint)).To(Succeed())
+			Expect(metav1.IsControlledBy(endpoint, cloudflare)).To(BeTrue())
+			endpoints, _, err := unstructured.NestedSlice(endpoint.Object, "spec", "endpoints")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(endpoints).To(HaveLen(1))
+			Expect(endpoints[0]).To(HaveKeyWithValue("dnsName", "gitlab.widgetcorp.tech"))
+			Expect(endpoints[0]).To(HaveKeyWithValue("recordType", "CNAME"))
+			Expect(endpoints[0]).To(HaveKeyWithValue("targets", ConsistOf(cloudflare.Status.TunnelID+".cfargotunnel.com")))
+
+			By("switching back to Managed")
+			cloudflare.Spec.DNSManagement = cloudflarev1beta1.DNSManagementManaged
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Name).To(Equal("gitlab.widgetcorp.tech"))
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + resourceName, Namespace: "default"}, endpoint)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should remove DNS records for hostnames dropped from the spec", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("removing a hostname from the ingress rules")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress = cloudflare.Spec.Ingress[1:]
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Name).To(Equal("gitlab-ssh.widgetcorp.tech"))
+			owners := dnsRecordsOfType(fakeAPI, zoneID, "TXT")
+			Expect(owners).To(HaveLen(1))
+			Expect(owners[0].Name).To(Equal(registryName("gitlab-ssh.widgetcorp.tech")))
+		})
+
+		It("should create DNS records in the longest zone of the account or the zone of the rule", func() {
+			subZoneID := fakeAPI.AddZone("dev.widgetcorp.tech")
+			suffixZoneID := fakeAPI.AddZone("example.co.uk")
+			otherZoneID := fakeAPI.AddZone("widgetcorp.net")
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			cloudflare.Spec.Ingress = []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.dev.widgetcorp.tech", Service: "http://localhost:80"},
+				{Hostname: "app.example.co.uk", Service: "http://localhost:80"},
+				{Hostname: "www.dev.widgetcorp.tech", Service: "http://localhost:80", ZoneID: zoneID},
+				{Hostname: "app.widgetcorp.tech", Service: "http://localhost:80", ZoneID: otherZoneID},
+				{Hostname: "api.widgetcorp.tech", Service: "http://localhost:80", ZoneID: "not-in-the-account"},
+			}
+			Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeAPI.DNSRecords(otherZoneID)).To(BeEmpty())
+			for id, name := range map[string]string{
+				subZoneID:    "app.dev.widgetcorp.tech",
+				suffixZoneID: "app.example.co.uk",
+				zoneID:       "www.dev.widgetcorp.tech",
+			} {
+				records := dnsRecordsOfType(fakeAPI, id, "CNAME")
+				Expect(records).To(HaveLen(1))
+				Expect(records[0].Name).To(Equal(name))
+			}
+
+			By("rejecting a zoneID that is not in the account or does not contain the hostname")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			messages := map[string]string{}
+			for _, h := range cloudflare.Status.Hostnames {
+				if h.State == cloudflarev1beta1.DNSRecordError {
+					messages[h.Hostname] = h.Message
+				}
+			}
+			Expect(messages).To(HaveKeyWithValue("app.widgetcorp.tech", ContainSubstring("does not contain hostname app.widgetcorp.tech")))
+			Expect(messages).To(HaveKeyWithValue("api.widgetcorp.tech", ContainSubstring("zone ID not-in-the-account not found in the account")))
+		})
+
+		It("should delete the tunnel and DNS records when the resource is deleted", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			tunnelID := cloudflare.Status.TunnelID
+
+			By("deleting the resource")
+			Expect(k8sClient.Delete(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeAPI.DNSRecords(zoneID)).To(BeEmpty())
+			tunnel, ok := fakeAPI.Tunnel(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(tunnel.DeletedAt).NotTo(BeNil())
+			events := drainEvents(recorder)
+			Expect(events).To(ContainElement("Normal DNSRecordDeleted Deleted CNAME gitlab.widgetcorp.tech"))
+			Expect(events).To(ContainElement("Normal TunnelDeleted Deleted tunnel " + tunnelID))
+
+			By("checking that the metrics are removed once the resource is gone")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(managedTunnelsGauge.DeleteLabelValues("default", resourceName)).To(BeFalse())
+			err = k8sClient.Get(ctx, typeNamespacedName, cloudflare)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should remove the finalizer when the credentials are deleted before the resource", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cloudflare)).To(Succeed())
+			tunnelID := cloudflare.Status.TunnelID
+
+			By("deleting the API token Secret and then the resource")
+			secret := &corev1.Secret{}
+			secretKey := types.NamespacedName{Name: "cloudflare-api-token", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Create(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace},
+					StringData: map[string]string{"apiToken": "test-token", "account_id": "test-account"},
+				})).To(Succeed())
+			})
+			Expect(k8sClient.Delete(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, typeNamespacedName, cloudflare)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			tunnel, ok := fakeAPI.Tunnel(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(tunnel.DeletedAt).To(BeNil())
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning CleanupSkipped Left the tunnel and DNS records in Cloudflare")))
+		})
+	})
+	Context("When several resources share a Tunnel through tunnelRef", func() {
+		const tunnelResourceName = "shared-tunnel"
+
+		ctx := context.Background()
+
+		tunnelKey := types.NamespacedName{Name: tunnelResourceName, Namespace: "default"}
+		teamA := types.NamespacedName{Name: "team-a", Namespace: "default"}
+		teamB := types.NamespacedName{Name: "team-b", Namespace: "default"}
+
+		var (
+			fakeAPI              *fake.API
+			zoneID               string
+			tunnelReconciler     *TunnelReconciler
+			controllerReconciler *CloudflareReconciler
+		)
+
+		newBoundCloudflare := func(key types.NamespacedName, hostname string) *cloudflarev1beta1.Cloudflare {
+			return &cloudflarev1beta1.Cloudflare{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: cloudflarev1beta1.CloudflareSpec{
+					TunnelRef: &cloudflarev1beta1.TunnelReference{Name: tunnelResourceName},
+					Replicas:  1,
+					Ingress: []cloudflarev1beta1.IngressRule{
+						{Hostname: hostname, Service: "http://localhost:80"},
+					},
+				},
+			}
+		}
+
+		reconcileCloudflare := func(key types.NamespacedName) {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			fakeAPI = fake.NewAPI()
+			zoneID = fakeAPI.AddZone("widgetcorp.tech")
+			tunnelReconciler = &TunnelReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				APIFactory: fakeAPI.Factory(),
+			}
+			controllerReconciler = &CloudflareReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				APIFactory: fakeAPI.Factory(),
+			}
+
+			By("creating the shared Tunnel")
+			tunnel := &cloudflarev1beta1.Tunnel{
+				ObjectMeta: metav1.ObjectMeta{Name: tunnelKey.Name, Namespace: tunnelKey.Namespace},
+				Spec:       cloudflarev1beta1.TunnelSpec{Replicas: 2},
+			}
+			Expect(k8sClient.Create(ctx, tunnel)).To(Succeed())
+			_, err := tunnelReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: tunnelKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("binding two Cloudflare resources to the Tunnel")
+			Expect(k8sClient.Create(ctx, newBoundCloudflare(teamA, "a.widgetcorp.tech"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, newBoundCloudflare(teamB, "b.widgetcorp.tech"))).To(Succeed())
+		})
+
+		AfterEach(func() {
+			for _, key := range []types.NamespacedName{teamA, teamB} {
+				resource := &cloudflarev1beta1.Cloudflare{}
+				err := k8sClient.Get(ctx, key, resource)
+				if errors.IsNotFound(err) {
+					continue
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+				reconcileCloudflare(key)
+			}
+
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			err := k8sClient.Get(ctx, tunnelKey, tunnel)
+			if errors.IsNotFound(err) {
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Delete(ctx, tunnel)).To(Succeed())
+			_, err = tunnelReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: tunnelKey})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should merge the ingress rules into one connector owned by the Tunnel", func() {
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamB)
+
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+
+			By("checking the merged cloudflared config")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("a.widgetcorp.tech"))
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("b.widgetcorp.tech"))
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring(tunnel.Status.TunnelID))
+			Expect(metav1.IsControlledBy(cm, tunnel)).To(BeTrue())
+
+			By("checking the shared Deployment")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, dep)).To(Succeed())
+			Expect(*dep.Spec.Replicas).To(Equal(int32(2)))
+
+			By("checking that no tunnel was created per resource")
+			Expect(k8sClient.Get(ctx, teamA, &cloudflarev1beta1.Cloudflare{})).To(Succeed())
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-" + teamA.Name, Namespace: "default"}, &appsv1.Deployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(2))
+		})
+
+		It("should keep the other resource's hostnames when one resource is deleted", func() {
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamB)
+
+			By("deleting team-a")
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Name).To(Equal("b.widgetcorp.tech"))
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).NotTo(ContainSubstring("a.widgetcorp.tech"))
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("b.widgetcorp.tech"))
+		})
+
+		It("should leave a hostname to the resource that claimed it first", func() {
+			By("adding team-a's hostname to team-b")
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamB, resource)).To(Succeed())
+			resource.Spec.Ingress = append(resource.Spec.Ingress, cloudflarev1beta1.IngressRule{Hostname: "a.widgetcorp.tech", Service: "http://team-b:80"})
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamB)
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).NotTo(ContainSubstring("http://team-b:80"))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(2))
+
+			Expect(k8sClient.Get(ctx, teamB, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("HostnameConflict"))
+			Expect(cond.Message).To(ContainSubstring("a.widgetcorp.tech is already used by default/team-a"))
+
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			cond = meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		})
+
+		It("should leave rules without a hostname or overlapping another resource's hostname out of the Tunnel", func() {
+			By("adding a path rule without a hostname and a wildcard over team-a's hostname to team-b")
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamB, resource)).To(Succeed())
+			resource.Spec.Ingress = append(resource.Spec.Ingress,
+				cloudflarev1beta1.IngressRule{Path: "^/admin", Service: "http://team-b-admin:80"},
+				cloudflarev1beta1.IngressRule{Hostname: "*.widgetcorp.tech", Service: "http://team-b-wildcard:80"},
+			)
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamB)
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).NotTo(ContainSubstring("team-b-admin"))
+			Expect(cm.Data["config.yaml"]).NotTo(ContainSubstring("team-b-wildcard"))
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("b.widgetcorp.tech"))
+
+			Expect(k8sClient.Get(ctx, teamB, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("HostnameRequired"))
+			Expect(cond.Message).To(ContainSubstring("rule for path ^/admin has no hostname"))
+			Expect(cond.Message).To(ContainSubstring("hostname *.widgetcorp.tech overlaps a.widgetcorp.tech used by default/team-a"))
+
+			By("removing the rule without a hostname")
+			resource.Spec.Ingress = slices.DeleteFunc(resource.Spec.Ingress, func(rule cloudflarev1beta1.IngressRule) bool {
+				return rule.Hostname == ""
+			})
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamB)
+
+			Expect(k8sClient.Get(ctx, teamB, resource)).To(Succeed())
+			cond = meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+			Expect(cond.Reason).To(Equal("HostnameConflict"))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(2))
+		})
+
+		It("should only admit resources from namespaces the Tunnel allows", func() {
+			teamC := types.NamespacedName{Name: "team-c", Namespace: "team-c"}
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: teamC.Namespace}})).To(Succeed())
+			other := newBoundCloudflare(teamC, "c.widgetcorp.tech")
+			other.Spec.TunnelRef.Namespace = "default"
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+				reconcileCloudflare(teamC)
+			})
+			reconcileCloudflare(teamC)
+
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamC, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal("NamespaceNotAllowed"))
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeConfigReady).Reason).To(Equal("NamespaceNotAllowed"))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(BeEmpty())
+
+			By("allowing the namespace on the Tunnel")
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+			tunnel.Spec.AllowedNamespaces = []string{teamC.Namespace}
+			Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+			reconcileCloudflare(teamC)
+
+			Expect(k8sClient.Get(ctx, teamC, resource)).To(Succeed())
+			cond = meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloudflare-tunnel-" + tunnelResourceName, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data["config.yaml"]).To(ContainSubstring("c.widgetcorp.tech"))
+		})
+
+		It("should resolve the credentials of the Tunnel in the Tunnel's namespace", func() {
+			teamD := types.NamespacedName{Name: "team-d", Namespace: "team-d"}
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: teamD.Namespace}})).To(Succeed())
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-tunnel-credentials", Namespace: tunnelKey.Namespace},
+				StringData: map[string]string{"apiToken": "token", "account_id": "account"},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+
+			By("pointing the Tunnel at a Secret in its own namespace")
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+			tunnel.Spec.SecretRef = &cloudflarev1beta1.SecretReference{Name: secret.Name}
+			tunnel.Spec.AllowedNamespaces = []string{teamD.Namespace}
+			Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+
+			other := newBoundCloudflare(teamD, "d.widgetcorp.tech")
+			other.Spec.TunnelRef.Namespace = tunnelKey.Namespace
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+				reconcileCloudflare(teamD)
+			})
+			reconcileCloudflare(teamD)
+
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamD, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeCredentialsReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(resource.Status.TunnelCredentials).To(Equal(&cloudflarev1beta1.InheritedCredentials{
+				Namespace:         tunnelKey.Namespace,
+				CredentialsSource: cloudflarev1beta1.CredentialsSource{SecretRef: &cloudflarev1beta1.SecretReference{Name: secret.Name}},
+			}))
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(1))
+		})
+
+		It("should report the tunnel of the Tunnel deleted outside the operator without waiting for the cache", func() {
+			By("caching the Cloudflare API for longer than the test")
+			factory := cfapi.WithCache(fakeAPI.Factory(), time.Hour)
+			tunnelReconciler.APIFactory = factory
+			controllerReconciler.APIFactory = factory
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			resource.Spec.DriftPolicy = cloudflarev1beta1.DriftPolicyReport
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamA)
+
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			tunnelID := resource.Status.TunnelID
+			Expect(tunnelID).NotTo(BeEmpty())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, cloudflarev1beta1.TypeDrifted)).To(BeTrue())
+
+			By("changing the DNS record outside of the operator")
+			records := dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			_, err := fakeAPI.UpdateDNSRecord(ctx, &cf.ResourceContainer{Identifier: zoneID}, cf.UpdateDNSRecordParams{ID: records[0].ID, Content: "elsewhere.example.com"})
+			Expect(err).NotTo(HaveOccurred())
+			reconcileCloudflare(teamA)
+
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(resource.Status.Hostnames[0].State).To(Equal(cloudflarev1beta1.DNSRecordDrifted))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, cloudflarev1beta1.TypeDrifted)).To(BeTrue())
+
+			By("deleting the tunnel outside of the operator")
+			Expect(fakeAPI.DeleteTunnel(ctx, cf.AccountIdentifier("test-account"), tunnelID)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: teamA})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(DefaultResyncPeriod))
+
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(resource.Status.TunnelID).To(Equal(tunnelID))
+			tunnelReady := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady)
+			Expect(tunnelReady.Status).To(Equal(metav1.ConditionFalse))
+			Expect(tunnelReady.Reason).To(Equal("TunnelDrifted"))
+			drifted := meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeDrifted)
+			Expect(drifted.Status).To(Equal(metav1.ConditionTrue))
+			Expect(drifted.Message).To(Equal("tunnel " + tunnelResourceName + " (" + tunnelID + ") was deleted outside the operator"))
+
+			By("switching driftPolicy to Correct and letting the Tunnel recreate its tunnel")
+			resource.Spec.DriftPolicy = cloudflarev1beta1.DriftPolicyCorrect
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeCloudflareTunnelReady).Reason).To(Equal("TunnelNotReady"))
+
+			_, err = tunnelReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: tunnelKey})
+			Expect(err).NotTo(HaveOccurred())
+			reconcileCloudflare(teamA)
+
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(resource.Status.TunnelID).NotTo(Equal(tunnelID))
+			records = dnsRecordsOfType(fakeAPI, zoneID, "CNAME")
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Content).To(Equal(resource.Status.TunnelID + ".cfargotunnel.com"))
+			drifted = meta.FindStatusCondition(resource.Status.Conditions, cloudflarev1beta1.TypeDrifted)
+			Expect(drifted.Status).To(Equal(metav1.ConditionFalse))
+			Expect(drifted.Reason).To(Equal("DriftCorrected"))
+		})
+
+		It("should delete the resource's DNS records even after the Tunnel is deleted", func() {
+			reconcileCloudflare(teamA)
+			reconcileCloudflare(teamB)
+
+			By("deleting the Tunnel first")
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, tunnel)).To(Succeed())
+			_, err := tunnelReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: tunnelKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, tunnelKey, &cloudflarev1beta1.Tunnel{}))).To(BeTrue())
+
+			By("deleting team-a")
+			resource := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, teamA, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileCloudflare(teamA)
+
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, teamA, &cloudflarev1beta1.Cloudflare{}))).To(BeTrue())
+			for _, rec := range fakeAPI.DNSRecords(zoneID) {
+				Expect(rec.Name).NotTo(ContainSubstring("a.widgetcorp.tech"))
+			}
+			Expect(dnsRecordsOfType(fakeAPI, zoneID, "CNAME")).To(HaveLen(1))
 		})
 	})
 })
+
+// drainEvents は FakeRecorder に記録された Event を全て取り出します。
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// dnsRecordsOfType はゾーン内の指定した種類のレコードを名前順で返します。
+func dnsRecordsOfType(api *fake.API, zoneID, recordType string) []cf.DNSRecord {
+	var records []cf.DNSRecord
+	for _, rec := range api.DNSRecords(zoneID) {
+		if rec.Type == recordType {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
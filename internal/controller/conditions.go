@@ -0,0 +1,231 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// cloudflareReadyConditions は Ready 条件の判定に使う条件で、Reconcile で調整する順に並んでいます。
+var cloudflareReadyConditions = []string{
+	cloudflarev1beta1.TypeCredentialsReady,
+	cloudflarev1beta1.TypeCloudflareTunnelReady,
+	cloudflarev1beta1.TypeConfigReady,
+	cloudflarev1beta1.TypeDeploymentAvailable,
+	cloudflarev1beta1.TypeDNSSynced,
+}
+
+// obsoleteCloudflareConditions は以前のバージョンが Cloudflare リソースに記録していた条件で、Ready の更新時に取り除きます。
+var obsoleteCloudflareConditions = []string{
+	cloudflarev1beta1.TypeCloudflareViewAvailable,
+	cloudflarev1beta1.TypeCloudflareViewDegraded,
+}
+
+// setCondition は Cloudflare リソースに条件を設定し、変化があれば status を更新します。
+func (r *CloudflareReconciler) setCondition(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare, condition metav1.Condition) error {
+	if meta.SetStatusCondition(&cloudflare.Status.Conditions, condition) {
+		return r.Status().Update(ctx, cloudflare)
+	}
+	return nil
+}
+
+// reconcileReady は他の条件から Ready 条件を決めて status を更新します。Reconcile の最後に、エラーで中断した場合も呼びます。
+func (r *CloudflareReconciler) reconcileReady(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare) {
+	changed := false
+	for _, conditionType := range obsoleteCloudflareConditions {
+		changed = meta.RemoveStatusCondition(&cloudflare.Status.Conditions, conditionType) || changed
+	}
+	ready := readyCondition(cloudflare.Status.Conditions, cloudflare.Generation)
+	changed = meta.SetStatusCondition(&cloudflare.Status.Conditions, ready) || changed
+	if !changed {
+		return
+	}
+	if err := r.Status().Update(ctx, cloudflare); err != nil {
+		log.FromContext(ctx).Error(err, "unable to update Ready condition")
+	}
+}
+
+// readyCondition は cloudflareReadyConditions の全てが True なら True の Ready 条件を返します。
+// そうでなければ、最初に満たされていない条件の理由とメッセージを使います。
+func readyCondition(conditions []metav1.Condition, generation int64) metav1.Condition {
+	for _, conditionType := range cloudflareReadyConditions {
+		cond := meta.FindStatusCondition(conditions, conditionType)
+		if cond == nil {
+			return metav1.Condition{
+				Type:               cloudflarev1beta1.TypeCloudflareReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Reconciling",
+				Message:            fmt.Sprintf("%s is not reported yet", conditionType),
+				ObservedGeneration: generation,
+			}
+		}
+		if cond.Status != metav1.ConditionTrue {
+			message := fmt.Sprintf("%s is %s", conditionType, cond.Status)
+			if cond.Message != "" {
+				message = fmt.Sprintf("%s: %s", conditionType, cond.Message)
+			}
+			return metav1.Condition{
+				Type:               cloudflarev1beta1.TypeCloudflareReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             cond.Reason,
+				Message:            message,
+				ObservedGeneration: generation,
+			}
+		}
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeCloudflareReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Ready",
+		ObservedGeneration: generation,
+	}
+}
+
+// tunnelReadyCondition は Tunnel と credentials.json を用意した結果を TunnelReady 条件に変換します。
+func tunnelReadyCondition(tunnelID string, err error, generation int64) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             "TunnelReady",
+			Message:            fmt.Sprintf("tunnel %s is ready", tunnelID),
+			ObservedGeneration: generation,
+		}
+	}
+	reason := "TunnelUnavailable"
+	var credsErr *tunnelCredentialsError
+	if goerrors.As(err, &credsErr) {
+		reason = credsErr.reason
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeCloudflareTunnelReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: generation,
+	}
+}
+
+// configCondition は cloudflared の設定と Deployment を描画した結果を ConfigReady 条件に変換します。
+func configCondition(err error, generation int64) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeConfigReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ConfigApplied",
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeConfigReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ConfigFailed",
+		Message:            err.Error(),
+		ObservedGeneration: generation,
+	}
+}
+
+// sharedConfigCondition は共有 Tunnel の設定を描画した結果を ConfigReady 条件に変換します。
+// 描画できても、ホスト名のないルールを外した場合は HostnameRequired、
+// 他のリソースが先に使っているホスト名のルールを外した場合は HostnameConflict です。
+func sharedConfigCondition(err error, rejected rejectedRules, generation int64) metav1.Condition {
+	cond := configCondition(err, generation)
+	if err != nil || len(rejected.hostless)+len(rejected.conflicts) == 0 {
+		return cond
+	}
+	cond.Status = metav1.ConditionFalse
+	cond.Reason = "HostnameConflict"
+	if len(rejected.hostless) > 0 {
+		cond.Reason = "HostnameRequired"
+	}
+	cond.Message = strings.Join(append(slices.Clone(rejected.hostless), rejected.conflicts...), "; ")
+	return cond
+}
+
+// deploymentCondition は cloudflared の Deployment の利用可能なレプリカ数を DeploymentAvailable 条件に変換します。
+// dep が nil の場合は Deployment が見つからなかったことを表します。
+func deploymentCondition(name string, dep *appsv1.Deployment, replicas int32, generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               cloudflarev1beta1.TypeDeploymentAvailable,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case dep == nil:
+		cond.Reason = "DeploymentNotFound"
+		cond.Message = fmt.Sprintf("Deployment %s not found", name)
+	case replicas == 0:
+		cond.Reason = "ScaledToZero"
+		cond.Message = fmt.Sprintf("Deployment %s has no replicas", name)
+	case dep.Status.AvailableReplicas == 0:
+		cond.Reason = "ReplicasUnavailable"
+		cond.Message = fmt.Sprintf("0/%d replicas of Deployment %s are available", replicas, name)
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "ReplicasAvailable"
+		cond.Message = fmt.Sprintf("%d/%d replicas of Deployment %s are available", dep.Status.AvailableReplicas, replicas, name)
+	}
+	return cond
+}
+
+// dnsSyncedCondition はホスト名ごとの DNS レコードの状態を DNSSynced 条件に変換します。
+// 失敗したホスト名があれば DNSRecordError、外部から変更されたままのホスト名があれば DNSRecordDrifted、
+// 他の所有者のレコードと衝突したホスト名があれば DNSRecordConflict です。
+func dnsSyncedCondition(hostnames []cloudflarev1beta1.HostnameStatus, generation int64) metav1.Condition {
+	var failed, drifted, conflicted []string
+	for _, hostname := range hostnames {
+		switch hostname.State {
+		case cloudflarev1beta1.DNSRecordError:
+			failed = append(failed, fmt.Sprintf("%s: %s", hostname.Hostname, hostname.Message))
+		case cloudflarev1beta1.DNSRecordDrifted:
+			drifted = append(drifted, hostname.Message)
+		case cloudflarev1beta1.DNSRecordConflict:
+			conflicted = append(conflicted, hostname.Message)
+		}
+	}
+	cond := metav1.Condition{
+		Type:               cloudflarev1beta1.TypeDNSSynced,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case len(failed) > 0:
+		cond.Reason = "DNSRecordError"
+		cond.Message = strings.Join(append(append(failed, drifted...), conflicted...), "; ")
+	case len(drifted) > 0:
+		cond.Reason = "DNSRecordDrifted"
+		cond.Message = strings.Join(append(drifted, conflicted...), "; ")
+	case len(conflicted) > 0:
+		cond.Reason = "DNSRecordConflict"
+		cond.Message = strings.Join(conflicted, "; ")
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "DNSRecordsSynced"
+	}
+	return cond
+}
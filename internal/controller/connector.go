@@ -0,0 +1,560 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// cloudflareFinalizerName は Cloudflare リソースの後始末用の finalizer です。
+	cloudflareFinalizerName = "finalizer.cloudflare.laininthewired.github.io"
+
+	// tunnelIDAnnotation は以前のバージョンが Cloudflare リソースの使用中の Tunnel ID を記録していたアノテーションです。
+	// 現在は status.tunnelID に記録し、アノテーションは status.tunnelID が無い場合にだけ読みます。
+	tunnelIDAnnotation = "cloudflare.io/tunnel-id"
+
+	// tunnelRefAnnotation は Cloudflare リソースが最後に紐付いた Tunnel リソース（namespace/name）を記録します。
+	// tunnelRef が付け替えられた時に、以前の Tunnel の設定からルールを外すために使います。
+	tunnelRefAnnotation = "cloudflare.io/tunnel-ref"
+
+	// configHashAnnotation は cloudflared の設定のハッシュです。設定が変わった時だけ Pod を再起動させます。
+	configHashAnnotation = "cloudflare.laininthewired.github.io/config-hash"
+
+	// defaultFallbackService は fallback を指定しない場合に、どのルールにも一致しなかったリクエストに返す応答です。
+	defaultFallbackService = "http_status:404"
+
+	// credentialsFilePath は Tunnel の認証情報 Secret をマウントするパスです。
+	credentialsFilePath = "/etc/cloudflared/creds/credentials.json"
+)
+
+// connector は 1 つの cloudflared Deployment とその設定 ConfigMap を描画するための情報です。
+// owner は ConfigMap と Deployment の所有者で、namespace も owner に揃えます。
+// remote の場合は ConfigMap の代わりに、credentials の API 認証情報で Tunnel の設定 API に ingress ルールを登録します。
+// fallback は rules の末尾に付ける、全てのリクエストに一致するルールの転送先です。
+type connector struct {
+	owner       client.Object
+	name        string
+	tunnelID    string
+	secretName  string
+	replicas    int32
+	labels      map[string]string
+	rules       []IngressRule
+	fallback    string
+	remote      bool
+	credentials cloudflarev1beta1.CredentialsSource
+}
+
+// inlineConnector は tunnel_name を指定した Cloudflare リソース専用の connector を返します。
+func inlineConnector(cf *cloudflarev1beta1.Cloudflare, tunnelID string) connector {
+	return connector{
+		owner:      cf,
+		name:       "cloudflare-" + cf.Name,
+		tunnelID:   tunnelID,
+		secretName: inlineSecretName(cf),
+		replicas:   cf.Spec.Replicas,
+		labels: map[string]string{
+			"app.kubernetes.io/name":       "cloudflare",
+			"app.kubernetes.io/instance":   cf.Name,
+			"app.kubernetes.io/created-by": "cloudflared-operator-controller-manager",
+		},
+		rules:       ingressRules(*cf),
+		fallback:    fallbackService(cf.Spec.Fallback, cf.Namespace),
+		remote:      cf.Spec.ConfigSource == cloudflarev1beta1.ConfigSourceCloudflare,
+		credentials: cf.Spec.CredentialsSource,
+	}
+}
+
+// cloudflareTunnelID は Cloudflare リソースが使用中の Tunnel ID を返します。
+func cloudflareTunnelID(cf *cloudflarev1beta1.Cloudflare) string {
+	if cf.Status.TunnelID != "" {
+		return cf.Status.TunnelID
+	}
+	return cf.Annotations[tunnelIDAnnotation]
+}
+
+// inlineSecretName は tunnel_name で作成した Tunnel の credentials Secret 名です。
+// Secret は Cloudflare リソースと同じ namespace に作成します。
+func inlineSecretName(cf *cloudflarev1beta1.Cloudflare) string {
+	return "cloudflare-" + cf.Spec.TunnelName
+}
+
+// sharedConnector は Tunnel リソースを共有する Cloudflare リソースのルールをまとめた connector を返します。
+// bound は名前順に並んでいる前提で、claimHostnames で Tunnel が許可していないリソース、ホスト名のないルール、
+// 他のリソースが先に使っているホスト名のルールを外します。どのルールにも一致しないリクエストには Tunnel の fallback を使います。
+func sharedConnector(tunnel *cloudflarev1beta1.Tunnel, bound []cloudflarev1beta1.Cloudflare) connector {
+	admitted, _ := claimHostnames(tunnel, bound)
+	seen := map[[2]string]bool{}
+	var rules []IngressRule
+	for _, item := range admitted {
+		for _, rule := range ingressRules(item) {
+			key := [2]string{rule.Hostname, rule.Path}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			rules = append(rules, rule)
+		}
+	}
+	return connector{
+		owner:      tunnel,
+		name:       sharedConnectorName(tunnel),
+		tunnelID:   tunnel.Status.TunnelID,
+		secretName: tunnel.Status.CredentialsSecret,
+		replicas:   tunnel.Spec.Replicas,
+		labels: map[string]string{
+			"app.kubernetes.io/name":       "cloudflare-tunnel",
+			"app.kubernetes.io/instance":   tunnel.Name,
+			"app.kubernetes.io/created-by": "cloudflared-operator-controller-manager",
+		},
+		rules:       rules,
+		fallback:    fallbackService(tunnel.Spec.Fallback, tunnel.Namespace),
+		remote:      tunnel.Spec.ConfigSource == cloudflarev1beta1.ConfigSourceCloudflare,
+		credentials: tunnel.Spec.CredentialsSource,
+	}
+}
+
+// sharedConnectorName は共有 Tunnel の設定の ConfigMap と Deployment の名前です。
+func sharedConnectorName(tunnel *cloudflarev1beta1.Tunnel) string {
+	return "cloudflare-tunnel-" + tunnel.Name
+}
+
+// rejectedRules は共有 Tunnel の設定から外した、1 つの Cloudflare リソースのルールの説明です。
+type rejectedRules struct {
+	// hostless はホスト名のないルールです。
+	hostless []string
+	// conflicts は他のリソースが先に使っているホスト名と重なるルールです。
+	conflicts []string
+}
+
+// hostnameClaim は共有 Tunnel でホスト名を割り当てたリソースです。
+type hostnameClaim struct {
+	owner    types.NamespacedName
+	hostname string
+}
+
+// claimHostnames は共有 Tunnel の設定に載せる Cloudflare リソースとそのルールを決めます。
+// Tunnel が許可していない namespace のリソースは除きます。ホスト名のないルール（パスだけのルールと catch-all）は
+// 他のリソースのホスト名へのリクエストにも一致してしまうため外します。同じホスト名や、ワイルドカードで重なるホスト名を
+// 複数のリソースが使う場合は、先に作成されたリソースにホスト名を割り当て、後のリソースからはそのホスト名のルールを外します。
+// これにより、各リソースのルールはそのリソースに割り当てたホスト名へのリクエストにだけ一致します。
+// 戻り値は bound と同じ順に並べたリソースのコピーと、ルールを外したリソースごとの理由です。
+func claimHostnames(tunnel *cloudflarev1beta1.Tunnel, bound []cloudflarev1beta1.Cloudflare) ([]cloudflarev1beta1.Cloudflare, map[types.NamespacedName]rejectedRules) {
+	var allowed []cloudflarev1beta1.Cloudflare
+	for _, item := range bound {
+		if cfapi.TunnelAllowsNamespace(tunnel, item.Namespace) {
+			allowed = append(allowed, item)
+		}
+	}
+
+	// 作成時刻の順にホスト名を割り当てる。同時刻の場合は bound の順に従う
+	byAge := slices.Clone(allowed)
+	sort.SliceStable(byAge, func(i, j int) bool {
+		return byAge[i].CreationTimestamp.Before(&byAge[j].CreationTimestamp)
+	})
+	var claimed []hostnameClaim
+	// holders はホスト名ごとの、そのホスト名か重なるホスト名を割り当てたリソースです。
+	holders := map[string]hostnameClaim{}
+	for _, item := range byAge {
+		key := client.ObjectKeyFromObject(&item)
+		for _, rule := range item.Spec.Ingress {
+			hostname := normalizeDNSName(rule.Hostname)
+			if _, ok := holders[hostname]; ok || matchesAnyHost(hostname) {
+				continue
+			}
+			holder := hostnameClaim{owner: key, hostname: hostname}
+			for _, c := range claimed {
+				if c.owner != key && hostnamesOverlap(c.hostname, hostname) {
+					holder = c
+					break
+				}
+			}
+			if holder.owner == key {
+				claimed = append(claimed, holder)
+			}
+			holders[hostname] = holder
+		}
+	}
+
+	rejected := map[types.NamespacedName]rejectedRules{}
+	admitted := make([]cloudflarev1beta1.Cloudflare, 0, len(allowed))
+	for _, item := range allowed {
+		key := client.ObjectKeyFromObject(&item)
+		reported := map[string]bool{}
+		var rules []cloudflarev1beta1.IngressRule
+		for _, rule := range item.Spec.Ingress {
+			hostname := normalizeDNSName(rule.Hostname)
+			if matchesAnyHost(hostname) {
+				r := rejected[key]
+				if rule.Path != "" {
+					r.hostless = append(r.hostless, fmt.Sprintf("rule for path %s has no hostname, which every rule on Tunnel %s needs", rule.Path, tunnel.Name))
+				} else {
+					r.hostless = append(r.hostless, fmt.Sprintf("catch-all rule has no hostname, which every rule on Tunnel %s needs; use spec.fallback of the Tunnel instead", tunnel.Name))
+				}
+				rejected[key] = r
+				continue
+			}
+			if holder := holders[hostname]; holder.owner != key {
+				if !reported[hostname] {
+					reported[hostname] = true
+					r := rejected[key]
+					if holder.hostname == hostname {
+						r.conflicts = append(r.conflicts, fmt.Sprintf("hostname %s is already used by %s on Tunnel %s", rule.Hostname, holder.owner, tunnel.Name))
+					} else {
+						r.conflicts = append(r.conflicts, fmt.Sprintf("hostname %s overlaps %s used by %s on Tunnel %s", rule.Hostname, holder.hostname, holder.owner, tunnel.Name))
+					}
+					rejected[key] = r
+				}
+				continue
+			}
+			rules = append(rules, rule)
+		}
+		item.Spec.Ingress = rules
+		admitted = append(admitted, item)
+	}
+	return admitted, rejected
+}
+
+// hostnamesOverlap は、どちらかのホスト名に一致するリクエストがもう一方にも一致しうるかを返します。
+// "*.example.com" のようなワイルドカードは、その下の全てのホスト名と重なります。
+func hostnamesOverlap(a, b string) bool {
+	return a == b || wildcardMatches(a, b) || wildcardMatches(b, a)
+}
+
+// wildcardMatches は pattern がワイルドカードで、name がその下のホスト名かを返します。
+func wildcardMatches(pattern, name string) bool {
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	return ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(name, suffix)
+}
+
+// ingressRules は CRD の ingress ルールを cloudflared の設定形式に変換します。
+// spec.originRequest はルールごとに展開するので、Tunnel を共有しても他のリソースのルールには影響しません。
+// serviceRef は resolveBackends で解決済みである前提で、クラスタ内の DNS 名の URL に変換します。
+func ingressRules(cf cloudflarev1beta1.Cloudflare) []IngressRule {
+	var out []IngressRule
+	for _, content := range cf.Spec.Ingress {
+		service := content.Service
+		if content.ServiceRef != nil {
+			service = serviceURL(content.ServiceRef, cf.Namespace)
+		}
+		out = append(out, IngressRule{
+			Hostname:      content.Hostname,
+			Path:          content.Path,
+			Service:       service,
+			OriginRequest: originRequestConfig(mergeOriginRequest(cf.Spec.OriginRequest, content.OriginRequest)),
+		})
+	}
+	return out
+}
+
+// mergeOriginRequest は global の設定に rule で指定された項目を上書きしたものを返します。
+func mergeOriginRequest(global, rule *cloudflarev1beta1.OriginRequest) *cloudflarev1beta1.OriginRequest {
+	if global == nil {
+		return rule
+	}
+	if rule == nil {
+		return global
+	}
+	return &cloudflarev1beta1.OriginRequest{
+		ConnectTimeout:         override(global.ConnectTimeout, rule.ConnectTimeout),
+		TLSTimeout:             override(global.TLSTimeout, rule.TLSTimeout),
+		TCPKeepAlive:           override(global.TCPKeepAlive, rule.TCPKeepAlive),
+		NoHappyEyeballs:        override(global.NoHappyEyeballs, rule.NoHappyEyeballs),
+		KeepAliveConnections:   override(global.KeepAliveConnections, rule.KeepAliveConnections),
+		KeepAliveTimeout:       override(global.KeepAliveTimeout, rule.KeepAliveTimeout),
+		HTTPHostHeader:         override(global.HTTPHostHeader, rule.HTTPHostHeader),
+		OriginServerName:       override(global.OriginServerName, rule.OriginServerName),
+		CAPool:                 override(global.CAPool, rule.CAPool),
+		NoTLSVerify:            override(global.NoTLSVerify, rule.NoTLSVerify),
+		HTTP2Origin:            override(global.HTTP2Origin, rule.HTTP2Origin),
+		DisableChunkedEncoding: override(global.DisableChunkedEncoding, rule.DisableChunkedEncoding),
+		ProxyType:              override(global.ProxyType, rule.ProxyType),
+		Access:                 override(global.Access, rule.Access),
+	}
+}
+
+func override[T any](global, rule *T) *T {
+	if rule != nil {
+		return rule
+	}
+	return global
+}
+
+// originRequestConfig は CRD の originRequest を cloudflared の設定形式に変換します。
+func originRequestConfig(o *cloudflarev1beta1.OriginRequest) *OriginRequestConfig {
+	if o == nil {
+		return nil
+	}
+	out := &OriginRequestConfig{
+		ConnectTimeout:         durationString(o.ConnectTimeout),
+		TLSTimeout:             durationString(o.TLSTimeout),
+		TCPKeepAlive:           durationString(o.TCPKeepAlive),
+		NoHappyEyeballs:        o.NoHappyEyeballs,
+		KeepAliveConnections:   o.KeepAliveConnections,
+		KeepAliveTimeout:       durationString(o.KeepAliveTimeout),
+		HTTPHostHeader:         ptr.Deref(o.HTTPHostHeader, ""),
+		OriginServerName:       ptr.Deref(o.OriginServerName, ""),
+		CAPool:                 ptr.Deref(o.CAPool, ""),
+		NoTLSVerify:            o.NoTLSVerify,
+		HTTP2Origin:            o.HTTP2Origin,
+		DisableChunkedEncoding: o.DisableChunkedEncoding,
+		ProxyType:              ptr.Deref(o.ProxyType, ""),
+	}
+	if o.Access != nil {
+		out.Access = &AccessConfig{
+			Required: o.Access.Required,
+			TeamName: o.Access.TeamName,
+			AudTag:   o.Access.AudTag,
+		}
+	}
+	return out
+}
+
+func durationString(d *metav1.Duration) string {
+	if d == nil {
+		return ""
+	}
+	return d.Duration.String()
+}
+
+// remoteOriginRequest は originRequest を Tunnel の設定 API の形式に変換します。
+func remoteOriginRequest(o *OriginRequestConfig) *cf.OriginRequestConfig {
+	if o == nil {
+		return nil
+	}
+	out := &cf.OriginRequestConfig{
+		ConnectTimeout:         tunnelDuration(o.ConnectTimeout),
+		TLSTimeout:             tunnelDuration(o.TLSTimeout),
+		TCPKeepAlive:           tunnelDuration(o.TCPKeepAlive),
+		NoHappyEyeballs:        o.NoHappyEyeballs,
+		KeepAliveTimeout:       tunnelDuration(o.KeepAliveTimeout),
+		HTTPHostHeader:         nonEmpty(o.HTTPHostHeader),
+		OriginServerName:       nonEmpty(o.OriginServerName),
+		CAPool:                 nonEmpty(o.CAPool),
+		NoTLSVerify:            o.NoTLSVerify,
+		Http2Origin:            o.HTTP2Origin,
+		DisableChunkedEncoding: o.DisableChunkedEncoding,
+		ProxyType:              nonEmpty(o.ProxyType),
+	}
+	if o.KeepAliveConnections != nil {
+		out.KeepAliveConnections = ptr.To(int(*o.KeepAliveConnections))
+	}
+	if o.Access != nil {
+		out.Access = &cf.AccessConfig{
+			Required: o.Access.Required,
+			TeamName: o.Access.TeamName,
+			AudTag:   o.Access.AudTag,
+		}
+	}
+	return out
+}
+
+// tunnelDuration は durationString で書いた時間を Tunnel の設定 API の形式に戻します。
+func tunnelDuration(s string) *cf.TunnelDuration {
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil
+	}
+	return &cf.TunnelDuration{Duration: d}
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// managedHostnames は Cloudflare リソースが公開するホスト名のうち、dnsManagement が Managed のものの集合を返します。
+func managedHostnames(cf cloudflarev1beta1.Cloudflare) map[string]bool {
+	hostnames := map[string]bool{}
+	for _, rule := range cf.Spec.Ingress {
+		if !matchesAnyHost(rule.Hostname) && dnsManagementFor(&cf, rule) == cloudflarev1beta1.DNSManagementManaged {
+			hostnames[rule.Hostname] = true
+		}
+	}
+	return hostnames
+}
+
+// renderConfig は connector の cloudflared 設定を YAML で返します。末尾には 404 のフォールバックを付けます。
+func renderConfig(conn connector) (string, error) {
+	spec := CloudflareConfig{
+		Tunnel:          conn.tunnelID,
+		CredentialsFile: credentialsFilePath,
+		Ingress:         withFallback(conn),
+		Metrics:         "0.0.0.0:2000",
+	}
+	yamlBytes, err := yaml.Marshal(&spec)
+	if err != nil {
+		return "", err
+	}
+	return string(yamlBytes), nil
+}
+
+// remoteConfig は connector の ingress ルールを Tunnel の設定 API の形式で返します。
+func remoteConfig(conn connector) cf.TunnelConfiguration {
+	var ingress []cf.UnvalidatedIngressRule
+	for _, rule := range withFallback(conn) {
+		ingress = append(ingress, cf.UnvalidatedIngressRule{
+			Hostname:      rule.Hostname,
+			Path:          rule.Path,
+			Service:       rule.Service,
+			OriginRequest: remoteOriginRequest(rule.OriginRequest),
+		})
+	}
+	return cf.TunnelConfiguration{Ingress: ingress}
+}
+
+// normalizedIngress は Tunnel の設定 API の ingress ルールから、cloudflared の既定値と同じ originRequest の設定を取り除いて返します。
+// Cloudflare は登録した設定に既定値を補って返すため、登録済みの設定と比べる前に両方をこの形に揃えます。
+func normalizedIngress(rules []cf.UnvalidatedIngressRule) []cf.UnvalidatedIngressRule {
+	out := make([]cf.UnvalidatedIngressRule, 0, len(rules))
+	for _, rule := range rules {
+		rule.OriginRequest = withoutOriginDefaults(rule.OriginRequest)
+		out = append(out, rule)
+	}
+	return out
+}
+
+// withoutOriginDefaults は cloudflared の既定値と同じ設定を省略した originRequest を返します。全て既定値の場合は nil を返します。
+func withoutOriginDefaults(o *cf.OriginRequestConfig) *cf.OriginRequestConfig {
+	if o == nil {
+		return nil
+	}
+	out := *o
+	out.ConnectTimeout = unlessDefault(out.ConnectTimeout, cf.TunnelDuration{Duration: 30 * time.Second})
+	out.TLSTimeout = unlessDefault(out.TLSTimeout, cf.TunnelDuration{Duration: 10 * time.Second})
+	out.TCPKeepAlive = unlessDefault(out.TCPKeepAlive, cf.TunnelDuration{Duration: 30 * time.Second})
+	out.NoHappyEyeballs = unlessDefault(out.NoHappyEyeballs, false)
+	out.KeepAliveConnections = unlessDefault(out.KeepAliveConnections, 100)
+	out.KeepAliveTimeout = unlessDefault(out.KeepAliveTimeout, cf.TunnelDuration{Duration: 90 * time.Second})
+	out.HTTPHostHeader = unlessDefault(out.HTTPHostHeader, "")
+	out.OriginServerName = unlessDefault(out.OriginServerName, "")
+	out.CAPool = unlessDefault(out.CAPool, "")
+	out.NoTLSVerify = unlessDefault(out.NoTLSVerify, false)
+	out.DisableChunkedEncoding = unlessDefault(out.DisableChunkedEncoding, false)
+	out.BastionMode = unlessDefault(out.BastionMode, false)
+	out.ProxyAddress = unlessDefault(out.ProxyAddress, "127.0.0.1")
+	out.ProxyPort = unlessDefault(out.ProxyPort, 0)
+	out.ProxyType = unlessDefault(out.ProxyType, "")
+	out.Http2Origin = unlessDefault(out.Http2Origin, false)
+	if len(out.IPRules) == 0 {
+		out.IPRules = nil
+	}
+	if equality.Semantic.DeepEqual(out, cf.OriginRequestConfig{}) {
+		return nil
+	}
+	return &out
+}
+
+// unlessDefault は値が既定値と同じ場合に nil を、それ以外の場合は値をそのまま返します。
+func unlessDefault[T comparable](v *T, def T) *T {
+	if v == nil || *v == def {
+		return nil
+	}
+	return v
+}
+
+// withFallback は ingress ルールの末尾に connector の fallback を付けます。
+// ルールが既に catch-all で終わっている場合はそのルールを優先します（cloudflared は catch-all の後のルールを受け付けません）。
+func withFallback(conn connector) []IngressRule {
+	out := append([]IngressRule{}, conn.rules...)
+	if len(out) > 0 && isCatchAll(out[len(out)-1]) {
+		return out
+	}
+	service := conn.fallback
+	if service == "" {
+		service = defaultFallbackService
+	}
+	return append(out, IngressRule{Service: service})
+}
+
+// isCatchAll はホスト名を省略するか "*" とし、パスも指定せず、全てのリクエストに一致するルールかを返します。
+func isCatchAll(rule IngressRule) bool {
+	return matchesAnyHost(rule.Hostname) && rule.Path == ""
+}
+
+// matchesAnyHost は、ホスト名が省略されているか "*" で、全てのホスト名に一致するかを返します。
+// このようなホスト名には DNS レコードを作成できず、共有 Tunnel で割り当てることもできません。
+func matchesAnyHost(hostname string) bool {
+	return hostname == "" || hostname == "*"
+}
+
+// fallbackService は FallbackService を cloudflared の service の値に変換します。
+// serviceRef の namespace を省略した場合は namespace を使います。
+func fallbackService(fallback *cloudflarev1beta1.FallbackService, namespace string) string {
+	switch {
+	case fallback == nil:
+		return defaultFallbackService
+	case fallback.HTTPStatus != nil:
+		return fmt.Sprintf("http_status:%d", *fallback.HTTPStatus)
+	case fallback.ServiceRef != nil:
+		return serviceURL(fallback.ServiceRef, namespace)
+	case fallback.HelloWorld:
+		return "hello_world"
+	default:
+		return defaultFallbackService
+	}
+}
+
+// defaultServiceScheme は ServiceReference の scheme の既定値です（CRD の default と同じ値）。
+// 生成する Cloudflare リソースにも明示し、API サーバーが補う値との差分で更新が繰り返されないようにします。
+const defaultServiceScheme = "http"
+
+// serviceURL は ServiceReference をクラスタ内の DNS 名の URL に変換します。
+func serviceURL(ref *cloudflarev1beta1.ServiceReference, namespace string) string {
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	scheme := ref.Scheme
+	if scheme == "" {
+		scheme = defaultServiceScheme
+	}
+	return fmt.Sprintf("%s://%s.%s.svc:%s", scheme, ref.Name, namespace, ref.Port.String())
+}
+
+// configHash は設定の内容から Pod テンプレートに付けるハッシュを計算します。
+func configHash(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// parseTunnelRefAnnotation は tunnelRefAnnotation の値（namespace/name）を参照先に戻します。
+func parseTunnelRefAnnotation(value string) types.NamespacedName {
+	namespace, name, _ := strings.Cut(value, "/")
+	return types.NamespacedName{Namespace: namespace, Name: name}
+}
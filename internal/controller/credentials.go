@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=clustercloudflareaccounts,verbs=get;list;watch
+
+// newCloudflareAPI は namespace にあるリソースの CredentialsSource から Cloudflare API クライアントを生成し、アカウント ID と共に返します。
+// newAPI が nil の場合は cfapi.NewClient を使います。
+func newCloudflareAPI(ctx context.Context, c client.Reader, newAPI cfapi.ClientFactory, namespace string, src cloudflarev1beta1.CredentialsSource) (cfapi.API, string, error) {
+	creds, err := cfapi.ResolveCredentials(ctx, c, namespace, src)
+	if err != nil {
+		return nil, "", err
+	}
+	if newAPI == nil {
+		newAPI = cfapi.NewClient
+	}
+	api, err := newAPI(creds)
+	if err != nil {
+		return nil, "", &cfapi.CredentialsError{Reason: "InvalidCredentials", Err: err}
+	}
+	return api, creds.AccountID, nil
+}
+
+// inheritedCredentials は Cloudflare リソースが tunnel から引き継ぐ認証情報の取得元です。引き継がない場合は nil です。
+func inheritedCredentials(cf *cloudflarev1beta1.Cloudflare, tunnel *cloudflarev1beta1.Tunnel) *cloudflarev1beta1.InheritedCredentials {
+	if !cfapi.InheritsTunnelCredentials(cf) {
+		return nil
+	}
+	return &cloudflarev1beta1.InheritedCredentials{
+		Namespace:         tunnel.Namespace,
+		CredentialsSource: *tunnel.Spec.CredentialsSource.DeepCopy(),
+	}
+}
+
+// isTunnelCredentialsError は、tunnelRef の Tunnel が無いか namespace を許可していないために認証情報を引き継げないエラーかを返します。
+func isTunnelCredentialsError(err error) bool {
+	var credsErr *cfapi.CredentialsError
+	return goerrors.As(err, &credsErr) && (credsErr.Reason == "TunnelNotFound" || credsErr.Reason == "TunnelNamespaceNotAllowed")
+}
+
+// credentialsCondition は認証情報の解決結果を CredentialsReady 条件に変換します。
+func credentialsCondition(err error, generation int64) metav1.Condition {
+	if err == nil {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeCredentialsReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             "CredentialsResolved",
+			ObservedGeneration: generation,
+		}
+	}
+	reason := "CredentialsUnavailable"
+	var credsErr *cfapi.CredentialsError
+	if goerrors.As(err, &credsErr) {
+		reason = credsErr.Reason
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeCredentialsReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            err.Error(),
+		ObservedGeneration: generation,
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// dnsEndpointGVK は external-dns の DNSEndpoint です。external-dns の API に依存しないよう unstructured で扱います。
+var dnsEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+// cloudflareProxiedProperty は external-dns の Cloudflare プロバイダがレコードの proxied に使うプロパティです。
+const cloudflareProxiedProperty = "external-dns.alpha.kubernetes.io/cloudflare-proxied"
+
+// dnsManagementFor はルールの DNS レコードの管理方法を返します。ルールの設定、spec の設定、Managed の順に決めます。
+func dnsManagementFor(cloudflare *cloudflarev1beta1.Cloudflare, rule cloudflarev1beta1.IngressRule) cloudflarev1beta1.DNSManagementPolicy {
+	if rule.DNSManagement != "" {
+		return rule.DNSManagement
+	}
+	if cloudflare.Spec.DNSManagement != "" {
+		return cloudflare.Spec.DNSManagement
+	}
+	return cloudflarev1beta1.DNSManagementManaged
+}
+
+// dnsEndpointName は Cloudflare リソースの DNSEndpoint の名前です。
+func dnsEndpointName(cloudflare *cloudflarev1beta1.Cloudflare) string {
+	return "cloudflare-" + cloudflare.Name
+}
+
+// externalDNSEndpoints は dnsManagement が ExternalDNS のルールのホスト名を、Tunnel を指す DNSEndpoint の endpoints に変換します。
+// proxied と TTL は dns の設定から external-dns の Cloudflare プロバイダの形式に変換します。
+func externalDNSEndpoints(cloudflare *cloudflarev1beta1.Cloudflare, tunnelID string) []interface{} {
+	var endpoints []interface{}
+	seen := map[string]bool{}
+	for _, rule := range cloudflare.Spec.Ingress {
+		if matchesAnyHost(rule.Hostname) || seen[rule.Hostname] ||
+			dnsManagementFor(cloudflare, rule) != cloudflarev1beta1.DNSManagementExternalDNS {
+			continue
+		}
+		seen[rule.Hostname] = true
+
+		settings := dnsSettingsFor(cloudflare.Spec.DNS, rule.DNS)
+		endpoint := map[string]interface{}{
+			"dnsName":    rule.Hostname,
+			"recordType": "CNAME",
+			"targets":    []interface{}{fmt.Sprintf("%s.cfargotunnel.com", tunnelID)},
+			"providerSpecific": []interface{}{
+				map[string]interface{}{"name": cloudflareProxiedProperty, "value": strconv.FormatBool(settings.proxied)},
+			},
+		}
+		if settings.ttl != 1 {
+			endpoint["recordTTL"] = int64(settings.ttl)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// reconcileDNSEndpoint は dnsManagement が ExternalDNS のルールのホスト名を DNSEndpoint リソースとして公開します。
+// 対象のルールが無い場合は DNSEndpoint を削除します。DNSEndpoint は Cloudflare リソースの削除時にガベージコレクションされます。
+func (r *CloudflareReconciler) reconcileDNSEndpoint(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare, tunnelID string) error {
+	logger := log.FromContext(ctx)
+
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(dnsEndpointGVK)
+	endpoint.SetName(dnsEndpointName(cloudflare))
+	endpoint.SetNamespace(cloudflare.Namespace)
+
+	endpoints := externalDNSEndpoints(cloudflare, tunnelID)
+	if len(endpoints) == 0 {
+		err := r.Get(ctx, client.ObjectKeyFromObject(endpoint), endpoint)
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, endpoint))
+	}
+
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, endpoint, func() error {
+		if err := unstructured.SetNestedSlice(endpoint.Object, endpoints, "spec", "endpoints"); err != nil {
+			return err
+		}
+		return ctrl.SetControllerReference(cloudflare, endpoint, r.Scheme)
+	})
+	if meta.IsNoMatchError(err) {
+		return fmt.Errorf("dnsManagement ExternalDNS requires the DNSEndpoint CRD of external-dns: %w", err)
+	}
+	if err != nil {
+		logger.Error(err, "unable to create or update DNSEndpoint")
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile DNSEndpoint successfully", "op", op)
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+const (
+	// dnsRegistryHeritage は、このオペレータが所有する DNS レコードを示す TXT レコードの heritage です。
+	dnsRegistryHeritage = "cloudflared-operator"
+
+	// dnsRegistryPrefix は所有者を記録する TXT レコード名の先頭のラベルです。
+	// CNAME と同じ名前には他のレコードを置けないため、"_cloudflared-operator.<hostname>" に置きます。
+	dnsRegistryPrefix = "_cloudflared-operator."
+
+	// dnsRegistryWildcardPrefix はワイルドカードのホスト名（*.example.com）の TXT レコード名の先頭のラベルです。
+	// "*" は最も左のラベルにしか置けないため、"_cloudflared-operator-wildcard.example.com" に置きます。
+	dnsRegistryWildcardPrefix = "_cloudflared-operator-wildcard."
+
+	// DefaultClusterID は --cluster-id を指定しなかった場合の所有者 ID のクラスタ部分です。
+	DefaultClusterID = "default"
+)
+
+// dnsOwner は DNS レコードの所有者です。ID はクラスタ ID と Cloudflare リソースの UID を "/" で繋いだものです。
+type dnsOwner struct {
+	ID       string
+	Resource string
+}
+
+// txt は所有者を TXT レコードの内容（external-dns と同じ key=value 形式）に変換します。
+func (o dnsOwner) txt() string {
+	return fmt.Sprintf(`"heritage=%s,%s/owner=%s,%s/resource=cloudflare/%s"`,
+		dnsRegistryHeritage, dnsRegistryHeritage, o.ID, dnsRegistryHeritage, o.Resource)
+}
+
+// parseDNSOwner は TXT レコードの内容から所有者を取り出します。このオペレータの TXT レコードでなければ false です。
+func parseDNSOwner(content string) (dnsOwner, bool) {
+	var owner dnsOwner
+	heritage := false
+	for _, field := range strings.Split(strings.Trim(content, `"`), ",") {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "heritage":
+			heritage = value == dnsRegistryHeritage
+		case dnsRegistryHeritage + "/owner":
+			owner.ID = value
+		case dnsRegistryHeritage + "/resource":
+			owner.Resource = strings.TrimPrefix(value, "cloudflare/")
+		}
+	}
+	return owner, heritage && owner.ID != ""
+}
+
+// registryName はホスト名の所有者を記録する TXT レコードの名前です。
+func registryName(hostname string) string {
+	if rest, ok := strings.CutPrefix(hostname, "*."); ok {
+		return dnsRegistryWildcardPrefix + rest
+	}
+	return dnsRegistryPrefix + hostname
+}
+
+// registryHostname は registryName の逆変換です。
+func registryHostname(name string) (string, bool) {
+	if rest, ok := strings.CutPrefix(name, dnsRegistryWildcardPrefix); ok {
+		return "*." + rest, true
+	}
+	return strings.CutPrefix(name, dnsRegistryPrefix)
+}
+
+// dnsOwnerFor は Cloudflare リソースの所有者 ID を返します。
+func (r *CloudflareReconciler) dnsOwnerFor(cloudflare *cloudflarev1beta1.Cloudflare) dnsOwner {
+	clusterID := r.ClusterID
+	if clusterID == "" {
+		clusterID = DefaultClusterID
+	}
+	return dnsOwner{
+		ID:       clusterID + "/" + string(cloudflare.UID),
+		Resource: cloudflare.Namespace + "/" + cloudflare.Name,
+	}
+}
+
+// dnsRecordSettings は CNAME レコードに設定する値です。作成と更新の両方で同じ値を使います。
+type dnsRecordSettings struct {
+	proxied bool
+	ttl     int
+	comment string
+	tags    []string
+}
+
+// dnsSettingsFor は spec.dns とルールの dns を項目単位でまとめた CNAME レコードの設定を返します。
+// proxied のレコードの TTL は Cloudflare が自動（1）にするため、差分が出ないように 1 にします。
+func dnsSettingsFor(global, rule *cloudflarev1beta1.DNSSettings) dnsRecordSettings {
+	settings := dnsRecordSettings{proxied: true, ttl: 1}
+	for _, s := range []*cloudflarev1beta1.DNSSettings{global, rule} {
+		if s == nil {
+			continue
+		}
+		if s.Proxied != nil {
+			settings.proxied = *s.Proxied
+		}
+		if s.TTL != nil {
+			settings.ttl = int(*s.TTL)
+		}
+		if s.Comment != "" {
+			settings.comment = s.Comment
+		}
+		if s.Tags != nil {
+			settings.tags = s.Tags
+		}
+	}
+	if settings.proxied {
+		settings.ttl = 1
+	}
+	return settings
+}
+
+// matches はレコードの proxied、TTL、コメント、タグが設定と一致するかを返します。タグの順序は問いません。
+func (s dnsRecordSettings) matches(rec cf.DNSRecord) bool {
+	return ptr.Deref(rec.Proxied, false) == s.proxied &&
+		rec.TTL == s.ttl &&
+		rec.Comment == s.comment &&
+		slices.Equal(sortedTags(rec.Tags), sortedTags(s.tags))
+}
+
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// dnsRegistry は TXT レコードで所有者を確かめながら、Tunnel を指す CNAME を操作します。
+// 所有者が異なるレコードは変更も削除もせず、衝突として報告します。
+// CNAME を作成・更新・削除した場合は events に記録します。
+type dnsRegistry struct {
+	api    cfapi.API
+	owner  dnsOwner
+	target string
+	events objectEvents
+}
+
+// ensure は hostname の CNAME を target に向け、settings と異なる項目があれば更新します。
+// 所有者の TXT レコードが無い場合は、既存のレコードが無いか、既に target を指す CNAME だけであれば所有者として登録します。
+// 他の所有者のレコードや、このオペレータが作っていないレコードがある場合は、その内容を conflict として返します。
+// 戻り値の recordID は CNAME レコードの ID です。
+func (g *dnsRegistry) ensure(ctx context.Context, zoneID, hostname string, settings dnsRecordSettings) (recordID, conflict string, err error) {
+	logger := log.FromContext(ctx)
+	rc := &cf.ResourceContainer{Identifier: zoneID}
+
+	owned, conflict, err := g.ownership(ctx, rc, hostname)
+	if err != nil || conflict != "" {
+		return "", conflict, err
+	}
+
+	records, _, err := g.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Name: hostname})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to list DNS records for %s: %w", hostname, err)
+	}
+	var cname *cf.DNSRecord
+	for i := range records {
+		rec := &records[i]
+		if rec.Type != "CNAME" {
+			if !owned {
+				return "", fmt.Sprintf("%s already has a %s record that is not managed by this operator", hostname, rec.Type), nil
+			}
+			continue
+		}
+		cname = rec
+	}
+	if !owned {
+		if cname != nil && cname.Content != g.target {
+			return "", fmt.Sprintf("%s already has a CNAME to %s that is not managed by this operator", hostname, cname.Content), nil
+		}
+		// 所有者を先に記録し、CNAME の作成に失敗しても次の Reconcile で自分のレコードとして扱えるようにする
+		if _, err := g.api.CreateDNSRecord(ctx, rc, cf.CreateDNSRecordParams{
+			Type:    "TXT",
+			Name:    registryName(hostname),
+			Content: g.owner.txt(),
+			TTL:     120,
+		}); err != nil {
+			return "", "", fmt.Errorf("failed to create owner record for %s: %w", hostname, err)
+		}
+		logger.Info("DNS owner record created", "hostname", hostname, "owner", g.owner.ID)
+	}
+
+	switch {
+	case cname == nil:
+		rec, err := g.api.CreateDNSRecord(ctx, rc, cf.CreateDNSRecordParams{
+			Type:    "CNAME",
+			Name:    hostname,
+			Content: g.target,
+			TTL:     settings.ttl,
+			Proxied: ptr.To(settings.proxied),
+			Comment: settings.comment,
+			Tags:    settings.tags,
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create DNS record for %s: %w", hostname, err)
+		}
+		logger.Info("DNS record created", "hostname", hostname, "content", g.target)
+		g.events.normal(eventDNSRecordCreated, "Created CNAME %s pointing to %s", hostname, g.target)
+		return rec.ID, "", nil
+	case cname.Content != g.target || !settings.matches(*cname):
+		if _, err := g.api.UpdateDNSRecord(ctx, rc, cf.UpdateDNSRecordParams{
+			ID:      cname.ID,
+			Type:    "CNAME",
+			Name:    hostname,
+			Content: g.target,
+			TTL:     settings.ttl,
+			Proxied: ptr.To(settings.proxied),
+			Comment: ptr.To(settings.comment),
+			Tags:    sortedTags(settings.tags),
+		}); err != nil {
+			return "", "", fmt.Errorf("failed to update DNS record for %s: %w", hostname, err)
+		}
+		logger.Info("DNS record updated", "hostname", hostname, "content", g.target)
+		g.events.normal(eventDNSRecordUpdated, "Updated CNAME %s pointing to %s", hostname, g.target)
+	}
+	return cname.ID, "", nil
+}
+
+// drift は以前に target へ同期した hostname のレコードが、このオペレータ以外から削除・変更されていればその内容を返します。
+// 他の所有者のレコードに置き換えられていた場合は ensure が衝突として報告するため、ここでは空を返します。
+func (g *dnsRegistry) drift(ctx context.Context, zoneID, hostname string, settings dnsRecordSettings) (string, error) {
+	rc := &cf.ResourceContainer{Identifier: zoneID}
+
+	owned, conflict, err := g.ownership(ctx, rc, hostname)
+	if err != nil || conflict != "" {
+		return "", err
+	}
+	records, _, err := g.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Type: "CNAME", Name: hostname})
+	if err != nil {
+		return "", fmt.Errorf("failed to list DNS records for %s: %w", hostname, err)
+	}
+	switch {
+	case len(records) == 0:
+		return fmt.Sprintf("CNAME %s was deleted outside the operator", hostname), nil
+	case records[0].Content != g.target:
+		return fmt.Sprintf("CNAME %s was changed outside the operator to point to %s", hostname, records[0].Content), nil
+	case !settings.matches(records[0]):
+		return fmt.Sprintf("the TTL, proxy status, comment or tags of CNAME %s were changed outside the operator", hostname), nil
+	case !owned:
+		return fmt.Sprintf("the owner record of %s was deleted outside the operator", hostname), nil
+	}
+	return "", nil
+}
+
+// ownership は hostname の所有者の TXT レコードを調べ、自分が所有しているかを返します。
+// 他の所有者の TXT レコードがあれば、その内容を conflict として返します。
+func (g *dnsRegistry) ownership(ctx context.Context, rc *cf.ResourceContainer, hostname string) (bool, string, error) {
+	owners, _, err := g.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Type: "TXT", Name: registryName(hostname)})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to list owner records for %s: %w", hostname, err)
+	}
+	for _, rec := range owners {
+		owner, ok := parseDNSOwner(rec.Content)
+		if !ok {
+			continue
+		}
+		if owner.ID != g.owner.ID {
+			return false, fmt.Sprintf("%s is owned by %s (%s)", hostname, owner.Resource, owner.ID), nil
+		}
+		return true, "", nil
+	}
+	return false, "", nil
+}
+
+// release は自分が所有する hostname の所有者の TXT レコードを削除します。
+// keepRecord が false の場合は CNAME も削除します。true の場合は CNAME を残し、同じ Tunnel を使う他のリソースが引き継げるようにします。
+func (g *dnsRegistry) release(ctx context.Context, zoneID, hostname string, keepRecord bool) error {
+	logger := log.FromContext(ctx)
+	rc := &cf.ResourceContainer{Identifier: zoneID}
+
+	owners, _, err := g.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Type: "TXT", Name: registryName(hostname)})
+	if err != nil {
+		return fmt.Errorf("failed to list owner records for %s: %w", hostname, err)
+	}
+	var ownerRecords []cf.DNSRecord
+	for _, rec := range owners {
+		if owner, ok := parseDNSOwner(rec.Content); ok && owner.ID == g.owner.ID {
+			ownerRecords = append(ownerRecords, rec)
+		}
+	}
+	if len(ownerRecords) == 0 {
+		return nil
+	}
+
+	if !keepRecord {
+		records, _, err := g.api.ListDNSRecords(ctx, rc, cf.ListDNSRecordsParams{Type: "CNAME", Name: hostname})
+		if err != nil {
+			return fmt.Errorf("failed to list DNS records for %s: %w", hostname, err)
+		}
+		for _, rec := range records {
+			if err := g.api.DeleteDNSRecord(ctx, rc, rec.ID); err != nil && !cfapi.IsNotFound(err) {
+				return fmt.Errorf("failed to delete DNS record for %s: %w", hostname, err)
+			}
+			logger.Info("DNS record deleted", "hostname", hostname, "recordID", rec.ID)
+			g.events.normal(eventDNSRecordDeleted, "Deleted CNAME %s", hostname)
+		}
+	}
+	for _, rec := range ownerRecords {
+		if err := g.api.DeleteDNSRecord(ctx, rc, rec.ID); err != nil && !cfapi.IsNotFound(err) {
+			return fmt.Errorf("failed to delete owner record for %s: %w", hostname, err)
+		}
+	}
+	logger.Info("DNS owner record deleted", "hostname", hostname, "owner", g.owner.ID)
+	return nil
+}
+
+// owned はゾーン内で自分が所有しているホスト名を返します。
+func (g *dnsRegistry) owned(ctx context.Context, zoneID string) ([]string, error) {
+	records, _, err := g.api.ListDNSRecords(ctx, &cf.ResourceContainer{Identifier: zoneID}, cf.ListDNSRecordsParams{Type: "TXT"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list owner records: %w", err)
+	}
+	var hostnames []string
+	for _, rec := range records {
+		hostname, ok := registryHostname(rec.Name)
+		if !ok {
+			continue
+		}
+		if owner, ok := parseDNSOwner(rec.Content); ok && owner.ID == g.owner.ID {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames, nil
+}
+
+// dnsConflictCondition は所有者の異なる DNS レコードとの衝突を DNSRecordConflict 条件に変換します。
+func dnsConflictCondition(conflicts []string, generation int64) metav1.Condition {
+	if len(conflicts) == 0 {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeDNSRecordConflict,
+			Status:             metav1.ConditionFalse,
+			Reason:             "RecordsOwned",
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeDNSRecordConflict,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RecordOwnedByOthers",
+		Message:            strings.Join(conflicts, "; "),
+		ObservedGeneration: generation,
+	}
+}
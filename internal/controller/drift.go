@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	goerrors "errors"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// DefaultResyncPeriod は Cloudflare リソースを定期的に Reconcile し、Tunnel と DNS レコードが外部から削除・変更されていないかを確かめる既定の間隔です。
+const DefaultResyncPeriod = 10 * time.Minute
+
+// errTunnelDrifted は driftPolicy が Report で、status の Tunnel が外部から削除・置き換えられていたことを表します。
+var errTunnelDrifted = goerrors.New("tunnel drifted")
+
+// resyncPeriod は再同期の間隔を返します。0 以下の場合は DefaultResyncPeriod です。
+func resyncPeriod(period time.Duration) time.Duration {
+	if period <= 0 {
+		return DefaultResyncPeriod
+	}
+	return period
+}
+
+// driftReport は 1 回の Reconcile で見つけた、このオペレータ以外による Tunnel や DNS レコードの削除・変更を集めます。
+// 見つけた時点で Event を記録し、最後に Drifted 条件に変換します。
+type driftReport struct {
+	policy cloudflarev1beta1.DriftPolicy
+	events objectEvents
+	// tunnelID は Reconcile を始めた時点の status の Tunnel ID です。
+	// Tunnel が変わった Reconcile では、DNS レコードの向き先の違いを外部からの変更として扱いません。
+	tunnelID string
+	// observed は前回の Reconcile が今の spec を反映したかです。spec が変わった Reconcile では、設定の違いを外部からの変更として扱いません。
+	observed bool
+	// previous は前回の Drifted 条件で、同じ内容を Reconcile の度に Event に記録しないために使います。
+	previous metav1.Condition
+
+	detected  []string
+	corrected []string
+}
+
+// newDriftReport は Cloudflare リソースの Reconcile を始める時に呼び出します。
+func (r *CloudflareReconciler) newDriftReport(cloudflare *cloudflarev1beta1.Cloudflare) *driftReport {
+	drift := &driftReport{
+		policy:   cloudflare.Spec.DriftPolicy,
+		events:   r.eventsFor(cloudflare),
+		tunnelID: cloudflare.Status.TunnelID,
+		observed: cloudflare.Status.ObservedGeneration == cloudflare.Generation,
+	}
+	if previous := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeDrifted); previous != nil {
+		drift.previous = *previous
+	}
+	return drift
+}
+
+// checksDNS は tunnelID を指す DNS レコードの外部からの変更を確かめるかを返します。
+// spec か Tunnel が前回の Reconcile から変わった場合は、レコードの違いが外部からの変更とは限らないため確かめません。
+func (d *driftReport) checksDNS(tunnelID string) bool {
+	return d != nil && d.observed && d.tunnelID == tunnelID
+}
+
+// reportOnly は外部からの変更を元に戻さずに報告だけするかを返します。
+func (d *driftReport) reportOnly() bool {
+	return d.policy == cloudflarev1beta1.DriftPolicyReport
+}
+
+// detect は元に戻さずに残した変更を記録します。前回の Drifted 条件に含まれていない場合だけ Event を記録します。
+func (d *driftReport) detect(message string) {
+	d.detected = append(d.detected, message)
+	if d.previous.Status == metav1.ConditionTrue && strings.Contains(d.previous.Message, message) {
+		return
+	}
+	d.events.warning(eventDriftDetected, "%s; left as is because driftPolicy is Report", message)
+}
+
+// correct は元に戻した変更を記録します。
+func (d *driftReport) correct(message string) {
+	d.corrected = append(d.corrected, message)
+	d.events.warning(eventDriftCorrected, "%s; corrected it", message)
+}
+
+// condition は集めた変更を Drifted 条件に変換します。
+func (d *driftReport) condition(generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               cloudflarev1beta1.TypeDrifted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case len(d.detected) > 0:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "DriftDetected"
+		cond.Message = strings.Join(d.detected, "; ")
+	case len(d.corrected) > 0:
+		cond.Reason = "DriftCorrected"
+		cond.Message = strings.Join(d.corrected, "; ")
+	default:
+		cond.Reason = "InSync"
+	}
+	return cond
+}
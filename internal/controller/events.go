@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Cloudflare 側の変更と API の失敗を記録する Event の Reason です。
+const (
+	eventTunnelCreated     = "TunnelCreated"
+	eventTunnelAdopted     = "TunnelAdopted"
+	eventTunnelDeleted     = "TunnelDeleted"
+	eventTunnelError       = "TunnelError"
+	eventCleanupSkipped    = "CleanupSkipped"
+	eventTunnelRefRejected = "TunnelRefRejected"
+	eventHostnameConflict  = "HostnameConflict"
+	eventHostnameRequired  = "HostnameRequired"
+	eventResourceConflict  = "ResourceConflict"
+	eventInvalidAnnotation = "InvalidAnnotation"
+	eventDNSRecordCreated  = "DNSRecordCreated"
+	eventDNSRecordUpdated  = "DNSRecordUpdated"
+	eventDNSRecordDeleted  = "DNSRecordDeleted"
+	eventDNSRecordConflict = "DNSRecordConflict"
+	eventDNSRecordError    = "DNSRecordError"
+	eventConfigUpdated     = "ConfigUpdated"
+	eventConfigError       = "ConfigError"
+	eventDriftDetected     = "DriftDetected"
+	eventDriftCorrected    = "DriftCorrected"
+)
+
+// objectEvents は Event を記録する対象のオブジェクトと EventRecorder の組です。
+// recorder が nil の場合は何も記録しません。
+type objectEvents struct {
+	recorder record.EventRecorder
+	object   runtime.Object
+}
+
+// eventsFor は obj に Event を記録する objectEvents を返します。
+func (r *CloudflareReconciler) eventsFor(obj runtime.Object) objectEvents {
+	return objectEvents{recorder: r.Recorder, object: obj}
+}
+
+// normal は Normal の Event を記録します。
+func (e objectEvents) normal(reason, messageFmt string, args ...interface{}) {
+	if e.recorder == nil {
+		return
+	}
+	e.recorder.Eventf(e.object, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// warning は Warning の Event を記録します。
+func (e objectEvents) warning(reason, messageFmt string, args ...interface{}) {
+	if e.recorder == nil {
+		return
+	}
+	e.recorder.Eventf(e.object, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
@@ -0,0 +1,406 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+const (
+	// GatewayControllerName は、このオペレータが処理する GatewayClass の spec.controllerName の値です。
+	GatewayControllerName gatewayv1.GatewayController = "cloudflare.laininthewired.github.io/gateway-controller"
+
+	// gatewayLabel は Gateway から生成した Tunnel に、元の Gateway 名を記録します。
+	gatewayLabel = "cloudflare.laininthewired.github.io/gateway"
+)
+
+// GatewayClassReconciler reconciles a gateway.networking.k8s.io/v1 GatewayClass object
+//
+// このオペレータの GatewayClass を受け付け、parametersRef が ClusterCloudflareAccount を指しているかを確認します。
+type GatewayClassReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// GatewayReconciler reconciles a gateway.networking.k8s.io/v1 Gateway object
+//
+// Gateway ごとに Tunnel リソースを作成します。Gateway に紐付いた HTTPRoute は HTTPRouteReconciler が
+// tunnelRef 付きの Cloudflare リソースに変換し、cloudflared の Deployment は CloudflareReconciler が描画します。
+type GatewayReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+
+// Reconcile は GatewayClass の Accepted 条件を記録します。
+func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var class gatewayv1.GatewayClass
+	err := r.Get(ctx, req.NamespacedName, &class)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if class.Spec.ControllerName != GatewayControllerName {
+		return ctrl.Result{}, nil
+	}
+
+	condition := metav1.Condition{
+		Type:               string(gatewayv1.GatewayClassConditionStatusAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gatewayv1.GatewayClassReasonAccepted),
+		ObservedGeneration: class.Generation,
+	}
+	if _, err := gatewayClassCredentials(&class); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = string(gatewayv1.GatewayClassReasonInvalidParameters)
+		condition.Message = err.Error()
+	}
+	if meta.SetStatusCondition(&class.Status.Conditions, condition) {
+		return ctrl.Result{}, r.Status().Update(ctx, &class)
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1.GatewayClass{}).
+		Named("gatewayclass").
+		Complete(r)
+}
+
+// gatewayClassCredentials は GatewayClass の parametersRef から Tunnel と DNS の操作に使う認証情報の取得元を返します。
+// parametersRef を省略した場合は従来の default/cloudflare-api-token を使います。
+func gatewayClassCredentials(class *gatewayv1.GatewayClass) (cloudflarev1beta1.CredentialsSource, error) {
+	ref := class.Spec.ParametersRef
+	if ref == nil {
+		return cloudflarev1beta1.CredentialsSource{}, nil
+	}
+	if string(ref.Group) != cloudflarev1beta1.GroupVersion.Group || ref.Kind != "ClusterCloudflareAccount" {
+		return cloudflarev1beta1.CredentialsSource{}, fmt.Errorf("parametersRef must refer to a ClusterCloudflareAccount, got %s/%s", ref.Group, ref.Kind)
+	}
+	return cloudflarev1beta1.CredentialsSource{AccountRef: &cloudflarev1beta1.AccountReference{Name: ref.Name}}, nil
+}
+
+// managedGatewayClass は名前の GatewayClass がこのオペレータのものであれば返します。
+func managedGatewayClass(ctx context.Context, c client.Reader, name gatewayv1.ObjectName) (*gatewayv1.GatewayClass, error) {
+	var class gatewayv1.GatewayClass
+	err := c.Get(ctx, client.ObjectKey{Name: string(name)}, &class)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if class.Spec.ControllerName != GatewayControllerName {
+		return nil, nil
+	}
+	return &class, nil
+}
+
+// gatewayTunnelName は Gateway から生成する Tunnel リソースの名前です。
+func gatewayTunnelName(gw *gatewayv1.Gateway) string {
+	return "gateway-" + gw.Name
+}
+
+// gatewayCloudflareTunnelName は Gateway の Cloudflare 上の Tunnel 名です。
+// namespace と名前を "-" でつなぐだけでは "a-b/c" と "a/b-c" が重なるため、UID を付けてアカウント内で一意にします。
+func gatewayCloudflareTunnelName(gw *gatewayv1.Gateway) string {
+	return gw.Namespace + "-" + gw.Name + "-" + string(gw.UID)
+}
+
+// Reconcile は Gateway の Tunnel を作成し、Tunnel のホスト名と Accepted/Programmed 条件を Gateway の status に書き込みます。
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var gw gatewayv1.Gateway
+	err := r.Get(ctx, req.NamespacedName, &gw)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to get Gateway", "name", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	if !gw.DeletionTimestamp.IsZero() {
+		// Tunnel は ownerReference で削除される
+		return ctrl.Result{}, nil
+	}
+
+	class, err := managedGatewayClass(ctx, r.Client, gw.Spec.GatewayClassName)
+	if err != nil || class == nil {
+		return ctrl.Result{}, err
+	}
+	creds, err := gatewayClassCredentials(class)
+	if err != nil {
+		// GatewayClass の Accepted 条件で報告済み
+		return ctrl.Result{}, nil
+	}
+
+	attached, namespaces, err := r.attachedRoutes(ctx, &gw)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	tunnel := &cloudflarev1beta1.Tunnel{}
+	tunnel.SetNamespace(gw.Namespace)
+	tunnel.SetName(gatewayTunnelName(&gw))
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, tunnel, func() error {
+		if tunnel.Labels == nil {
+			tunnel.Labels = map[string]string{}
+		}
+		tunnel.Labels[gatewayLabel] = gw.Name
+		tunnel.Spec.TunnelName = gatewayCloudflareTunnelName(&gw)
+		if tunnel.Spec.Replicas == 0 {
+			tunnel.Spec.Replicas = 1
+		}
+		tunnel.Spec.CredentialsSource = creds
+		// リスナーの allowedRoutes が受け付けた HTTPRoute の namespace から Tunnel を参照できるようにする
+		tunnel.Spec.AllowedNamespaces = namespaces
+		return ctrl.SetControllerReference(&gw, tunnel, r.Scheme)
+	})
+	if err != nil {
+		logger.Error(err, "unable to create or update Tunnel for Gateway")
+		return ctrl.Result{}, err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile Tunnel for Gateway successfully", "op", op, "name", tunnel.Name)
+	}
+
+	var dep *appsv1.Deployment
+	if tunnel.Status.TunnelID != "" {
+		dep = &appsv1.Deployment{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: tunnel.Namespace, Name: sharedConnectorName(tunnel)}, dep)
+		if errors.IsNotFound(err) {
+			dep = nil
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.updateGatewayStatus(ctx, &gw, tunnel, dep, attached)
+}
+
+// attachedRoutes はリスナーごとに、受け付けた HTTPRoute の数を返します。
+// 併せて、受け付けた HTTPRoute のうち Gateway と異なる namespace を名前順で返します。
+func (r *GatewayReconciler) attachedRoutes(ctx context.Context, gw *gatewayv1.Gateway) (map[gatewayv1.SectionName]int32, []string, error) {
+	var routes gatewayv1.HTTPRouteList
+	if err := r.List(ctx, &routes); err != nil {
+		return nil, nil, err
+	}
+	attached := map[gatewayv1.SectionName]int32{}
+	var namespaces []string
+	for i := range routes.Items {
+		route := &routes.Items[i]
+		for _, ref := range route.Spec.ParentRefs {
+			if !parentRefersToGateway(ref, route.Namespace, gw) {
+				continue
+			}
+			listeners, _, err := allowedListeners(ctx, r.Client, gw, route, ref)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, l := range listeners {
+				attached[l.Name]++
+			}
+			if len(listeners) > 0 && route.Namespace != gw.Namespace && !slices.Contains(namespaces, route.Namespace) {
+				namespaces = append(namespaces, route.Namespace)
+			}
+		}
+	}
+	slices.Sort(namespaces)
+	return attached, namespaces, nil
+}
+
+// updateGatewayStatus は Gateway の addresses、Accepted/Programmed 条件とリスナーの状態を更新します。
+// addresses は Tunnel の ID が確定した時点で書き込み、Programmed は cloudflared の Deployment dep が利用可能になった時点で True にします。
+// dep は HTTPRoute が紐付いて Tunnel の設定が描画されるまで作成されないため、それまでは Pending です。
+func (r *GatewayReconciler) updateGatewayStatus(ctx context.Context, gw *gatewayv1.Gateway, tunnel *cloudflarev1beta1.Tunnel, dep *appsv1.Deployment, attached map[gatewayv1.SectionName]int32) error {
+	status := gw.Status.DeepCopy()
+
+	programmed := metav1.Condition{
+		Type:               string(gatewayv1.GatewayConditionProgrammed),
+		Status:             metav1.ConditionFalse,
+		Reason:             string(gatewayv1.GatewayReasonPending),
+		Message:            "waiting for the Tunnel to be created",
+		ObservedGeneration: gw.Generation,
+	}
+	status.Addresses = nil
+	if tunnelID := tunnel.Status.TunnelID; tunnelID != "" {
+		status.Addresses = []gatewayv1.GatewayStatusAddress{{
+			Type:  ptr.To(gatewayv1.HostnameAddressType),
+			Value: tunnelID + ".cfargotunnel.com",
+		}}
+		available := deploymentCondition(sharedConnectorName(tunnel), dep, tunnel.Spec.Replicas, gw.Generation)
+		programmed.Message = available.Message
+		if available.Status == metav1.ConditionTrue {
+			programmed.Status = metav1.ConditionTrue
+			programmed.Reason = string(gatewayv1.GatewayReasonProgrammed)
+			programmed.Message = ""
+		}
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               string(gatewayv1.GatewayConditionAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gatewayv1.GatewayReasonAccepted),
+		ObservedGeneration: gw.Generation,
+	})
+	meta.SetStatusCondition(&status.Conditions, programmed)
+
+	listeners := make([]gatewayv1.ListenerStatus, 0, len(gw.Spec.Listeners))
+	for _, l := range gw.Spec.Listeners {
+		ls := gatewayv1.ListenerStatus{
+			Name:           l.Name,
+			SupportedKinds: []gatewayv1.RouteGroupKind{{Group: ptr.To(gatewayv1.Group(gatewayv1.GroupName)), Kind: "HTTPRoute"}},
+			AttachedRoutes: attached[l.Name],
+		}
+		for _, prev := range status.Listeners {
+			if prev.Name == l.Name {
+				ls.Conditions = prev.Conditions
+			}
+		}
+		accepted := metav1.Condition{
+			Type:               string(gatewayv1.ListenerConditionAccepted),
+			Status:             metav1.ConditionTrue,
+			Reason:             string(gatewayv1.ListenerReasonAccepted),
+			ObservedGeneration: gw.Generation,
+		}
+		if !supportedListenerProtocol(l.Protocol) {
+			// cloudflared はエッジで HTTP(S) を受けるため、それ以外のプロトコルは扱えない
+			accepted.Status = metav1.ConditionFalse
+			accepted.Reason = string(gatewayv1.ListenerReasonUnsupportedProtocol)
+			accepted.Message = fmt.Sprintf("protocol %s is not supported", l.Protocol)
+			ls.SupportedKinds = []gatewayv1.RouteGroupKind{}
+		}
+		listenerProgrammed := metav1.Condition{
+			Type:               string(gatewayv1.ListenerConditionProgrammed),
+			Status:             programmed.Status,
+			Reason:             programmed.Reason,
+			ObservedGeneration: gw.Generation,
+		}
+		if accepted.Status == metav1.ConditionFalse {
+			listenerProgrammed.Status = metav1.ConditionFalse
+			listenerProgrammed.Reason = string(gatewayv1.ListenerReasonInvalid)
+		}
+		meta.SetStatusCondition(&ls.Conditions, accepted)
+		meta.SetStatusCondition(&ls.Conditions, metav1.Condition{
+			Type:               string(gatewayv1.ListenerConditionResolvedRefs),
+			Status:             metav1.ConditionTrue,
+			Reason:             string(gatewayv1.ListenerReasonResolvedRefs),
+			ObservedGeneration: gw.Generation,
+		})
+		meta.SetStatusCondition(&ls.Conditions, listenerProgrammed)
+		listeners = append(listeners, ls)
+	}
+	status.Listeners = listeners
+
+	if equality.Semantic.DeepEqual(&gw.Status, status) {
+		return nil
+	}
+	gw.Status = *status
+	return r.Status().Update(ctx, gw)
+}
+
+func supportedListenerProtocol(protocol gatewayv1.ProtocolType) bool {
+	return protocol == gatewayv1.HTTPProtocolType || protocol == gatewayv1.HTTPSProtocolType
+}
+
+// gatewaysForClass は GatewayClass の変更時に、その GatewayClass の Gateway を再調整対象にします。
+func (r *GatewayReconciler) gatewaysForClass(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list gatewayv1.GatewayList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if string(list.Items[i].Spec.GatewayClassName) == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// gatewaysForRoute は HTTPRoute の変更時に、その HTTPRoute の親の Gateway を再調整対象にします（attachedRoutes の更新）。
+func (r *GatewayReconciler) gatewaysForRoute(ctx context.Context, obj client.Object) []reconcile.Request {
+	route, ok := obj.(*gatewayv1.HTTPRoute)
+	if !ok {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, ref := range route.Spec.ParentRefs {
+		if key, ok := parentGatewayKey(ref, route.Namespace); ok {
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+	}
+	return requests
+}
+
+// gatewaysForDeployment は Gateway の Tunnel の cloudflared の Deployment が変わった時に、その Gateway を再調整対象にします（Programmed の更新）。
+func (r *GatewayReconciler) gatewaysForDeployment(ctx context.Context, obj client.Object) []reconcile.Request {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.APIVersion != cloudflarev1beta1.GroupVersion.String() || owner.Kind != "Tunnel" {
+		return nil
+	}
+	var tunnel cloudflarev1beta1.Tunnel
+	if err := r.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: owner.Name}, &tunnel); err != nil {
+		return nil
+	}
+	gw := metav1.GetControllerOf(&tunnel)
+	if gw == nil || gw.APIVersion != gatewayv1.GroupVersion.String() || gw.Kind != "Gateway" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: tunnel.Namespace, Name: gw.Name}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1.Gateway{}).
+		Owns(&cloudflarev1beta1.Tunnel{}).
+		Watches(&gatewayv1.GatewayClass{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForClass)).
+		Watches(&gatewayv1.HTTPRoute{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForRoute)).
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForDeployment)).
+		Named("gateway").
+		Complete(r)
+}
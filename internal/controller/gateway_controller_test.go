@@ -0,0 +1,213 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+var _ = Describe("Gateway Controller", func() {
+	Context("When reconciling a Gateway of the Cloudflare GatewayClass", func() {
+		const (
+			className   = "cloudflare"
+			gatewayName = "gateway"
+		)
+
+		ctx := context.Background()
+
+		classKey := types.NamespacedName{Name: className}
+		gatewayKey := types.NamespacedName{Name: gatewayName, Namespace: "default"}
+		tunnelKey := types.NamespacedName{Name: "gateway-" + gatewayName, Namespace: "default"}
+
+		BeforeEach(func() {
+			By("creating the GatewayClass")
+			class := &gatewayv1.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: className},
+				Spec: gatewayv1.GatewayClassSpec{
+					ControllerName: GatewayControllerName,
+					ParametersRef: &gatewayv1.ParametersReference{
+						Group: gatewayv1.Group(cloudflarev1beta1.GroupVersion.Group),
+						Kind:  "ClusterCloudflareAccount",
+						Name:  "production",
+					},
+				},
+			}
+			err := k8sClient.Create(ctx, class)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating the Gateway")
+			gw := &gatewayv1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Name: gatewayName, Namespace: "default"},
+				Spec: gatewayv1.GatewaySpec{
+					GatewayClassName: className,
+					Listeners: []gatewayv1.Listener{
+						{
+							Name:     "http",
+							Hostname: ptr.To(gatewayv1.Hostname("*.widgetcorp.tech")),
+							Port:     80,
+							Protocol: gatewayv1.HTTPProtocolType,
+						},
+						{
+							Name:     "tcp",
+							Port:     5432,
+							Protocol: gatewayv1.TCPProtocolType,
+						},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, gw)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			gw := &gatewayv1.Gateway{}
+			if err := k8sClient.Get(ctx, gatewayKey, gw); err == nil {
+				Expect(k8sClient.Delete(ctx, gw)).To(Succeed())
+			}
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			if err := k8sClient.Get(ctx, tunnelKey, tunnel); err == nil {
+				tunnel.Finalizers = nil
+				Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, tunnel))).To(Succeed())
+			}
+		})
+
+		It("should accept the GatewayClass referring to a ClusterCloudflareAccount", func() {
+			_, err := (&GatewayClassReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}).
+				Reconcile(ctx, reconcile.Request{NamespacedName: classKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			class := &gatewayv1.GatewayClass{}
+			Expect(k8sClient.Get(ctx, classKey, class)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(class.Status.Conditions, string(gatewayv1.GatewayClassConditionStatusAccepted))).To(BeTrue())
+		})
+
+		It("should create a Tunnel and report the Tunnel hostname once it is programmed", func() {
+			controllerReconciler := &GatewayReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: gatewayKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+			Expect(tunnel.Spec.AccountRef).To(Equal(&cloudflarev1beta1.AccountReference{Name: "production"}))
+
+			gw := &gatewayv1.Gateway{}
+			Expect(k8sClient.Get(ctx, gatewayKey, gw)).To(Succeed())
+			Expect(tunnel.Spec.TunnelName).To(Equal("default-" + gatewayName + "-" + string(gw.UID)))
+			Expect(metav1.IsControlledBy(tunnel, gw)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(gw.Status.Conditions, string(gatewayv1.GatewayConditionAccepted))).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(gw.Status.Conditions, string(gatewayv1.GatewayConditionProgrammed))).To(BeTrue())
+
+			By("setting the Tunnel ID")
+			tunnel.Status.TunnelID = "gateway-tunnel-id"
+			Expect(k8sClient.Status().Update(ctx, tunnel)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: gatewayKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, gatewayKey, gw)).To(Succeed())
+			Expect(gw.Status.Addresses).To(Equal([]gatewayv1.GatewayStatusAddress{{
+				Type:  ptr.To(gatewayv1.HostnameAddressType),
+				Value: "gateway-tunnel-id.cfargotunnel.com",
+			}}))
+			Expect(meta.IsStatusConditionFalse(gw.Status.Conditions, string(gatewayv1.GatewayConditionProgrammed))).To(BeTrue())
+
+			By("making the cloudflared Deployment available")
+			labels := map[string]string{"app.kubernetes.io/name": "cloudflare-tunnel"}
+			dep := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cloudflare-tunnel-" + tunnelKey.Name, Namespace: tunnelKey.Namespace},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "cloudflared", Image: "cloudflare/cloudflared"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+			})
+			dep.Status.Replicas = 1
+			dep.Status.AvailableReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: gatewayKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, gatewayKey, gw)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(gw.Status.Conditions, string(gatewayv1.GatewayConditionProgrammed))).To(BeTrue())
+			Expect(gw.Status.Listeners).To(HaveLen(2))
+			Expect(meta.IsStatusConditionTrue(gw.Status.Listeners[0].Conditions, string(gatewayv1.ListenerConditionAccepted))).To(BeTrue())
+			Expect(meta.FindStatusCondition(gw.Status.Listeners[1].Conditions, string(gatewayv1.ListenerConditionAccepted)).Reason).
+				To(Equal(string(gatewayv1.ListenerReasonUnsupportedProtocol)))
+		})
+
+		It("should allow the namespaces of the HTTPRoutes the listeners accept on the Tunnel", func() {
+			routeKey := types.NamespacedName{Name: "web", Namespace: "gateway-routes"}
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: routeKey.Namespace}})).To(Succeed())
+
+			By("accepting routes from all namespaces on the http listener")
+			gw := &gatewayv1.Gateway{}
+			Expect(k8sClient.Get(ctx, gatewayKey, gw)).To(Succeed())
+			gw.Spec.Listeners[0].AllowedRoutes = &gatewayv1.AllowedRoutes{
+				Namespaces: &gatewayv1.RouteNamespaces{From: ptr.To(gatewayv1.NamespacesFromAll)},
+			}
+			Expect(k8sClient.Update(ctx, gw)).To(Succeed())
+
+			route := &gatewayv1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{Name: routeKey.Name, Namespace: routeKey.Namespace},
+				Spec: gatewayv1.HTTPRouteSpec{
+					CommonRouteSpec: gatewayv1.CommonRouteSpec{
+						ParentRefs: []gatewayv1.ParentReference{{Name: gatewayName, Namespace: ptr.To(gatewayv1.Namespace("default"))}},
+					},
+					Hostnames: []gatewayv1.Hostname{"web.widgetcorp.tech"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, route)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, route)).To(Succeed())
+			})
+
+			controllerReconciler := &GatewayReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: gatewayKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, tunnelKey, tunnel)).To(Succeed())
+			Expect(tunnel.Spec.AllowedNamespaces).To(Equal([]string{routeKey.Namespace}))
+		})
+	})
+})
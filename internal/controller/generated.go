@@ -0,0 +1,54 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// generatedConflictError は、Ingress・HTTPRoute・Service から生成する名前の Cloudflare リソースが既にあり、
+// 生成元のオブジェクトが所有していないことを表します。利用者が作成したリソースは変更も削除もしません。
+type generatedConflictError struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (e *generatedConflictError) Error() string {
+	return fmt.Sprintf("Cloudflare resource %s/%s already exists and is not managed by this %s; rename or delete it", e.namespace, e.name, e.kind)
+}
+
+// checkGeneratedOwner は、既存の Cloudflare リソースを owner が所有している場合だけ nil を返します。
+// まだ作成されていないリソースは owner のものとして扱います。ctrl.CreateOrUpdate の mutate の先頭で呼びます。
+func checkGeneratedOwner(owner client.Object, kind string, cloudflare *cloudflarev1beta1.Cloudflare) error {
+	if cloudflare.CreationTimestamp.IsZero() || metav1.IsControlledBy(cloudflare, owner) {
+		return nil
+	}
+	return &generatedConflictError{kind: kind, namespace: cloudflare.Namespace, name: cloudflare.Name}
+}
+
+// generatedConfigReady は生成した Cloudflare リソースのルールが、今の spec のまま Tunnel の設定に載っているかを返します。
+func generatedConfigReady(cloudflare *cloudflarev1beta1.Cloudflare) bool {
+	cond := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+	return cond != nil && cond.Status == metav1.ConditionTrue && cond.ObservedGeneration == cloudflare.Generation
+}
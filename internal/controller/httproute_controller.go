@@ -0,0 +1,657 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+const (
+	// httpRouteLabel は HTTPRoute から生成した Cloudflare リソースに、元の HTTPRoute 名を記録します。
+	httpRouteLabel = "cloudflare.laininthewired.github.io/httproute"
+
+	// invalidBackendStatus は有効な backendRef が無いルールに返すステータスコードです。
+	invalidBackendStatus = 500
+)
+
+// HTTPRouteReconciler reconciles a gateway.networking.k8s.io/v1 HTTPRoute object
+//
+// 親の Gateway ごとに、HTTPRoute を Gateway の Tunnel を参照する Cloudflare リソースに変換します。
+// IngressReconciler と同様に、設定の描画、DNS レコード、serviceRef の解決は CloudflareReconciler が行います。
+type HTTPRouteReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// routeParent は HTTPRoute が紐付いた、このオペレータの Gateway です。
+type routeParent struct {
+	ref       gatewayv1.ParentReference
+	gateway   *gatewayv1.Gateway
+	listeners []gatewayv1.Listener
+	// reason は受け付けるリスナーが無かった理由です。
+	reason gatewayv1.RouteConditionReason
+}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=cloudflares,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile は HTTPRoute から親の Gateway ごとの Cloudflare リソースを生成し、parents に Accepted/ResolvedRefs/Programmed 条件を書き込みます。
+// 親ではなくなった Gateway の Cloudflare リソースは削除します。
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var route gatewayv1.HTTPRoute
+	err := r.Get(ctx, req.NamespacedName, &route)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to get HTTPRoute", "name", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	if !route.DeletionTimestamp.IsZero() {
+		// 生成した Cloudflare リソースは ownerReference で削除される
+		return ctrl.Result{}, nil
+	}
+
+	parents, err := r.routeParents(ctx, &route)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	keep := map[string]bool{}
+	statuses := make([]gatewayv1.RouteParentStatus, 0, len(parents))
+	for _, parent := range parents {
+		status, name, err := r.reconcileParent(ctx, &route, parent)
+		if err != nil {
+			logger.Error(err, "unable to reconcile HTTPRoute for Gateway", "gateway", parent.gateway.Name)
+			return ctrl.Result{}, err
+		}
+		if name != "" {
+			keep[name] = true
+		}
+		statuses = append(statuses, status)
+	}
+
+	if err := r.deleteStaleCloudflares(ctx, &route, keep); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.updateRouteStatus(ctx, &route, statuses)
+}
+
+// routeParents は parentRefs のうち、このオペレータの GatewayClass の Gateway を返します。
+func (r *HTTPRouteReconciler) routeParents(ctx context.Context, route *gatewayv1.HTTPRoute) ([]routeParent, error) {
+	var parents []routeParent
+	for _, ref := range route.Spec.ParentRefs {
+		key, ok := parentGatewayKey(ref, route.Namespace)
+		if !ok {
+			continue
+		}
+		var gw gatewayv1.Gateway
+		if err := r.Get(ctx, key, &gw); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		class, err := managedGatewayClass(ctx, r.Client, gw.Spec.GatewayClassName)
+		if err != nil {
+			return nil, err
+		}
+		if class == nil {
+			continue
+		}
+		listeners, reason, err := allowedListeners(ctx, r.Client, &gw, route, ref)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, routeParent{ref: ref, gateway: &gw, listeners: listeners, reason: reason})
+	}
+	return parents, nil
+}
+
+// httpRouteCloudflareName は HTTPRoute と Gateway の組から生成する Cloudflare リソースの名前です。
+func httpRouteCloudflareName(route *gatewayv1.HTTPRoute, gw *gatewayv1.Gateway) string {
+	return "httproute-" + route.Name + "-" + gw.Name
+}
+
+// reconcileParent は1つの親 Gateway について Cloudflare リソースを生成し、その親の status を返します。
+// Cloudflare リソースを生成した場合はその名前も返します。
+func (r *HTTPRouteReconciler) reconcileParent(ctx context.Context, route *gatewayv1.HTTPRoute, parent routeParent) (gatewayv1.RouteParentStatus, string, error) {
+	logger := log.FromContext(ctx)
+
+	status := gatewayv1.RouteParentStatus{
+		ParentRef:      parent.ref,
+		ControllerName: GatewayControllerName,
+	}
+	accepted := metav1.Condition{
+		Type:               string(gatewayv1.RouteConditionAccepted),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gatewayv1.RouteReasonAccepted),
+		ObservedGeneration: route.Generation,
+	}
+	resolved := metav1.Condition{
+		Type:               string(gatewayv1.RouteConditionResolvedRefs),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gatewayv1.RouteReasonResolvedRefs),
+		ObservedGeneration: route.Generation,
+	}
+	programmed := metav1.Condition{
+		Type:               string(gatewayv1.GatewayConditionProgrammed),
+		Status:             metav1.ConditionFalse,
+		Reason:             string(gatewayv1.GatewayReasonPending),
+		ObservedGeneration: route.Generation,
+	}
+	setConditions := func() {
+		meta.SetStatusCondition(&status.Conditions, accepted)
+		meta.SetStatusCondition(&status.Conditions, resolved)
+		meta.SetStatusCondition(&status.Conditions, programmed)
+	}
+
+	if len(parent.listeners) == 0 {
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = string(parent.reason)
+		accepted.Message = "no listener of the Gateway accepts this route"
+		programmed.Message = accepted.Message
+		setConditions()
+		return status, "", nil
+	}
+
+	rules, refErrors := httpRouteToRules(route, parent.listeners)
+	if len(refErrors) > 0 {
+		resolved.Status = metav1.ConditionFalse
+		resolved.Reason = string(refErrors[0].reason)
+		resolved.Message = refErrors.Error()
+	}
+	if len(rules) == 0 {
+		accepted.Status = metav1.ConditionFalse
+		accepted.Reason = string(gatewayv1.RouteReasonUnsupportedValue)
+		accepted.Message = "route has no hostname and path that can be routed through the Tunnel"
+		programmed.Message = accepted.Message
+		setConditions()
+		return status, "", nil
+	}
+
+	var tunnel cloudflarev1beta1.Tunnel
+	tunnelKey := types.NamespacedName{Namespace: parent.gateway.Namespace, Name: gatewayTunnelName(parent.gateway)}
+	if err := r.Get(ctx, tunnelKey, &tunnel); err != nil {
+		if !errors.IsNotFound(err) {
+			return status, "", err
+		}
+		programmed.Message = "waiting for the Tunnel of the Gateway"
+		setConditions()
+		return status, "", nil
+	}
+
+	cloudflare := &cloudflarev1beta1.Cloudflare{}
+	cloudflare.SetNamespace(route.Namespace)
+	cloudflare.SetName(httpRouteCloudflareName(route, parent.gateway))
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cloudflare, func() error {
+		if err := checkGeneratedOwner(route, "HTTPRoute", cloudflare); err != nil {
+			return err
+		}
+		if cloudflare.Labels == nil {
+			cloudflare.Labels = map[string]string{}
+		}
+		cloudflare.Labels[httpRouteLabel] = route.Name
+		cloudflare.Labels[gatewayLabel] = parent.gateway.Name
+		cloudflare.Spec.TunnelRef = &cloudflarev1beta1.TunnelReference{
+			Name:      tunnel.Name,
+			Namespace: tunnel.Namespace,
+		}
+		// 認証情報は省略し、DNS レコードは Tunnel の namespace で解決する Tunnel の認証情報で管理する
+		cloudflare.Spec.CredentialsSource = cloudflarev1beta1.CredentialsSource{}
+		cloudflare.Spec.Ingress = rules
+		return ctrl.SetControllerReference(route, cloudflare, r.Scheme)
+	})
+	var conflict *generatedConflictError
+	if goerrors.As(err, &conflict) {
+		logger.Info("HTTPRoute cannot be programmed on the Gateway", "reason", err.Error())
+		programmed.Message = err.Error()
+		setConditions()
+		return status, "", nil
+	}
+	if err != nil {
+		return status, "", err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile Cloudflare resource for HTTPRoute successfully", "op", op, "name", cloudflare.Name)
+	}
+
+	// Service の解決結果は CloudflareReconciler が BackendNotFound 条件に記録する
+	if c := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeBackendNotFound); c != nil && c.Status == metav1.ConditionTrue && resolved.Status == metav1.ConditionTrue {
+		resolved.Status = metav1.ConditionFalse
+		resolved.Reason = string(gatewayv1.RouteReasonBackendNotFound)
+		resolved.Message = c.Message
+	}
+	// ルールが Tunnel の設定に載ったかは CloudflareReconciler が ConfigReady 条件に記録する
+	switch config := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeConfigReady); {
+	case generatedConfigReady(cloudflare):
+		programmed.Status = metav1.ConditionTrue
+		programmed.Reason = string(gatewayv1.GatewayReasonProgrammed)
+	case config == nil || config.ObservedGeneration != cloudflare.Generation:
+		programmed.Message = "waiting for the rules to be applied to the Tunnel of the Gateway"
+	default:
+		programmed.Message = config.Message
+		if config.Reason == "NamespaceNotAllowed" {
+			accepted.Status = metav1.ConditionFalse
+			accepted.Reason = string(gatewayv1.RouteReasonNotAllowedByListeners)
+			accepted.Message = config.Message
+		}
+	}
+	setConditions()
+	return status, cloudflare.Name, nil
+}
+
+// deleteStaleCloudflares は HTTPRoute から生成した Cloudflare リソースのうち、keep に含まれないものを削除します。
+func (r *HTTPRouteReconciler) deleteStaleCloudflares(ctx context.Context, route *gatewayv1.HTTPRoute, keep map[string]bool) error {
+	var list cloudflarev1beta1.CloudflareList
+	if err := r.List(ctx, &list, client.InNamespace(route.Namespace), client.MatchingLabels{httpRouteLabel: route.Name}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		cloudflare := &list.Items[i]
+		if keep[cloudflare.Name] || !metav1.IsControlledBy(cloudflare, route) {
+			continue
+		}
+		if err := client.IgnoreNotFound(r.Delete(ctx, cloudflare)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRouteStatus は status.parents のうち、このオペレータが書き込んだものを statuses で置き換えます。
+func (r *HTTPRouteReconciler) updateRouteStatus(ctx context.Context, route *gatewayv1.HTTPRoute, statuses []gatewayv1.RouteParentStatus) error {
+	var parents []gatewayv1.RouteParentStatus
+	for _, prev := range route.Status.Parents {
+		if prev.ControllerName != GatewayControllerName {
+			parents = append(parents, prev)
+		}
+	}
+	for _, status := range statuses {
+		// 変化のない条件の lastTransitionTime を保つ
+		for _, prev := range route.Status.Parents {
+			if prev.ControllerName != GatewayControllerName || !equality.Semantic.DeepEqual(prev.ParentRef, status.ParentRef) {
+				continue
+			}
+			conditions := append([]metav1.Condition(nil), prev.Conditions...)
+			for _, c := range status.Conditions {
+				meta.SetStatusCondition(&conditions, c)
+			}
+			status.Conditions = conditions
+		}
+		parents = append(parents, status)
+	}
+	if equality.Semantic.DeepEqual(route.Status.Parents, parents) {
+		return nil
+	}
+	route.Status.Parents = parents
+	return r.Status().Update(ctx, route)
+}
+
+// parentGatewayKey は parentRef が Gateway を指していれば、その名前を返します。
+func parentGatewayKey(ref gatewayv1.ParentReference, namespace string) (types.NamespacedName, bool) {
+	if ref.Group != nil && *ref.Group != gatewayv1.GroupName {
+		return types.NamespacedName{}, false
+	}
+	if ref.Kind != nil && *ref.Kind != "Gateway" {
+		return types.NamespacedName{}, false
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: string(ref.Name)}
+	if ref.Namespace != nil && *ref.Namespace != "" {
+		key.Namespace = string(*ref.Namespace)
+	}
+	return key, true
+}
+
+// parentRefersToGateway は parentRef が gw を指しているかを返します。
+func parentRefersToGateway(ref gatewayv1.ParentReference, namespace string, gw *gatewayv1.Gateway) bool {
+	key, ok := parentGatewayKey(ref, namespace)
+	return ok && key == client.ObjectKeyFromObject(gw)
+}
+
+// allowedListeners は parentRef の sectionName と port、リスナーの allowedRoutes とホスト名から、route を受け付けるリスナーを返します。
+// 受け付けるリスナーが無い場合は、その理由を返します。
+func allowedListeners(ctx context.Context, c client.Reader, gw *gatewayv1.Gateway, route *gatewayv1.HTTPRoute, ref gatewayv1.ParentReference) ([]gatewayv1.Listener, gatewayv1.RouteConditionReason, error) {
+	var listeners []gatewayv1.Listener
+	reason := gatewayv1.RouteReasonNotAllowedByListeners
+	for _, l := range gw.Spec.Listeners {
+		if ref.SectionName != nil && *ref.SectionName != l.Name {
+			continue
+		}
+		if ref.Port != nil && *ref.Port != l.Port {
+			continue
+		}
+		if !supportedListenerProtocol(l.Protocol) {
+			continue
+		}
+		ok, err := listenerAllowsRoute(ctx, c, gw, l, route)
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			continue
+		}
+		if len(routeHostnames(l.Hostname, route.Spec.Hostnames)) == 0 && len(route.Spec.Hostnames) > 0 {
+			reason = gatewayv1.RouteReasonNoMatchingListenerHostname
+			continue
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, reason, nil
+}
+
+// listenerAllowsRoute はリスナーの allowedRoutes が route の namespace と種類を許可しているかを返します。
+func listenerAllowsRoute(ctx context.Context, c client.Reader, gw *gatewayv1.Gateway, l gatewayv1.Listener, route *gatewayv1.HTTPRoute) (bool, error) {
+	allowed := l.AllowedRoutes
+	if allowed == nil {
+		return route.Namespace == gw.Namespace, nil
+	}
+	if len(allowed.Kinds) > 0 {
+		found := false
+		for _, kind := range allowed.Kinds {
+			if (kind.Group == nil || *kind.Group == gatewayv1.GroupName) && kind.Kind == "HTTPRoute" {
+				found = true
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	from := gatewayv1.NamespacesFromSame
+	if allowed.Namespaces != nil && allowed.Namespaces.From != nil {
+		from = *allowed.Namespaces.From
+	}
+	switch from {
+	case gatewayv1.NamespacesFromAll:
+		return true, nil
+	case gatewayv1.NamespacesFromSelector:
+		if allowed.Namespaces.Selector == nil {
+			return false, nil
+		}
+		selector, err := metav1.LabelSelectorAsSelector(allowed.Namespaces.Selector)
+		if err != nil {
+			return false, nil
+		}
+		var ns corev1.Namespace
+		if err := c.Get(ctx, client.ObjectKey{Name: route.Namespace}, &ns); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return selector.Matches(labels.Set(ns.Labels)), nil
+	default:
+		return route.Namespace == gw.Namespace, nil
+	}
+}
+
+// routeHostnames はリスナーのホスト名と HTTPRoute の hostnames の共通部分を返します。
+// どちらも省略された場合は、共有 Tunnel では全てのホスト名に一致するルールを作れないため空になります。
+func routeHostnames(listener *gatewayv1.Hostname, hostnames []gatewayv1.Hostname) []string {
+	if len(hostnames) == 0 {
+		if listener == nil || *listener == "" {
+			return nil
+		}
+		return []string{string(*listener)}
+	}
+	var out []string
+	for _, h := range hostnames {
+		if listener == nil || *listener == "" {
+			out = append(out, string(h))
+			continue
+		}
+		if host, ok := intersectHostname(string(*listener), string(h)); ok {
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+// intersectHostname は2つのホスト名の両方に一致する、より限定的な方を返します。
+// "*." で始まるホスト名は1つ以上のラベルに一致します。
+func intersectHostname(a, b string) (string, bool) {
+	if a == b {
+		return a, true
+	}
+	if suffix, ok := strings.CutPrefix(a, "*"); ok && strings.HasSuffix(b, suffix) && len(b) > len(suffix) {
+		return b, true
+	}
+	if suffix, ok := strings.CutPrefix(b, "*"); ok && strings.HasSuffix(a, suffix) && len(a) > len(suffix) {
+		return a, true
+	}
+	return "", false
+}
+
+// backendRefError は HTTPRoute の backendRef を解決できなかった理由です。
+type backendRefError struct {
+	reason  gatewayv1.RouteConditionReason
+	message string
+}
+
+type backendRefErrors []backendRefError
+
+func (errs backendRefErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// httpRouteToRules は HTTPRoute のホスト名とパスの一致条件と backendRefs を cloudflared の ingress ルールに変換します。
+// cloudflared は1つのルールに1つの転送先しか持てないため、重みが 0 でない最初の Service を使います。
+// ヘッダー、クエリ、メソッドの一致条件は cloudflared で表現できないため、それらを含む match は無視します。
+func httpRouteToRules(route *gatewayv1.HTTPRoute, listeners []gatewayv1.Listener) ([]cloudflarev1beta1.IngressRule, backendRefErrors) {
+	var hosts []string
+	seen := map[string]bool{}
+	for _, l := range listeners {
+		for _, host := range routeHostnames(l.Hostname, route.Spec.Hostnames) {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	type pathRule struct {
+		path   string
+		exact  bool
+		length int
+		ref    *cloudflarev1beta1.ServiceReference
+	}
+	var paths []pathRule
+	var refErrors backendRefErrors
+	for _, rule := range route.Spec.Rules {
+		ref, refErr := httpRouteBackendRef(rule.BackendRefs, route.Namespace)
+		if refErr != nil {
+			refErrors = append(refErrors, *refErr)
+		}
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1.HTTPRouteMatch{{}}
+		}
+		for _, m := range matches {
+			if len(m.Headers) > 0 || len(m.QueryParams) > 0 || m.Method != nil {
+				continue
+			}
+			path, exact, length := httpRouteMatchPath(m.Path)
+			paths = append(paths, pathRule{path: path, exact: exact, length: length, ref: ref})
+		}
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i].exact != paths[j].exact {
+			return paths[i].exact
+		}
+		return paths[i].length > paths[j].length
+	})
+
+	var rules []cloudflarev1beta1.IngressRule
+	for _, host := range hosts {
+		for _, p := range paths {
+			rule := cloudflarev1beta1.IngressRule{Hostname: host, Path: p.path}
+			if p.ref != nil {
+				rule.ServiceRef = p.ref.DeepCopy()
+			} else {
+				rule.Service = fmt.Sprintf("http_status:%d", invalidBackendStatus)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, refErrors
+}
+
+// httpRouteMatchPath は HTTPRoute のパスの一致条件を cloudflared の path の正規表現に変換します。
+func httpRouteMatchPath(match *gatewayv1.HTTPPathMatch) (string, bool, int) {
+	matchType := gatewayv1.PathMatchPathPrefix
+	value := "/"
+	if match != nil {
+		if match.Type != nil {
+			matchType = *match.Type
+		}
+		if match.Value != nil {
+			value = *match.Value
+		}
+	}
+	switch matchType {
+	case gatewayv1.PathMatchExact:
+		return ingressPathRegex(value, true), true, len(value)
+	case gatewayv1.PathMatchRegularExpression:
+		if !strings.HasPrefix(value, "^") {
+			value = "^" + value
+		}
+		return value, false, len(value)
+	default:
+		return ingressPathRegex(value, false), false, len(value)
+	}
+}
+
+// httpRouteBackendRef は backendRefs のうち、重みが 0 でない最初の Service を serviceRef に変換します。
+// Service 以外の種類や、ReferenceGrant が必要な別 namespace の Service は扱いません。
+func httpRouteBackendRef(refs []gatewayv1.HTTPBackendRef, namespace string) (*cloudflarev1beta1.ServiceReference, *backendRefError) {
+	var refErr *backendRefError
+	for _, ref := range refs {
+		if ref.Weight != nil && *ref.Weight == 0 {
+			continue
+		}
+		if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+			refErr = &backendRefError{
+				reason:  gatewayv1.RouteReasonInvalidKind,
+				message: fmt.Sprintf("backendRef %s is not a Service", ref.Name),
+			}
+			continue
+		}
+		if ref.Namespace != nil && string(*ref.Namespace) != namespace {
+			refErr = &backendRefError{
+				reason:  gatewayv1.RouteReasonRefNotPermitted,
+				message: fmt.Sprintf("backendRef %s/%s is in another namespace", *ref.Namespace, ref.Name),
+			}
+			continue
+		}
+		if ref.Port == nil {
+			refErr = &backendRefError{
+				reason:  gatewayv1.RouteReasonUnsupportedValue,
+				message: fmt.Sprintf("backendRef %s has no port", ref.Name),
+			}
+			continue
+		}
+		return &cloudflarev1beta1.ServiceReference{
+			Name:   string(ref.Name),
+			Port:   intstr.FromInt32(int32(*ref.Port)),
+			Scheme: defaultServiceScheme,
+		}, nil
+	}
+	return nil, refErr
+}
+
+// routesForGateway は Gateway の変更時に、その Gateway を親に持つ HTTPRoute を再調整対象にします。
+func (r *HTTPRouteReconciler) routesForGateway(ctx context.Context, obj client.Object) []reconcile.Request {
+	gw, ok := obj.(*gatewayv1.Gateway)
+	if !ok {
+		return nil
+	}
+	return r.routesMatching(ctx, func(route *gatewayv1.HTTPRoute) bool {
+		for _, ref := range route.Spec.ParentRefs {
+			if parentRefersToGateway(ref, route.Namespace, gw) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// routesForTunnel は Gateway の Tunnel の変更時（Tunnel ID の確定など）に、その Gateway を親に持つ HTTPRoute を再調整対象にします。
+func (r *HTTPRouteReconciler) routesForTunnel(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[gatewayLabel]
+	if !ok {
+		return nil
+	}
+	gw := &gatewayv1.Gateway{}
+	gw.SetNamespace(obj.GetNamespace())
+	gw.SetName(name)
+	return r.routesForGateway(ctx, gw)
+}
+
+func (r *HTTPRouteReconciler) routesMatching(ctx context.Context, match func(*gatewayv1.HTTPRoute) bool) []reconcile.Request {
+	var list gatewayv1.HTTPRouteList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list HTTPRoutes")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if match(&list.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gatewayv1.HTTPRoute{}).
+		Owns(&cloudflarev1beta1.Cloudflare{}).
+		Watches(&gatewayv1.Gateway{}, handler.EnqueueRequestsFromMapFunc(r.routesForGateway)).
+		Watches(&cloudflarev1beta1.Tunnel{}, handler.EnqueueRequestsFromMapFunc(r.routesForTunnel)).
+		Named("httproute").
+		Complete(r)
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+var _ = Describe("HTTPRoute Controller", func() {
+	Context("When reconciling an HTTPRoute attached to a Cloudflare Gateway", func() {
+		const (
+			className   = "cloudflare-routes"
+			gatewayName = "route-gateway"
+			routeName   = "web"
+		)
+
+		ctx := context.Background()
+
+		routeKey := types.NamespacedName{Name: routeName, Namespace: "default"}
+		cloudflareKey := types.NamespacedName{Name: "httproute-" + routeName + "-" + gatewayName, Namespace: "default"}
+
+		var controllerReconciler *HTTPRouteReconciler
+
+		BeforeEach(func() {
+			controllerReconciler = &HTTPRouteReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("creating the GatewayClass and the Gateway")
+			class := &gatewayv1.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: className},
+				Spec:       gatewayv1.GatewayClassSpec{ControllerName: GatewayControllerName},
+			}
+			err := k8sClient.Create(ctx, class)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			gw := &gatewayv1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Name: gatewayName, Namespace: "default"},
+				Spec: gatewayv1.GatewaySpec{
+					GatewayClassName: className,
+					Listeners: []gatewayv1.Listener{{
+						Name:     "http",
+						Hostname: ptr.To(gatewayv1.Hostname("*.widgetcorp.tech")),
+						Port:     80,
+						Protocol: gatewayv1.HTTPProtocolType,
+					}},
+				},
+			}
+			err = k8sClient.Create(ctx, gw)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating the Tunnel of the Gateway with a Tunnel ID")
+			tunnel := &cloudflarev1beta1.Tunnel{
+				ObjectMeta: metav1.ObjectMeta{Name: "gateway-" + gatewayName, Namespace: "default"},
+				Spec:       cloudflarev1beta1.TunnelSpec{Replicas: 1},
+			}
+			err = k8sClient.Create(ctx, tunnel)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tunnel), tunnel)).To(Succeed())
+			tunnel.Status.TunnelID = "route-tunnel-id"
+			Expect(k8sClient.Status().Update(ctx, tunnel)).To(Succeed())
+
+			By("creating the HTTPRoute")
+			route := &gatewayv1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{Name: routeName, Namespace: "default"},
+				Spec: gatewayv1.HTTPRouteSpec{
+					CommonRouteSpec: gatewayv1.CommonRouteSpec{
+						ParentRefs: []gatewayv1.ParentReference{{Name: gatewayName}},
+					},
+					Hostnames: []gatewayv1.Hostname{"web.widgetcorp.tech", "web.example.com"},
+					Rules: []gatewayv1.HTTPRouteRule{
+						{
+							BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{Name: "web", Port: ptr.To(gatewayv1.PortNumber(80))},
+							}}},
+						},
+						{
+							Matches: []gatewayv1.HTTPRouteMatch{{
+								Path: &gatewayv1.HTTPPathMatch{
+									Type:  ptr.To(gatewayv1.PathMatchPathPrefix),
+									Value: ptr.To("/api"),
+								},
+							}},
+							BackendRefs: []gatewayv1.HTTPBackendRef{{BackendRef: gatewayv1.BackendRef{
+								BackendObjectReference: gatewayv1.BackendObjectReference{Name: "api", Port: ptr.To(gatewayv1.PortNumber(8080))},
+							}}},
+						},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, route)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			route := &gatewayv1.HTTPRoute{}
+			if err := k8sClient.Get(ctx, routeKey, route); err == nil {
+				Expect(k8sClient.Delete(ctx, route)).To(Succeed())
+			}
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			if err := k8sClient.Get(ctx, cloudflareKey, cloudflare); err == nil {
+				cloudflare.Finalizers = nil
+				Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cloudflare))).To(Succeed())
+			}
+		})
+
+		It("should translate the HTTPRoute into a Cloudflare resource on the Gateway Tunnel", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: routeKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.Spec.TunnelRef).To(Equal(&cloudflarev1beta1.TunnelReference{Name: "gateway-" + gatewayName, Namespace: "default"}))
+			// web.example.com does not match the listener hostname
+			Expect(cloudflare.Spec.Ingress).To(Equal([]cloudflarev1beta1.IngressRule{
+				{
+					Hostname:   "web.widgetcorp.tech",
+					Path:       "^/api(/|$)",
+					ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "api", Port: intstr.FromInt32(8080), Scheme: "http"},
+				},
+				{
+					Hostname:   "web.widgetcorp.tech",
+					ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "web", Port: intstr.FromInt32(80), Scheme: "http"},
+				},
+			}))
+
+			route := &gatewayv1.HTTPRoute{}
+			Expect(k8sClient.Get(ctx, routeKey, route)).To(Succeed())
+			Expect(metav1.IsControlledBy(cloudflare, route)).To(BeTrue())
+			Expect(route.Status.Parents).To(HaveLen(1))
+			parent := route.Status.Parents[0]
+			Expect(parent.ControllerName).To(Equal(GatewayControllerName))
+			Expect(meta.IsStatusConditionTrue(parent.Conditions, string(gatewayv1.RouteConditionAccepted))).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(parent.Conditions, string(gatewayv1.RouteConditionResolvedRefs))).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(parent.Conditions, string(gatewayv1.GatewayConditionProgrammed))).To(BeTrue())
+
+			By("applying the rules to the Tunnel")
+			meta.SetStatusCondition(&cloudflare.Status.Conditions, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeConfigReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             "ConfigApplied",
+				ObservedGeneration: cloudflare.Generation,
+			})
+			Expect(k8sClient.Status().Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: routeKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, routeKey, route)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(route.Status.Parents[0].Conditions, string(gatewayv1.GatewayConditionProgrammed))).To(BeTrue())
+
+			By("leaving out rules the Tunnel does not accept")
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			meta.SetStatusCondition(&cloudflare.Status.Conditions, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeConfigReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "HostnameConflict",
+				Message:            "hostname web.widgetcorp.tech is already used by default/other on Tunnel shared",
+				ObservedGeneration: cloudflare.Generation,
+			})
+			Expect(k8sClient.Status().Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: routeKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, routeKey, route)).To(Succeed())
+			programmed := meta.FindStatusCondition(route.Status.Parents[0].Conditions, string(gatewayv1.GatewayConditionProgrammed))
+			Expect(programmed.Status).To(Equal(metav1.ConditionFalse))
+			Expect(programmed.Message).To(ContainSubstring("already used by default/other"))
+		})
+
+		It("should not accept the HTTPRoute when no hostname matches the listener", func() {
+			route := &gatewayv1.HTTPRoute{}
+			Expect(k8sClient.Get(ctx, routeKey, route)).To(Succeed())
+			route.Spec.Hostnames = []gatewayv1.Hostname{"web.example.com"}
+			Expect(k8sClient.Update(ctx, route)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: routeKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, routeKey, route)).To(Succeed())
+			Expect(route.Status.Parents).To(HaveLen(1))
+			accepted := meta.FindStatusCondition(route.Status.Parents[0].Conditions, string(gatewayv1.RouteConditionAccepted))
+			Expect(accepted.Status).To(Equal(metav1.ConditionFalse))
+			Expect(accepted.Reason).To(Equal(string(gatewayv1.RouteReasonNoMatchingListenerHostname)))
+		})
+	})
+})
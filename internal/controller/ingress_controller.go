@@ -0,0 +1,434 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+const (
+	// IngressClassController は、このオペレータが処理する IngressClass の spec.controller の値です。
+	IngressClassController = "cloudflare.laininthewired.github.io/tunnel-controller"
+
+	// defaultIngressClassAnnotation が "true" の IngressClass は、ingressClassName を省略した Ingress に使われます。
+	defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+	// ingressLabel は Ingress から生成した Cloudflare リソースに、元の Ingress 名を記録します。
+	ingressLabel = "cloudflare.laininthewired.github.io/ingress"
+)
+
+// IngressReconciler reconciles a networking.k8s.io/v1 Ingress object
+//
+// IngressClass の parameters で参照する Tunnel ごとに、Ingress を tunnelRef 付きの Cloudflare リソースに変換します。
+// 設定の描画、DNS レコード、serviceRef の解決は、生成した Cloudflare リソースを通して CloudflareReconciler が行います。
+type IngressReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder は生成する Cloudflare リソースとの名前の衝突を Ingress の Event として記録します。nil の場合は記録しません。
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingressclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=cloudflares,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels,verbs=get;list;watch
+
+// Reconcile は Ingress から Cloudflare リソースを生成し、Tunnel のホスト名を Ingress の status.loadBalancer に書き込みます。
+// このオペレータの IngressClass を使わなくなった Ingress からは、生成した Cloudflare リソースを削除します。
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var ing networkingv1.Ingress
+	err := r.Get(ctx, req.NamespacedName, &ing)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to get Ingress", "name", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	if !ing.DeletionTimestamp.IsZero() {
+		// 生成した Cloudflare リソースは ownerReference で削除される
+		return ctrl.Result{}, nil
+	}
+
+	tunnelKey, ok, err := r.tunnelForIngress(ctx, &ing)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !ok {
+		return ctrl.Result{}, r.deleteIngressCloudflare(ctx, &ing)
+	}
+
+	var tunnel cloudflarev1beta1.Tunnel
+	if err := r.Get(ctx, tunnelKey, &tunnel); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("waiting for the Tunnel referenced by the IngressClass", "tunnel", tunnelKey)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	events := objectEvents{recorder: r.Recorder, object: &ing}
+	rules, skipped := ingressToRules(&ing)
+	if len(skipped) > 0 {
+		logger.Info("skipped Ingress rules without a host", "paths", skipped)
+		events.warning(eventHostnameRequired, "Skipped the rules without a host, which every rule on Tunnel %s needs: %s", tunnelKey, strings.Join(skipped, ", "))
+	}
+	if len(rules) == 0 {
+		logger.Info("Ingress has no rules that can be routed through the Tunnel")
+		if err := r.deleteIngressCloudflare(ctx, &ing); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.updateIngressStatus(ctx, &ing, "")
+	}
+	cloudflare, err := r.reconcileIngressCloudflare(ctx, &ing, &tunnel, rules)
+	var conflict *generatedConflictError
+	if goerrors.As(err, &conflict) {
+		logger.Info("Ingress cannot be exposed through the Tunnel", "reason", err.Error())
+		events.warning(eventResourceConflict, "%v", err)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to reconcile Cloudflare resource for Ingress")
+		return ctrl.Result{}, err
+	}
+
+	// 生成した Cloudflare リソースのルールが Tunnel の設定に載るまでは、Tunnel のホスト名を公開しない
+	tunnelID := cloudflare.Status.TunnelID
+	if !generatedConfigReady(cloudflare) {
+		tunnelID = ""
+	}
+	return ctrl.Result{}, r.updateIngressStatus(ctx, &ing, tunnelID)
+}
+
+// tunnelForIngress は Ingress の IngressClass がこのオペレータのものであれば、parameters で参照する Tunnel を返します。
+func (r *IngressReconciler) tunnelForIngress(ctx context.Context, ing *networkingv1.Ingress) (types.NamespacedName, bool, error) {
+	class, err := r.ingressClass(ctx, ing)
+	if err != nil || class == nil {
+		return types.NamespacedName{}, false, err
+	}
+	return ingressClassTunnel(class, ing.Namespace)
+}
+
+// ingressClass は Ingress が使う IngressClass を返します。ingressClassName を省略した場合は既定の IngressClass です。
+func (r *IngressReconciler) ingressClass(ctx context.Context, ing *networkingv1.Ingress) (*networkingv1.IngressClass, error) {
+	if ing.Spec.IngressClassName != nil {
+		var class networkingv1.IngressClass
+		err := r.Get(ctx, client.ObjectKey{Name: *ing.Spec.IngressClassName}, &class)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &class, nil
+	}
+
+	var classes networkingv1.IngressClassList
+	if err := r.List(ctx, &classes); err != nil {
+		return nil, err
+	}
+	for i := range classes.Items {
+		if classes.Items[i].Annotations[defaultIngressClassAnnotation] == "true" {
+			return &classes.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ingressClassTunnel は IngressClass の parameters から Tunnel を返します。
+// parameters の namespace を省略した場合は Ingress と同じ namespace の Tunnel です。
+// Ingress と異なる namespace の Tunnel は、その allowedNamespaces に Ingress の namespace がある場合だけ使えます。
+func ingressClassTunnel(class *networkingv1.IngressClass, namespace string) (types.NamespacedName, bool, error) {
+	if class.Spec.Controller != IngressClassController {
+		return types.NamespacedName{}, false, nil
+	}
+	params := class.Spec.Parameters
+	if params == nil || params.APIGroup == nil || *params.APIGroup != cloudflarev1beta1.GroupVersion.Group || params.Kind != "Tunnel" {
+		return types.NamespacedName{}, false, nil
+	}
+	key := types.NamespacedName{Namespace: namespace, Name: params.Name}
+	if params.Namespace != nil && *params.Namespace != "" {
+		key.Namespace = *params.Namespace
+	}
+	return key, true, nil
+}
+
+// ingressCloudflareName は Ingress から生成する Cloudflare リソースの名前です。
+func ingressCloudflareName(ing *networkingv1.Ingress) string {
+	return "ingress-" + ing.Name
+}
+
+// reconcileIngressCloudflare は Ingress のルールを tunnelRef 付きの Cloudflare リソースに書き込み、Ingress に所有させます。
+// 同じ名前のリソースを Ingress が所有していない場合は変更せずに generatedConflictError を返します。
+func (r *IngressReconciler) reconcileIngressCloudflare(ctx context.Context, ing *networkingv1.Ingress, tunnel *cloudflarev1beta1.Tunnel, rules []cloudflarev1beta1.IngressRule) (*cloudflarev1beta1.Cloudflare, error) {
+	logger := log.FromContext(ctx)
+
+	cloudflare := &cloudflarev1beta1.Cloudflare{}
+	cloudflare.SetNamespace(ing.Namespace)
+	cloudflare.SetName(ingressCloudflareName(ing))
+
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cloudflare, func() error {
+		if err := checkGeneratedOwner(ing, "Ingress", cloudflare); err != nil {
+			return err
+		}
+		if cloudflare.Labels == nil {
+			cloudflare.Labels = map[string]string{}
+		}
+		cloudflare.Labels[ingressLabel] = ing.Name
+		cloudflare.Spec.TunnelRef = &cloudflarev1beta1.TunnelReference{
+			Name:      tunnel.Name,
+			Namespace: tunnel.Namespace,
+		}
+		// 認証情報は省略し、DNS レコードは Tunnel の namespace で解決する Tunnel の認証情報で管理する
+		cloudflare.Spec.CredentialsSource = cloudflarev1beta1.CredentialsSource{}
+		cloudflare.Spec.Ingress = rules
+		return ctrl.SetControllerReference(ing, cloudflare, r.Scheme)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile Cloudflare resource for Ingress successfully", "op", op, "name", cloudflare.Name)
+	}
+	return cloudflare, nil
+}
+
+// deleteIngressCloudflare は Ingress から生成した Cloudflare リソースが残っていれば削除します。
+func (r *IngressReconciler) deleteIngressCloudflare(ctx context.Context, ing *networkingv1.Ingress) error {
+	var cloudflare cloudflarev1beta1.Cloudflare
+	err := r.Get(ctx, client.ObjectKey{Namespace: ing.Namespace, Name: ingressCloudflareName(ing)}, &cloudflare)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(&cloudflare, ing) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, &cloudflare))
+}
+
+// updateIngressStatus は Tunnel のホスト名（<tunnelID>.cfargotunnel.com）を Ingress の status.loadBalancer に書き込みます。
+// tunnelID が空の場合は、以前に書き込んだホスト名を消します。
+func (r *IngressReconciler) updateIngressStatus(ctx context.Context, ing *networkingv1.Ingress, tunnelID string) error {
+	var desired []networkingv1.IngressLoadBalancerIngress
+	if tunnelID != "" {
+		desired = []networkingv1.IngressLoadBalancerIngress{{Hostname: tunnelID + ".cfargotunnel.com"}}
+	}
+	if len(ing.Status.LoadBalancer.Ingress) == 0 && len(desired) == 0 ||
+		equality.Semantic.DeepEqual(ing.Status.LoadBalancer.Ingress, desired) {
+		return nil
+	}
+	ing.Status.LoadBalancer.Ingress = desired
+	return r.Status().Update(ctx, ing)
+}
+
+// ingressToRules は Ingress の host/path/backend を cloudflared の ingress ルールに変換します。
+// cloudflared は先頭から順に一致を調べるため、同じホストの中では Exact を先に、長いパスを先に並べます。
+// defaultBackend は "/" のルールが無いホストの最後のルールとして追加します。
+// host の無いルールは共有 Tunnel の他のリソースのホスト名にも一致してしまうため除き、そのパスを skipped で返します。
+func ingressToRules(ing *networkingv1.Ingress) ([]cloudflarev1beta1.IngressRule, []string) {
+	type pathRule struct {
+		rule     cloudflarev1beta1.IngressRule
+		exact    bool
+		length   int
+		hostRank int
+	}
+	var rules []pathRule
+	hostRank := map[string]int{}
+	for _, ingRule := range ing.Spec.Rules {
+		if _, ok := hostRank[ingRule.Host]; !ok {
+			hostRank[ingRule.Host] = len(hostRank)
+		}
+		if ingRule.HTTP == nil {
+			continue
+		}
+		for _, p := range ingRule.HTTP.Paths {
+			ref := ingressBackendRef(p.Backend)
+			if ref == nil {
+				continue
+			}
+			exact := p.PathType != nil && *p.PathType == networkingv1.PathTypeExact
+			rules = append(rules, pathRule{
+				rule: cloudflarev1beta1.IngressRule{
+					Hostname:   ingRule.Host,
+					Path:       ingressPathRegex(p.Path, exact),
+					ServiceRef: ref,
+				},
+				exact:    exact,
+				length:   len(p.Path),
+				hostRank: hostRank[ingRule.Host],
+			})
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.hostRank != b.hostRank {
+			return a.hostRank < b.hostRank
+		}
+		if a.exact != b.exact {
+			return a.exact
+		}
+		return a.length > b.length
+	})
+
+	var out []cloudflarev1beta1.IngressRule
+	var skipped []string
+	coversHost := map[string]bool{}
+	for _, pr := range rules {
+		if pr.rule.Hostname == "" {
+			skipped = append(skipped, ingressRulePath(pr.rule))
+			continue
+		}
+		if pr.rule.Path == "" {
+			coversHost[pr.rule.Hostname] = true
+		}
+		out = append(out, pr.rule)
+	}
+
+	if ing.Spec.DefaultBackend != nil {
+		if ref := ingressBackendRef(*ing.Spec.DefaultBackend); ref != nil {
+			hosts := make([]string, len(hostRank))
+			for host, rank := range hostRank {
+				hosts[rank] = host
+			}
+			for _, host := range hosts {
+				if host == "" || coversHost[host] {
+					continue
+				}
+				out = append(out, cloudflarev1beta1.IngressRule{Hostname: host, ServiceRef: ref.DeepCopy()})
+			}
+			if len(hosts) == 0 || slices.Contains(hosts, "") {
+				skipped = append(skipped, "defaultBackend")
+			}
+		}
+	}
+	return out, skipped
+}
+
+// ingressRulePath は Event に記録する、host の無いルールのパスです。
+func ingressRulePath(rule cloudflarev1beta1.IngressRule) string {
+	if rule.Path == "" {
+		return "/"
+	}
+	return rule.Path
+}
+
+// ingressBackendRef は Ingress の backend を serviceRef に変換します。Service 以外の backend は扱いません。
+func ingressBackendRef(backend networkingv1.IngressBackend) *cloudflarev1beta1.ServiceReference {
+	if backend.Service == nil {
+		return nil
+	}
+	port := intstr.FromInt32(backend.Service.Port.Number)
+	if backend.Service.Port.Name != "" {
+		port = intstr.FromString(backend.Service.Port.Name)
+	}
+	return &cloudflarev1beta1.ServiceReference{
+		Name:   backend.Service.Name,
+		Port:   port,
+		Scheme: defaultServiceScheme,
+	}
+}
+
+// ingressPathRegex は Ingress のパスを cloudflared の path の正規表現に変換します。
+// Prefix（と ImplementationSpecific）はパスの区切り単位で前方一致させます。"/" は全てのパスに一致するため空にします。
+func ingressPathRegex(path string, exact bool) string {
+	if exact {
+		return "^" + regexp.QuoteMeta(path) + "$"
+	}
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "^" + regexp.QuoteMeta(path) + "(/|$)"
+}
+
+// ingressesForClass は IngressClass の変更時に、その IngressClass を使う Ingress を再調整対象にします。
+func (r *IngressReconciler) ingressesForClass(ctx context.Context, obj client.Object) []reconcile.Request {
+	class, ok := obj.(*networkingv1.IngressClass)
+	if !ok {
+		return nil
+	}
+	return r.ingressesMatching(ctx, func(ing *networkingv1.Ingress) bool {
+		if ing.Spec.IngressClassName != nil {
+			return *ing.Spec.IngressClassName == class.Name
+		}
+		return class.Annotations[defaultIngressClassAnnotation] == "true"
+	})
+}
+
+// ingressesForTunnel は Tunnel の変更時（Tunnel ID の確定など）に、その Tunnel を使う Ingress を再調整対象にします。
+func (r *IngressReconciler) ingressesForTunnel(ctx context.Context, obj client.Object) []reconcile.Request {
+	tunnelKey := client.ObjectKeyFromObject(obj)
+	return r.ingressesMatching(ctx, func(ing *networkingv1.Ingress) bool {
+		key, ok, err := r.tunnelForIngress(ctx, ing)
+		return err == nil && ok && key == tunnelKey
+	})
+}
+
+func (r *IngressReconciler) ingressesMatching(ctx context.Context, match func(*networkingv1.Ingress) bool) []reconcile.Request {
+	var list networkingv1.IngressList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Ingresses")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if match(&list.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&networkingv1.Ingress{}).
+		Owns(&cloudflarev1beta1.Cloudflare{}).
+		Watches(&networkingv1.IngressClass{}, handler.EnqueueRequestsFromMapFunc(r.ingressesForClass)).
+		Watches(&cloudflarev1beta1.Tunnel{}, handler.EnqueueRequestsFromMapFunc(r.ingressesForTunnel)).
+		Named("ingress").
+		Complete(r)
+}
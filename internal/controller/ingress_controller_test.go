@@ -0,0 +1,293 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+var _ = Describe("Ingress Controller", func() {
+	Context("When reconciling an Ingress of the Cloudflare IngressClass", func() {
+		const (
+			className   = "cloudflare"
+			tunnelName  = "ingress-tunnel"
+			ingressName = "web"
+		)
+
+		ctx := context.Background()
+
+		ingressKey := types.NamespacedName{Name: ingressName, Namespace: "default"}
+		cloudflareKey := types.NamespacedName{Name: "ingress-" + ingressName, Namespace: "default"}
+
+		var controllerReconciler *IngressReconciler
+
+		BeforeEach(func() {
+			controllerReconciler = &IngressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("creating the IngressClass pointing at a Tunnel")
+			class := &networkingv1.IngressClass{
+				ObjectMeta: metav1.ObjectMeta{Name: className},
+				Spec: networkingv1.IngressClassSpec{
+					Controller: IngressClassController,
+					Parameters: &networkingv1.IngressClassParametersReference{
+						APIGroup:  ptr.To(cloudflarev1beta1.GroupVersion.Group),
+						Kind:      "Tunnel",
+						Name:      tunnelName,
+						Namespace: ptr.To("default"),
+						Scope:     ptr.To(networkingv1.IngressClassParametersReferenceScopeNamespace),
+					},
+				},
+			}
+			err := k8sClient.Create(ctx, class)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating the Tunnel with a Tunnel ID")
+			tunnel := &cloudflarev1beta1.Tunnel{
+				ObjectMeta: metav1.ObjectMeta{Name: tunnelName, Namespace: "default"},
+				Spec:       cloudflarev1beta1.TunnelSpec{Replicas: 1},
+			}
+			err = k8sClient.Create(ctx, tunnel)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tunnel), tunnel)).To(Succeed())
+			tunnel.Status.TunnelID = "ingress-tunnel-id"
+			Expect(k8sClient.Status().Update(ctx, tunnel)).To(Succeed())
+
+			By("creating the Ingress")
+			pathType := networkingv1.PathTypePrefix
+			exact := networkingv1.PathTypeExact
+			ing := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: ingressName, Namespace: "default"},
+				Spec: networkingv1.IngressSpec{
+					IngressClassName: ptr.To(className),
+					Rules: []networkingv1.IngressRule{{
+						Host: "web.widgetcorp.tech",
+						IngressRuleValue: networkingv1.IngressRuleValue{
+							HTTP: &networkingv1.HTTPIngressRuleValue{
+								Paths: []networkingv1.HTTPIngressPath{
+									{
+										Path:     "/",
+										PathType: &pathType,
+										Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+											Name: "web", Port: networkingv1.ServiceBackendPort{Name: "http"},
+										}},
+									},
+									{
+										Path:     "/api",
+										PathType: &pathType,
+										Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+											Name: "api", Port: networkingv1.ServiceBackendPort{Number: 8080},
+										}},
+									},
+									{
+										Path:     "/healthz",
+										PathType: &exact,
+										Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+											Name: "api", Port: networkingv1.ServiceBackendPort{Number: 8081},
+										}},
+									},
+								},
+							},
+						},
+					}},
+				},
+			}
+			err = k8sClient.Create(ctx, ing)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			ing := &networkingv1.Ingress{}
+			if err := k8sClient.Get(ctx, ingressKey, ing); err == nil {
+				Expect(k8sClient.Delete(ctx, ing)).To(Succeed())
+			}
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			if err := k8sClient.Get(ctx, cloudflareKey, cloudflare); err == nil {
+				cloudflare.Finalizers = nil
+				Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cloudflare))).To(Succeed())
+			}
+		})
+
+		It("should translate the Ingress into a Cloudflare resource on the Tunnel", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.Spec.TunnelRef).To(Equal(&cloudflarev1beta1.TunnelReference{Name: tunnelName, Namespace: "default"}))
+			Expect(cloudflare.Spec.Ingress).To(Equal([]cloudflarev1beta1.IngressRule{
+				{
+					Hostname:   "web.widgetcorp.tech",
+					Path:       "^/healthz$",
+					ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "api", Port: intstr.FromInt32(8081), Scheme: "http"},
+				},
+				{
+					Hostname:   "web.widgetcorp.tech",
+					Path:       "^/api(/|$)",
+					ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "api", Port: intstr.FromInt32(8080), Scheme: "http"},
+				},
+				{
+					Hostname:   "web.widgetcorp.tech",
+					ServiceRef: &cloudflarev1beta1.ServiceReference{Name: "web", Port: intstr.FromString("http"), Scheme: "http"},
+				},
+			}))
+
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			Expect(metav1.IsControlledBy(cloudflare, ing)).To(BeTrue())
+			Expect(ing.Status.LoadBalancer.Ingress).To(BeEmpty())
+
+			By("publishing the Tunnel once the rules are on the Tunnel")
+			cloudflare.Status.TunnelID = "ingress-tunnel-id"
+			meta.SetStatusCondition(&cloudflare.Status.Conditions, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeConfigReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             "ConfigApplied",
+				ObservedGeneration: cloudflare.Generation,
+			})
+			Expect(k8sClient.Status().Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			Expect(ing.Status.LoadBalancer.Ingress).To(Equal([]networkingv1.IngressLoadBalancerIngress{
+				{Hostname: "ingress-tunnel-id.cfargotunnel.com"},
+			}))
+
+			By("withdrawing the Tunnel when the Tunnel does not take the rules")
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			meta.SetStatusCondition(&cloudflare.Status.Conditions, metav1.Condition{
+				Type:               cloudflarev1beta1.TypeConfigReady,
+				Status:             metav1.ConditionFalse,
+				Reason:             "NamespaceNotAllowed",
+				ObservedGeneration: cloudflare.Generation,
+			})
+			Expect(k8sClient.Status().Update(ctx, cloudflare)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			Expect(ing.Status.LoadBalancer.Ingress).To(BeEmpty())
+		})
+
+		It("should skip the rules without a host", func() {
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			pathType := networkingv1.PathTypePrefix
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/admin",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+								Name: "admin", Port: networkingv1.ServiceBackendPort{Number: 80},
+							}},
+						}},
+					},
+				},
+			})
+			Expect(k8sClient.Update(ctx, ing)).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler.Recorder = recorder
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.Spec.Ingress).To(HaveLen(3))
+			for _, rule := range cloudflare.Spec.Ingress {
+				Expect(rule.Hostname).To(Equal("web.widgetcorp.tech"))
+			}
+			Expect(drainEvents(recorder)).To(ContainElement(And(ContainSubstring("HostnameRequired"), ContainSubstring("^/admin(/|$)"))))
+		})
+
+		It("should remove the Cloudflare resource when the Ingress leaves the IngressClass", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})).To(Succeed())
+
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			ing.Spec.IngressClassName = ptr.To("other")
+			Expect(k8sClient.Update(ctx, ing)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should leave a Cloudflare resource with the same name that the Ingress does not own", func() {
+			By("creating a Cloudflare resource with the generated name by hand")
+			existing := &cloudflarev1beta1.Cloudflare{
+				ObjectMeta: metav1.ObjectMeta{Name: cloudflareKey.Name, Namespace: cloudflareKey.Namespace},
+				Spec: cloudflarev1beta1.CloudflareSpec{
+					TunnelName: "hand-made",
+					Replicas:   1,
+					Ingress:    []cloudflarev1beta1.IngressRule{{Hostname: "hand.widgetcorp.tech", Service: "http://hand:80"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler.Recorder = recorder
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.OwnerReferences).To(BeEmpty())
+			Expect(cloudflare.Spec.TunnelRef).To(BeNil())
+			Expect(cloudflare.Spec.Ingress).To(Equal(existing.Spec.Ingress))
+			Expect(drainEvents(recorder)).To(ContainElement(ContainSubstring("ResourceConflict")))
+
+			By("keeping the resource when the Ingress leaves the IngressClass")
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, ingressKey, ing)).To(Succeed())
+			ing.Spec.IngressClassName = ptr.To("other")
+			Expect(k8sClient.Update(ctx, ing)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: ingressKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+// Cloudflare リソースごとの管理対象の数です。ラベルは Cloudflare リソースの namespace と name です。
+var (
+	managedTunnelsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudflared_operator_managed_tunnels",
+		Help: "Number of Cloudflare tunnels used by the Cloudflare resource.",
+	}, []string{"namespace", "name"})
+
+	managedHostnamesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudflared_operator_managed_hostnames",
+		Help: "Number of hostnames in the ingress rules of the Cloudflare resource.",
+	}, []string{"namespace", "name"})
+
+	managedDNSRecordsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudflared_operator_managed_dns_records",
+		Help: "Number of DNS records of the Cloudflare resource that are in sync.",
+	}, []string{"namespace", "name"})
+
+	outOfSyncDNSRecordsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudflared_operator_out_of_sync_dns_records",
+		Help: "Number of DNS records of the Cloudflare resource that are in conflict, drifted or failed to reconcile.",
+	}, []string{"namespace", "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(managedTunnelsGauge, managedHostnamesGauge, managedDNSRecordsGauge, outOfSyncDNSRecordsGauge)
+}
+
+// recordCloudflareMetrics は Cloudflare リソースの status から管理対象の数を記録します。
+func recordCloudflareMetrics(namespace, name string, status *cloudflarev1beta1.CloudflareStatus) {
+	tunnels, synced, outOfSync := 0, 0, 0
+	if status.TunnelID != "" {
+		tunnels = 1
+	}
+	for _, hostname := range status.Hostnames {
+		switch hostname.State {
+		case cloudflarev1beta1.DNSRecordSynced:
+			synced++
+		case cloudflarev1beta1.DNSRecordConflict, cloudflarev1beta1.DNSRecordError, cloudflarev1beta1.DNSRecordDrifted:
+			outOfSync++
+		}
+	}
+	managedTunnelsGauge.WithLabelValues(namespace, name).Set(float64(tunnels))
+	managedHostnamesGauge.WithLabelValues(namespace, name).Set(float64(len(status.Hostnames)))
+	managedDNSRecordsGauge.WithLabelValues(namespace, name).Set(float64(synced))
+	outOfSyncDNSRecordsGauge.WithLabelValues(namespace, name).Set(float64(outOfSync))
+}
+
+// deleteCloudflareMetrics は削除された Cloudflare リソースのメトリクスを取り除きます。
+func deleteCloudflareMetrics(namespace, name string) {
+	for _, gauge := range []*prometheus.GaugeVec{managedTunnelsGauge, managedHostnamesGauge, managedDNSRecordsGauge, outOfSyncDNSRecordsGauge} {
+		gauge.DeleteLabelValues(namespace, name)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+const (
+	// ServiceHostnameAnnotation は Service を公開するホスト名です。カンマ区切りで複数指定できます。
+	ServiceHostnameAnnotation = "cloudflare.laininthewired.github.io/hostname"
+
+	// ServiceTunnelAnnotation は Service を公開する Tunnel リソースです。"name" または "namespace/name" で指定します。
+	// Service と異なる namespace の Tunnel は、その allowedNamespaces に Service の namespace がある場合だけ使えます。
+	ServiceTunnelAnnotation = "cloudflare.laininthewired.github.io/tunnel"
+
+	// ServicePortAnnotation は転送先のポートの名前か番号です。省略した場合は Service の最初のポートを使います。
+	ServicePortAnnotation = "cloudflare.laininthewired.github.io/port"
+
+	// ServiceSchemeAnnotation は cloudflared が Service に接続する時のスキームです。省略した場合は http です。
+	ServiceSchemeAnnotation = "cloudflare.laininthewired.github.io/scheme"
+
+	// serviceLabel は Service から生成した Cloudflare リソースに、元の Service 名を記録します。
+	serviceLabel = "cloudflare.laininthewired.github.io/service"
+)
+
+// ServiceReconciler reconciles a core/v1 Service object
+//
+// アノテーションの付いた Service を、アノテーションで指定した Tunnel を参照する Cloudflare リソースに変換します。
+// 設定の描画と DNS レコードの作成・削除は、生成した Cloudflare リソースを通して CloudflareReconciler が行います。
+type ServiceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder は生成する Cloudflare リソースとの名前の衝突と、正しくないアノテーションを Service の Event として記録します。nil の場合は記録しません。
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=cloudflares,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels,verbs=get;list;watch
+
+// Reconcile はアノテーションから Cloudflare リソースを生成します。
+// Tunnel が Service の namespace を許可しているかは、Ingress や HTTPRoute と同じく生成した Cloudflare リソースの ConfigReady 条件で報告します。
+// アノテーションの値が正しくない場合は、生成済みの Cloudflare リソースを残して Service に Warning の Event を記録します。
+// アノテーションを外した Service からは、生成した Cloudflare リソースを削除します（DNS レコードはその Finalizer で削除されます）。
+func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var svc corev1.Service
+	err := r.Get(ctx, req.NamespacedName, &svc)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to get Service", "name", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+	if !svc.DeletionTimestamp.IsZero() {
+		// 生成した Cloudflare リソースは ownerReference で削除される
+		return ctrl.Result{}, nil
+	}
+
+	tunnelKey, ok := serviceTunnel(&svc)
+	if !ok {
+		return ctrl.Result{}, r.deleteServiceCloudflare(ctx, &svc)
+	}
+	events := objectEvents{recorder: r.Recorder, object: &svc}
+	rules, err := serviceToRules(&svc)
+	if err != nil {
+		logger.Info("Service annotations cannot be exposed through the Tunnel", "reason", err.Error())
+		events.warning(eventInvalidAnnotation, "Left the Cloudflare resource %s as it was: %v", serviceCloudflareName(&svc), err)
+		return ctrl.Result{}, nil
+	}
+
+	var tunnel cloudflarev1beta1.Tunnel
+	if err := r.Get(ctx, tunnelKey, &tunnel); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("waiting for the Tunnel referenced by the Service", "tunnel", tunnelKey)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	err = r.reconcileServiceCloudflare(ctx, &svc, &tunnel, rules)
+	var conflict *generatedConflictError
+	if goerrors.As(err, &conflict) {
+		logger.Info("Service cannot be exposed through the Tunnel", "reason", err.Error())
+		events.warning(eventResourceConflict, "%v", err)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to reconcile Cloudflare resource for Service")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// serviceTunnel は Service のアノテーションから公開先の Tunnel を返します。
+// ホスト名と Tunnel の両方のアノテーションがある場合だけ公開します。
+func serviceTunnel(svc *corev1.Service) (types.NamespacedName, bool) {
+	if strings.TrimSpace(svc.Annotations[ServiceHostnameAnnotation]) == "" {
+		return types.NamespacedName{}, false
+	}
+	ref := strings.TrimSpace(svc.Annotations[ServiceTunnelAnnotation])
+	if ref == "" {
+		return types.NamespacedName{}, false
+	}
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: ref}
+	if namespace, name, found := strings.Cut(ref, "/"); found {
+		key = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	return key, true
+}
+
+// serviceToRules はホスト名のアノテーションごとに、Service を転送先とする ingress ルールを作ります。
+func serviceToRules(svc *corev1.Service) ([]cloudflarev1beta1.IngressRule, error) {
+	var port intstr.IntOrString
+	switch value := strings.TrimSpace(svc.Annotations[ServicePortAnnotation]); {
+	case value != "":
+		port = intstr.Parse(value)
+	case len(svc.Spec.Ports) > 0:
+		port = intstr.FromInt32(svc.Spec.Ports[0].Port)
+	default:
+		return nil, fmt.Errorf("service has no ports, set the %s annotation", ServicePortAnnotation)
+	}
+	scheme := strings.TrimSpace(svc.Annotations[ServiceSchemeAnnotation])
+	if scheme == "" {
+		scheme = defaultServiceScheme
+	}
+	switch scheme {
+	case "http", "https", "tcp", "ssh", "rdp":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in the %s annotation", scheme, ServiceSchemeAnnotation)
+	}
+
+	var rules []cloudflarev1beta1.IngressRule
+	for _, host := range strings.Split(svc.Annotations[ServiceHostnameAnnotation], ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		rules = append(rules, cloudflarev1beta1.IngressRule{
+			Hostname: host,
+			ServiceRef: &cloudflarev1beta1.ServiceReference{
+				Name:   svc.Name,
+				Port:   port,
+				Scheme: scheme,
+			},
+		})
+	}
+	return rules, nil
+}
+
+// serviceCloudflareName は Service から生成する Cloudflare リソースの名前です。
+func serviceCloudflareName(svc *corev1.Service) string {
+	return "service-" + svc.Name
+}
+
+// reconcileServiceCloudflare は Service のルールを tunnelRef 付きの Cloudflare リソースに書き込み、Service に所有させます。
+// 同じ名前のリソースを Service が所有していない場合は変更せずに generatedConflictError を返します。
+func (r *ServiceReconciler) reconcileServiceCloudflare(ctx context.Context, svc *corev1.Service, tunnel *cloudflarev1beta1.Tunnel, rules []cloudflarev1beta1.IngressRule) error {
+	logger := log.FromContext(ctx)
+
+	cloudflare := &cloudflarev1beta1.Cloudflare{}
+	cloudflare.SetNamespace(svc.Namespace)
+	cloudflare.SetName(serviceCloudflareName(svc))
+
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cloudflare, func() error {
+		if err := checkGeneratedOwner(svc, "Service", cloudflare); err != nil {
+			return err
+		}
+		if cloudflare.Labels == nil {
+			cloudflare.Labels = map[string]string{}
+		}
+		cloudflare.Labels[serviceLabel] = svc.Name
+		cloudflare.Spec.TunnelRef = &cloudflarev1beta1.TunnelReference{
+			Name:      tunnel.Name,
+			Namespace: tunnel.Namespace,
+		}
+		// 認証情報は省略し、DNS レコードは Tunnel の namespace で解決する Tunnel の認証情報で管理する
+		cloudflare.Spec.CredentialsSource = cloudflarev1beta1.CredentialsSource{}
+		cloudflare.Spec.Ingress = rules
+		return ctrl.SetControllerReference(svc, cloudflare, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile Cloudflare resource for Service successfully", "op", op, "name", cloudflare.Name)
+	}
+	return nil
+}
+
+// deleteServiceCloudflare は Service から生成した Cloudflare リソースが残っていれば削除します。
+func (r *ServiceReconciler) deleteServiceCloudflare(ctx context.Context, svc *corev1.Service) error {
+	var cloudflare cloudflarev1beta1.Cloudflare
+	err := r.Get(ctx, client.ObjectKey{Namespace: svc.Namespace, Name: serviceCloudflareName(svc)}, &cloudflare)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(&cloudflare, svc) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, &cloudflare))
+}
+
+// servicesForTunnel は Tunnel の変更時に、その Tunnel で公開している Service を再調整対象にします。
+func (r *ServiceReconciler) servicesForTunnel(ctx context.Context, obj client.Object) []reconcile.Request {
+	tunnelKey := client.ObjectKeyFromObject(obj)
+	var list corev1.ServiceList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Services")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if key, ok := serviceTunnel(&list.Items[i]); ok && key == tunnelKey {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Service{}).
+		Owns(&cloudflarev1beta1.Cloudflare{}).
+		Watches(&cloudflarev1beta1.Tunnel{}, handler.EnqueueRequestsFromMapFunc(r.servicesForTunnel)).
+		Named("service").
+		Complete(r)
+}
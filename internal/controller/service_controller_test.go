@@ -0,0 +1,215 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+)
+
+var _ = Describe("Service Controller", func() {
+	Context("When reconciling an annotated Service", func() {
+		const (
+			tunnelName  = "service-tunnel"
+			serviceName = "app"
+		)
+
+		ctx := context.Background()
+
+		serviceKey := types.NamespacedName{Name: serviceName, Namespace: "default"}
+		cloudflareKey := types.NamespacedName{Name: "service-" + serviceName, Namespace: "default"}
+
+		var controllerReconciler *ServiceReconciler
+
+		BeforeEach(func() {
+			controllerReconciler = &ServiceReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("creating the Tunnel")
+			tunnel := &cloudflarev1beta1.Tunnel{
+				ObjectMeta: metav1.ObjectMeta{Name: tunnelName, Namespace: "default"},
+				Spec:       cloudflarev1beta1.TunnelSpec{Replicas: 1},
+			}
+			err := k8sClient.Create(ctx, tunnel)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating the annotated Service")
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+					Annotations: map[string]string{
+						ServiceHostnameAnnotation: "app.widgetcorp.tech, www.widgetcorp.tech",
+						ServiceTunnelAnnotation:   tunnelName,
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "http", Port: 8080}},
+				},
+			}
+			err = k8sClient.Create(ctx, svc)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			svc := &corev1.Service{}
+			if err := k8sClient.Get(ctx, serviceKey, svc); err == nil {
+				Expect(k8sClient.Delete(ctx, svc)).To(Succeed())
+			}
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			if err := k8sClient.Get(ctx, cloudflareKey, cloudflare); err == nil {
+				cloudflare.Finalizers = nil
+				Expect(k8sClient.Update(ctx, cloudflare)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, cloudflare))).To(Succeed())
+			}
+		})
+
+		It("should expose the Service on the annotated Tunnel", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.Spec.TunnelRef).To(Equal(&cloudflarev1beta1.TunnelReference{Name: tunnelName, Namespace: "default"}))
+			ref := &cloudflarev1beta1.ServiceReference{Name: serviceName, Port: intstr.FromInt32(8080), Scheme: "http"}
+			Expect(cloudflare.Spec.Ingress).To(Equal([]cloudflarev1beta1.IngressRule{
+				{Hostname: "app.widgetcorp.tech", ServiceRef: ref},
+				{Hostname: "www.widgetcorp.tech", ServiceRef: ref},
+			}))
+
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, serviceKey, svc)).To(Succeed())
+			Expect(metav1.IsControlledBy(cloudflare, svc)).To(BeTrue())
+		})
+
+		It("should remove the Cloudflare resource when the annotation is removed", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})).To(Succeed())
+
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, serviceKey, svc)).To(Succeed())
+			delete(svc.Annotations, ServiceHostnameAnnotation)
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, cloudflareKey, &cloudflarev1beta1.Cloudflare{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep the Cloudflare resource and warn when the annotations become invalid", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, serviceKey, svc)).To(Succeed())
+			svc.Annotations[ServiceSchemeAnnotation] = "ftp"
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler.Recorder = recorder
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, cloudflareKey, cloudflare)).To(Succeed())
+			Expect(cloudflare.Spec.Ingress).To(HaveLen(2))
+			Expect(cloudflare.Spec.Ingress[0].ServiceRef.Scheme).To(Equal("http"))
+			Expect(drainEvents(recorder)).To(ContainElement(And(ContainSubstring("InvalidAnnotation"), ContainSubstring(`unsupported scheme "ftp"`))))
+		})
+
+		It("should expose the Service on a Tunnel in another namespace once the Tunnel allows it", func() {
+			otherKey := types.NamespacedName{Name: serviceName, Namespace: "service-apps"}
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: otherKey.Namespace}})).To(Succeed())
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      otherKey.Name,
+					Namespace: otherKey.Namespace,
+					Annotations: map[string]string{
+						ServiceHostnameAnnotation: "apps.widgetcorp.tech",
+						ServiceTunnelAnnotation:   "default/" + tunnelName,
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "http", Port: 8080}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+			otherCloudflareKey := types.NamespacedName{Name: "service-" + serviceName, Namespace: otherKey.Namespace}
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, svc)).To(Succeed())
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &cloudflarev1beta1.Cloudflare{
+					ObjectMeta: metav1.ObjectMeta{Name: otherCloudflareKey.Name, Namespace: otherCloudflareKey.Namespace},
+				}))).To(Succeed())
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: otherKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("reporting the namespace on the generated Cloudflare resource like Ingress and HTTPRoute")
+			cloudflareReconciler := &CloudflareReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err = cloudflareReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: otherCloudflareKey})
+			Expect(err).NotTo(HaveOccurred())
+			cloudflare := &cloudflarev1beta1.Cloudflare{}
+			Expect(k8sClient.Get(ctx, otherCloudflareKey, cloudflare)).To(Succeed())
+			configReady := meta.FindStatusCondition(cloudflare.Status.Conditions, cloudflarev1beta1.TypeConfigReady)
+			Expect(configReady).NotTo(BeNil())
+			Expect(configReady.Status).To(Equal(metav1.ConditionFalse))
+			Expect(configReady.Reason).To(Equal("NamespaceNotAllowed"))
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Get(ctx, otherCloudflareKey, cloudflare))).To(Succeed())
+				cloudflare.Finalizers = nil
+				Expect(client.IgnoreNotFound(k8sClient.Update(ctx, cloudflare))).To(Succeed())
+			})
+
+			By("allowing the namespace on the Tunnel")
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: tunnelName, Namespace: "default"}, tunnel)).To(Succeed())
+			tunnel.Spec.AllowedNamespaces = []string{otherKey.Namespace}
+			Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(tunnel), tunnel)).To(Succeed())
+				tunnel.Spec.AllowedNamespaces = nil
+				Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+			})
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: otherKey})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, otherCloudflareKey, &cloudflarev1beta1.Cloudflare{})).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,458 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+const (
+	tunnelFinalizerName = "tunnel.cloudflare.laininthewired.github.io/finalizer"
+
+	// tunnelTokenKey は credentials Secret のうち、リモート管理の Tunnel で cloudflared に渡すトークンのキーです。
+	tunnelTokenKey = "token"
+)
+
+// TunnelReconciler reconciles a Tunnel object
+type TunnelReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIFactory は Cloudflare API クライアントを生成します。nil の場合は cfapi.NewClient を使います。
+	APIFactory cfapi.ClientFactory
+
+	// TunnelHealthInterval は Tunnel の接続状態を Cloudflare から取り直す間隔です。0 の場合は DefaultTunnelHealthInterval です。
+	TunnelHealthInterval time.Duration
+}
+
+// tunnelCredentials は cloudflared が読み込む credentials.json の内容です。
+type tunnelCredentials struct {
+	AccountTag   string `json:"AccountTag"`
+	TunnelSecret string `json:"TunnelSecret"`
+	TunnelID     string `json:"TunnelID"`
+}
+
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cloudflare.laininthewired.github.io,resources=tunnels/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile は Tunnel リソースに対応する Cloudflare Tunnel と、その credentials Secret のみを管理します。
+// cloudflared の Deployment や ingress 設定は、この Tunnel を参照する側が管理します。
+func (r *TunnelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var tunnel cloudflarev1beta1.Tunnel
+	err := r.Get(ctx, req.NamespacedName, &tunnel)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		logger.Error(err, "unable to get Tunnel", "name", req.NamespacedName)
+		return ctrl.Result{}, err
+	}
+
+	if !tunnel.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeTunnel(ctx, &tunnel)
+	}
+
+	if !controllerutil.ContainsFinalizer(&tunnel, tunnelFinalizerName) {
+		controllerutil.AddFinalizer(&tunnel, tunnelFinalizerName)
+		if err := r.Update(ctx, &tunnel); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	api, accountID, err := newCloudflareAPI(ctx, r.Client, r.APIFactory, tunnel.Namespace, tunnel.Spec.CredentialsSource)
+	meta.SetStatusCondition(&tunnel.Status.Conditions, credentialsCondition(err, tunnel.Generation))
+	if err != nil {
+		return ctrl.Result{}, r.updateStatusFailed(ctx, &tunnel, "CredentialsUnavailable", err)
+	}
+
+	// 以前に作成・再利用した Tunnel が外部から削除されていれば作り直すため、キャッシュを使わずに確かめる
+	findCtx := ctx
+	if tunnel.Status.TunnelID != "" {
+		findCtx = cfapi.Refresh(ctx)
+	}
+	tunnelID, tunnelSecret, err := ensureTunnel(findCtx, api, accountID, cloudflareTunnelName(tunnel), tunnel.Spec.ConfigSource)
+	if err != nil {
+		return ctrl.Result{}, r.updateStatusFailed(ctx, &tunnel, "TunnelError", err)
+	}
+	// 作成したかどうかは ID が変わったときにしか分からないため、以降の失敗で status を書くときにも残す
+	if tunnelID != tunnel.Status.TunnelID {
+		tunnel.Status.TunnelID = tunnelID
+		tunnel.Status.Created = tunnelSecret != ""
+	}
+
+	err = reconcileTunnelCredentials(ctx, r.Client, r.Scheme, &tunnel, tunnelSecretName(tunnel), api, accountID, tunnelID, tunnelSecret, tunnel.Spec.CredentialsRecovery)
+	reason := "SecretError"
+	if cond, ok := tunnelCredentialsCondition(err, tunnel.Generation); ok {
+		meta.SetStatusCondition(&tunnel.Status.Conditions, cond)
+		reason = cloudflarev1beta1.TypeCredentialsUnavailable
+	}
+	if err != nil {
+		return ctrl.Result{}, r.updateStatusFailed(ctx, &tunnel, reason, err)
+	}
+
+	// 状態を取得できなくても Tunnel は使えるため、失敗は TunnelHealthy 条件にだけ記録し、前回の状態を残して取り直す
+	health, healthErr := fetchTunnelHealth(ctx, api, accountID, tunnelID)
+	meta.SetStatusCondition(&tunnel.Status.Conditions, tunnelHealthyCondition(health, healthErr, tunnel.Generation))
+	if healthErr == nil {
+		tunnel.Status.TunnelHealth = health
+	}
+
+	tunnel.Status.CredentialsSecret = tunnelSecretName(tunnel)
+	tunnel.Status.Phase = cloudflarev1beta1.TunnelPhaseReady
+	meta.SetStatusCondition(&tunnel.Status.Conditions, metav1.Condition{
+		Type:               cloudflarev1beta1.TypeTunnelReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "TunnelReady",
+		Message:            fmt.Sprintf("tunnel %s is ready", tunnelID),
+		ObservedGeneration: tunnel.Generation,
+	})
+	if err := r.Status().Update(ctx, &tunnel); err != nil {
+		return ctrl.Result{}, err
+	}
+	if healthErr != nil {
+		return ctrl.Result{}, healthErr
+	}
+
+	return ctrl.Result{RequeueAfter: tunnelHealthInterval(r.TunnelHealthInterval)}, nil
+}
+
+// reconcileTunnelCredentials は owner と同じ namespace に credentials.json とトンネルトークンを持つ Secret を作成し、owner に所有させます。
+// tunnelSecret が空（既存の Tunnel を再利用した場合）で、Secret が無いか別の Tunnel のものであれば、
+// recovery に従って recoverTunnelSecret でシークレットを用意し、credentials.json を作り直します。
+func reconcileTunnelCredentials(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object, secretName string, api cfapi.API, accountID, tunnelID, tunnelSecret string, recovery cloudflarev1beta1.CredentialsRecoveryPolicy) error {
+	logger := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	secret.SetNamespace(owner.GetNamespace())
+	secret.SetName(secretName)
+
+	if tunnelSecret == "" {
+		err := c.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			if stored, ok := storedCredentials(secret); ok && stored.TunnelID == tunnelID {
+				tunnelSecret = stored.TunnelSecret
+			}
+		}
+		if tunnelSecret == "" {
+			tunnelSecret, err = recoverTunnelSecret(ctx, api, accountID, tunnelID, recovery)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	credentials, err := json.Marshal(tunnelCredentials{
+		AccountTag:   accountID,
+		TunnelSecret: tunnelSecret,
+		TunnelID:     tunnelID,
+	})
+	if err != nil {
+		return err
+	}
+	token, err := cfapi.EncodeTunnelToken(cfapi.TunnelToken{
+		AccountTag:   accountID,
+		TunnelID:     tunnelID,
+		TunnelSecret: tunnelSecret,
+	})
+	if err != nil {
+		return err
+	}
+
+	op, err := ctrl.CreateOrUpdate(ctx, c, secret, func() error {
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data["credentials.json"] = credentials
+		secret.Data[tunnelTokenKey] = []byte(token)
+		return ctrl.SetControllerReference(owner, secret, scheme)
+	})
+	if err != nil {
+		logger.Error(err, "unable to create or update Secret")
+		return err
+	}
+
+	if op != controllerutil.OperationResultNone {
+		logger.Info("reconcile Secret successfully", "op", op)
+	}
+	return nil
+}
+
+// tunnelCredentialsError は Tunnel のシークレットを用意できなかったことを表します。
+// Reason は CredentialsUnavailable 条件の Reason に使います。
+type tunnelCredentialsError struct {
+	reason string
+	err    error
+}
+
+func (e *tunnelCredentialsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tunnelCredentialsError) Unwrap() error {
+	return e.err
+}
+
+// recoverTunnelSecret は既存の Tunnel のシークレットをトンネルトークンから取り出します。
+// トークンを取得できず recovery が Rotate の場合は、シークレットを新しく置き換えて返します。
+func recoverTunnelSecret(ctx context.Context, api cfapi.API, accountID, tunnelID string, recovery cloudflarev1beta1.CredentialsRecoveryPolicy) (string, error) {
+	logger := log.FromContext(ctx)
+	rc := cf.AccountIdentifier(accountID)
+
+	token, err := api.GetTunnelToken(ctx, rc, tunnelID)
+	if err == nil {
+		decoded, decodeErr := cfapi.DecodeTunnelToken(token)
+		if decodeErr != nil {
+			return "", &tunnelCredentialsError{reason: "InvalidTunnelToken", err: decodeErr}
+		}
+		logger.Info("recovered tunnel credentials from the tunnel token", "tunnelID", tunnelID)
+		return decoded.TunnelSecret, nil
+	}
+	if recovery != cloudflarev1beta1.CredentialsRecoveryRotate {
+		return "", &tunnelCredentialsError{
+			reason: "TunnelTokenUnavailable",
+			err:    fmt.Errorf("failed to get the token of tunnel %s; set credentialsRecovery to Rotate to replace its secret: %w", tunnelID, err),
+		}
+	}
+
+	tunnelSecret, err := newTunnelSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := api.RotateTunnelSecret(ctx, rc, tunnelID, tunnelSecret); err != nil {
+		return "", &tunnelCredentialsError{
+			reason: "RotationFailed",
+			err:    fmt.Errorf("failed to rotate the secret of tunnel %s: %w", tunnelID, err),
+		}
+	}
+	logger.Info("rotated tunnel secret", "tunnelID", tunnelID)
+	return tunnelSecret, nil
+}
+
+// tunnelCredentialsCondition は credentials.json を用意した結果を CredentialsUnavailable 条件に変換します。
+// シークレットの用意とは関係のないエラーの場合は false を返します。
+func tunnelCredentialsCondition(err error, generation int64) (metav1.Condition, bool) {
+	if err == nil {
+		return metav1.Condition{
+			Type:               cloudflarev1beta1.TypeCredentialsUnavailable,
+			Status:             metav1.ConditionFalse,
+			Reason:             "CredentialsAvailable",
+			ObservedGeneration: generation,
+		}, true
+	}
+	var credsErr *tunnelCredentialsError
+	if !goerrors.As(err, &credsErr) {
+		return metav1.Condition{}, false
+	}
+	return metav1.Condition{
+		Type:               cloudflarev1beta1.TypeCredentialsUnavailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             credsErr.reason,
+		Message:            err.Error(),
+		ObservedGeneration: generation,
+	}, true
+}
+
+// storedCredentials は credentials Secret に格納された credentials.json を返します。読めない場合は false です。
+func storedCredentials(secret *corev1.Secret) (tunnelCredentials, bool) {
+	var creds tunnelCredentials
+	if err := json.Unmarshal(secret.Data["credentials.json"], &creds); err != nil {
+		return tunnelCredentials{}, false
+	}
+	return creds, creds.TunnelSecret != ""
+}
+
+// finalizeTunnel は Cloudflare 上の Tunnel を削除してから Finalizer を外します。
+// 既存の Tunnel を再利用していた場合は、operator が作成したものではないため Cloudflare 上に残します。
+// Secret は OwnerReference によりガベージコレクションされます。
+func (r *TunnelReconciler) finalizeTunnel(ctx context.Context, tunnel *cloudflarev1beta1.Tunnel) error {
+	logger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(tunnel, tunnelFinalizerName) {
+		return nil
+	}
+
+	if tunnel.Status.TunnelID != "" && !tunnel.Status.Created {
+		logger.Info("leaving the adopted tunnel in Cloudflare", "tunnelID", tunnel.Status.TunnelID)
+	} else if tunnel.Status.TunnelID != "" {
+		if tunnel.Status.Phase != cloudflarev1beta1.TunnelPhaseDeleting {
+			tunnel.Status.Phase = cloudflarev1beta1.TunnelPhaseDeleting
+			if err := r.Status().Update(ctx, tunnel); err != nil {
+				return err
+			}
+		}
+
+		api, accountID, err := newCloudflareAPI(ctx, r.Client, r.APIFactory, tunnel.Namespace, tunnel.Spec.CredentialsSource)
+		if err != nil {
+			return err
+		}
+		if err := removeTunnel(ctx, api, accountID, tunnel.Status.TunnelID); err != nil {
+			logger.Error(err, "failed to delete tunnel during finalization")
+			return err
+		}
+		logger.Info("tunnel deleted", "tunnelID", tunnel.Status.TunnelID)
+	}
+
+	controllerutil.RemoveFinalizer(tunnel, tunnelFinalizerName)
+	return r.Update(ctx, tunnel)
+}
+
+// updateStatusFailed は Phase を Failed にし、Ready 条件に失敗理由を記録した上で元のエラーを返します。
+func (r *TunnelReconciler) updateStatusFailed(ctx context.Context, tunnel *cloudflarev1beta1.Tunnel, reason string, cause error) error {
+	logger := log.FromContext(ctx)
+
+	tunnel.Status.Phase = cloudflarev1beta1.TunnelPhaseFailed
+	meta.SetStatusCondition(&tunnel.Status.Conditions, metav1.Condition{
+		Type:               cloudflarev1beta1.TypeTunnelReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            cause.Error(),
+		ObservedGeneration: tunnel.Generation,
+	})
+	if err := r.Status().Update(ctx, tunnel); err != nil {
+		logger.Error(err, "unable to update status")
+	}
+	return cause
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TunnelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cloudflarev1beta1.Tunnel{}).
+		Owns(&corev1.Secret{}).
+		Named("tunnel").
+		Complete(r)
+}
+
+// cloudflareTunnelName は Cloudflare 上の Tunnel 名を返します。spec で省略された場合はリソース名です。
+func cloudflareTunnelName(tunnel cloudflarev1beta1.Tunnel) string {
+	if tunnel.Spec.TunnelName != "" {
+		return tunnel.Spec.TunnelName
+	}
+	return tunnel.Name
+}
+
+// tunnelSecretName は Tunnel の credentials Secret 名を返します。
+func tunnelSecretName(tunnel cloudflarev1beta1.Tunnel) string {
+	return "cloudflare-tunnel-" + tunnel.Name
+}
+
+// ensureTunnel は同名の Tunnel があればそれを再利用し、なければ新規作成します。
+// 新規作成した場合のみ tunnelSecret を返します。既存の Tunnel では一覧 API がシークレットを返さないため空になります。
+func ensureTunnel(ctx context.Context, api cfapi.API, accountID, tunnelName string, source cloudflarev1beta1.ConfigSource) (string, string, error) {
+	tunnelID, err := findTunnel(ctx, api, accountID, tunnelName)
+	if err != nil || tunnelID != "" {
+		return tunnelID, "", err
+	}
+	return createTunnel(ctx, api, accountID, tunnelName, source)
+}
+
+// findTunnel は削除されていない同名の Tunnel の ID を返します。見つからない場合は空です。
+func findTunnel(ctx context.Context, api cfapi.API, accountID, tunnelName string) (string, error) {
+	listparam := cf.TunnelListParams{}
+	tunnels, _, err := api.ListTunnels(ctx, cf.AccountIdentifier(accountID), listparam)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range tunnels {
+		if v.Name == tunnelName && v.DeletedAt == nil {
+			return v.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// createTunnel は Tunnel を新規作成し、その ID とシークレットを返します。
+func createTunnel(ctx context.Context, api cfapi.API, accountID, tunnelName string, source cloudflarev1beta1.ConfigSource) (string, string, error) {
+	tunnelSecret, err := newTunnelSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	rc := cf.AccountIdentifier(accountID)
+	params := cf.TunnelCreateParams{
+		Name:   tunnelName,
+		Secret: tunnelSecret,
+		// Indicates if this is a locally or remotely configured tunnel "local" or "cloudflare"
+		ConfigSrc: configSrc(source),
+	}
+
+	tunnel, err := api.CreateTunnel(ctx, rc, params)
+	if err != nil {
+		return "", "", err
+	}
+	return tunnel.ID, tunnelSecret, nil
+}
+
+// configSrc は ConfigSource を Tunnel 作成 API の config_src に変換します。
+func configSrc(source cloudflarev1beta1.ConfigSource) string {
+	if source == cloudflarev1beta1.ConfigSourceCloudflare {
+		return "cloudflare"
+	}
+	return "local"
+}
+
+// newTunnelSecret は Tunnel 用のランダムな 32 バイトのシークレットを base64 で返します。
+func newTunnelSecret() (string, error) {
+	randSecret := make([]byte, 32)
+	if _, err := rand.Read(randSecret); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(randSecret), nil
+}
+
+// removeTunnel は Tunnel の接続を切断してから削除します。既に存在しない場合は何もしません。
+func removeTunnel(ctx context.Context, api cfapi.API, accountID, tunnelID string) error {
+	rc := cf.AccountIdentifier(accountID)
+
+	if err := api.CleanupTunnelConnections(ctx, rc, tunnelID); err != nil {
+		if cfapi.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := api.DeleteTunnel(ctx, rc, tunnelID); err != nil && !cfapi.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi/fake"
+)
+
+var _ = Describe("Tunnel Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "test-tunnel"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+		tunnel := &cloudflarev1beta1.Tunnel{}
+
+		var (
+			fakeAPI              *fake.API
+			controllerReconciler *TunnelReconciler
+		)
+
+		BeforeEach(func() {
+			fakeAPI = fake.NewAPI()
+			controllerReconciler = &TunnelReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				APIFactory: fakeAPI.Factory(),
+			}
+
+			By("creating the Cloudflare API token Secret")
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cloudflare-api-token",
+					Namespace: "default",
+				},
+				StringData: map[string]string{
+					"apiToken":   "test-token",
+					"account_id": "test-account",
+				},
+			}
+			err := k8sClient.Create(ctx, secret)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating the custom resource for the Kind Tunnel")
+			err = k8sClient.Get(ctx, typeNamespacedName, tunnel)
+			if err != nil && errors.IsNotFound(err) {
+				resource := &cloudflarev1beta1.Tunnel{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      resourceName,
+						Namespace: "default",
+					},
+					Spec: cloudflarev1beta1.TunnelSpec{
+						Replicas: 1,
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			resource := &cloudflarev1beta1.Tunnel{}
+			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Cleanup the specific resource instance Tunnel")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should create the tunnel and its credentials Secret", func() {
+			By("Reconciling the created resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.Phase).To(Equal(cloudflarev1beta1.TunnelPhaseReady))
+			Expect(tunnel.Status.TunnelID).NotTo(BeEmpty())
+			Expect(tunnel.Status.Created).To(BeTrue())
+			created, ok := fakeAPI.Tunnel(tunnel.Status.TunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(created.Name).To(Equal(resourceName))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      tunnel.Status.CredentialsSecret,
+				Namespace: "default",
+			}, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKey("credentials.json"))
+			Expect(metav1.IsControlledBy(secret, tunnel)).To(BeTrue())
+		})
+
+		It("should recover the credentials of an adopted tunnel from its token", func() {
+			By("creating the tunnel in Cloudflare beforehand")
+			existing, err := fakeAPI.CreateTunnel(ctx, cf.AccountIdentifier("test-account"), cf.TunnelCreateParams{
+				Name:   resourceName,
+				Secret: "ZXhpc3Rpbmctc2VjcmV0",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.TunnelID).To(Equal(existing.ID))
+			cond := meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeCredentialsUnavailable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      tunnel.Status.CredentialsSecret,
+				Namespace: "default",
+			}, secret)).To(Succeed())
+			Expect(string(secret.Data["credentials.json"])).To(ContainSubstring("ZXhpc3Rpbmctc2VjcmV0"))
+		})
+
+		It("should rotate the secret of an adopted tunnel only when asked to", func() {
+			existing, err := fakeAPI.CreateTunnel(ctx, cf.AccountIdentifier("test-account"), cf.TunnelCreateParams{
+				Name:   resourceName,
+				Secret: "ZXhpc3Rpbmctc2VjcmV0",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			fakeAPI.DenyTunnelTokens(true)
+
+			By("failing with CredentialsUnavailable while the token cannot be read")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.Phase).To(Equal(cloudflarev1beta1.TunnelPhaseFailed))
+			cond := meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeCredentialsUnavailable)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal("TunnelTokenUnavailable"))
+
+			By("opting in to rotation")
+			tunnel.Spec.CredentialsRecovery = cloudflarev1beta1.CredentialsRecoveryRotate
+			Expect(k8sClient.Update(ctx, tunnel)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			rotated, ok := fakeAPI.Tunnel(existing.ID)
+			Expect(ok).To(BeTrue())
+			Expect(rotated.Secret).NotTo(Equal("ZXhpc3Rpbmctc2VjcmV0"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      tunnel.Status.CredentialsSecret,
+				Namespace: "default",
+			}, secret)).To(Succeed())
+			Expect(string(secret.Data["credentials.json"])).To(ContainSubstring(rotated.Secret))
+		})
+
+		It("should report the health and connections of the tunnel", func() {
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(DefaultTunnelHealthInterval))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.Health).To(Equal("inactive"))
+			cond := meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("Inactive"))
+
+			fakeAPI.SetTunnelConnections(tunnel.Status.TunnelID, []cf.TunnelConnection{
+				{ColoName: "nrt01", ClientVersion: "2025.1.0"},
+				{ColoName: "kix01", ClientVersion: "2025.1.0"},
+				{ColoName: "nrt01", ClientVersion: "2024.12.2"},
+			})
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.TunnelHealth).To(Equal(cloudflarev1beta1.TunnelHealth{
+				Health:      "healthy",
+				Connections: 3,
+				Colos:       []string{"kix01", "nrt01"},
+				Versions:    []string{"2024.12.2", "2025.1.0"},
+			}))
+			Expect(meta.IsStatusConditionTrue(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)).To(BeTrue())
+
+			By("reporting a degraded tunnel")
+			fakeAPI.SetTunnelStatus(tunnel.Status.TunnelID, "degraded")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			cond = meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("Degraded"))
+		})
+
+		It("should keep the tunnel ready when its health cannot be fetched", func() {
+			fakeAPI.FailGetTunnel(true)
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.TunnelID).NotTo(BeEmpty())
+			Expect(tunnel.Status.Phase).To(Equal(cloudflarev1beta1.TunnelPhaseReady))
+			Expect(meta.IsStatusConditionTrue(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelReady)).To(BeTrue())
+			cond := meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionUnknown))
+			Expect(cond.Reason).To(Equal("HealthCheckFailed"))
+
+			By("reporting the health again once Cloudflare answers")
+			fakeAPI.FailGetTunnel(false)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.Health).To(Equal("inactive"))
+		})
+
+		It("should delete the tunnel when the resource is deleted", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			tunnelID := tunnel.Status.TunnelID
+
+			Expect(k8sClient.Delete(ctx, tunnel)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			deleted, ok := fakeAPI.Tunnel(tunnelID)
+			Expect(ok).To(BeTrue())
+			Expect(deleted.DeletedAt).NotTo(BeNil())
+			err = k8sClient.Get(ctx, typeNamespacedName, tunnel)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should leave an adopted tunnel in Cloudflare when the resource is deleted", func() {
+			existing, err := fakeAPI.CreateTunnel(ctx, cf.AccountIdentifier("test-account"), cf.TunnelCreateParams{
+				Name:   resourceName,
+				Secret: "ZXhpc3Rpbmctc2VjcmV0",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			Expect(tunnel.Status.TunnelID).To(Equal(existing.ID))
+			Expect(tunnel.Status.Created).To(BeFalse())
+
+			Expect(k8sClient.Delete(ctx, tunnel)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			adopted, ok := fakeAPI.Tunnel(existing.ID)
+			Expect(ok).To(BeTrue())
+			Expect(adopted.DeletedAt).To(BeNil())
+			err = k8sClient.Get(ctx, typeNamespacedName, tunnel)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+	Context("When the Tunnel references its own credentials", func() {
+		const resourceName = "account-tunnel"
+		const accountName = "test-account"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		var (
+			fakeAPI              *fake.API
+			controllerReconciler *TunnelReconciler
+		)
+
+		reconcileTunnel := func() error {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			return err
+		}
+
+		credentialsReady := func() *metav1.Condition {
+			tunnel := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tunnel)).To(Succeed())
+			return meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeCredentialsReady)
+		}
+
+		BeforeEach(func() {
+			fakeAPI = fake.NewAPI()
+			controllerReconciler = &TunnelReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				APIFactory: fakeAPI.Factory(),
+			}
+
+			By("creating a Tunnel that references a ClusterCloudflareAccount")
+			resource := &cloudflarev1beta1.Tunnel{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: cloudflarev1beta1.TunnelSpec{
+					Replicas: 1,
+					CredentialsSource: cloudflarev1beta1.CredentialsSource{
+						AccountRef: &cloudflarev1beta1.AccountReference{Name: accountName},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			account := &cloudflarev1beta1.ClusterCloudflareAccount{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: accountName}, account); err == nil {
+				Expect(k8sClient.Delete(ctx, account)).To(Succeed())
+			}
+
+			resource := &cloudflarev1beta1.Tunnel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			controllerutil.RemoveFinalizer(resource, tunnelFinalizerName)
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		createAccount := func(allowedNamespaces ...string) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "account-api-key",
+					Namespace: "default",
+				},
+				StringData: map[string]string{
+					"apiKey": "test-key",
+				},
+			}
+			err := k8sClient.Create(ctx, secret)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			account := &cloudflarev1beta1.ClusterCloudflareAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: accountName},
+				Spec: cloudflarev1beta1.ClusterCloudflareAccountSpec{
+					AccountID:         "other-account",
+					APIKeySecretRef:   &cloudflarev1beta1.SecretKeyReference{Name: "account-api-key", Namespace: "default"},
+					Email:             "admin@widgetcorp.tech",
+					AllowedNamespaces: allowedNamespaces,
+				},
+			}
+			Expect(k8sClient.Create(ctx, account)).To(Succeed())
+		}
+
+		It("should report a missing account as a condition", func() {
+			Expect(reconcileTunnel()).NotTo(Succeed())
+
+			cond := credentialsReady()
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("AccountNotFound"))
+		})
+
+		It("should reject namespaces the account does not allow", func() {
+			createAccount("team-a")
+			Expect(reconcileTunnel()).NotTo(Succeed())
+
+			cond := credentialsReady()
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal("NamespaceNotAllowed"))
+		})
+
+		It("should create the tunnel with the account's credentials", func() {
+			createAccount()
+			Expect(reconcileTunnel()).To(Succeed())
+
+			cond := credentialsReady()
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(fakeAPI.Credentials()).To(Equal(cfapi.Credentials{
+				APIKey:    "test-key",
+				Email:     "admin@widgetcorp.tech",
+				AccountID: "other-account",
+			}))
+		})
+	})
+})
@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cf "github.com/cloudflare/cloudflare-go"
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+// DefaultTunnelHealthInterval は Tunnel の接続状態を Cloudflare から取り直す既定の間隔です。
+const DefaultTunnelHealthInterval = time.Minute
+
+// Cloudflare が報告する Tunnel の状態です。
+const (
+	tunnelHealthHealthy  = "healthy"
+	tunnelHealthDegraded = "degraded"
+	tunnelHealthDown     = "down"
+	tunnelHealthInactive = "inactive"
+)
+
+// tunnelHealthInterval は接続状態を取り直す間隔を返します。0 以下の場合は DefaultTunnelHealthInterval です。
+func tunnelHealthInterval(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return DefaultTunnelHealthInterval
+	}
+	return interval
+}
+
+// fetchTunnelHealth は Tunnel の状態と接続を Cloudflare から取得します。
+func fetchTunnelHealth(ctx context.Context, api cfapi.API, accountID, tunnelID string) (cloudflarev1beta1.TunnelHealth, error) {
+	tunnel, err := api.GetTunnel(ctx, cf.AccountIdentifier(accountID), tunnelID)
+	if err != nil {
+		return cloudflarev1beta1.TunnelHealth{}, fmt.Errorf("failed to get the status of tunnel %s: %w", tunnelID, err)
+	}
+	return tunnelHealthFor(tunnel), nil
+}
+
+// tunnelHealthFor は Tunnel の接続から接続数と、重複を除いて並べたデータセンターと cloudflared のバージョンを集計します。
+func tunnelHealthFor(tunnel cf.Tunnel) cloudflarev1beta1.TunnelHealth {
+	health := cloudflarev1beta1.TunnelHealth{
+		Health:      tunnel.Status,
+		Connections: int32(len(tunnel.Connections)),
+	}
+	for _, conn := range tunnel.Connections {
+		if conn.ColoName != "" && !slices.Contains(health.Colos, conn.ColoName) {
+			health.Colos = append(health.Colos, conn.ColoName)
+		}
+		if conn.ClientVersion != "" && !slices.Contains(health.Versions, conn.ClientVersion) {
+			health.Versions = append(health.Versions, conn.ClientVersion)
+		}
+	}
+	slices.Sort(health.Colos)
+	slices.Sort(health.Versions)
+	return health
+}
+
+// tunnelHealthyCondition は Tunnel の状態を TunnelHealthy 条件に変換します。
+// 状態を取得できなかった場合や、未知の状態の場合は Unknown です。
+func tunnelHealthyCondition(health cloudflarev1beta1.TunnelHealth, err error, generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               cloudflarev1beta1.TypeTunnelHealthy,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = "HealthCheckFailed"
+		cond.Message = err.Error()
+		return cond
+	}
+
+	connections := fmt.Sprintf("%d connections", health.Connections)
+	if len(health.Colos) > 0 {
+		connections += " to " + strings.Join(health.Colos, ", ")
+	}
+	switch health.Health {
+	case tunnelHealthHealthy:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "Healthy"
+		cond.Message = fmt.Sprintf("tunnel is healthy with %s", connections)
+	case tunnelHealthDegraded:
+		cond.Reason = "Degraded"
+		cond.Message = fmt.Sprintf("tunnel is degraded with %s", connections)
+	case tunnelHealthDown:
+		cond.Reason = "Down"
+		cond.Message = "tunnel is down"
+	case tunnelHealthInactive:
+		cond.Reason = "Inactive"
+		cond.Message = "no cloudflared has connected to the tunnel yet"
+	default:
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = "UnknownHealth"
+		cond.Message = fmt.Sprintf("tunnel reported an unknown status %q", health.Health)
+	}
+	return cond
+}
+
+// tunnelHealth は Cloudflare リソースが使う Tunnel の接続状態と TunnelHealthy 条件を返します。
+// 共有 Tunnel の場合は Tunnel の status を写し、tunnel_name の場合は Cloudflare から取得します。
+// 取得に失敗した場合は前回の接続状態を残します。
+func (r *CloudflareReconciler) tunnelHealth(ctx context.Context, cloudflare *cloudflarev1beta1.Cloudflare, conn connector) (cloudflarev1beta1.TunnelHealth, metav1.Condition) {
+	if tunnel, ok := conn.owner.(*cloudflarev1beta1.Tunnel); ok {
+		cond := metav1.Condition{
+			Type:               cloudflarev1beta1.TypeTunnelHealthy,
+			Status:             metav1.ConditionUnknown,
+			Reason:             "HealthNotReported",
+			Message:            fmt.Sprintf("Tunnel %s/%s has not reported its health yet", tunnel.Namespace, tunnel.Name),
+			ObservedGeneration: cloudflare.Generation,
+		}
+		if reported := meta.FindStatusCondition(tunnel.Status.Conditions, cloudflarev1beta1.TypeTunnelHealthy); reported != nil {
+			cond.Status = reported.Status
+			cond.Reason = reported.Reason
+			cond.Message = reported.Message
+		}
+		return tunnel.Status.TunnelHealth, cond
+	}
+
+	api, accountID, err := r.cloudflareAPI(ctx, cloudflare)
+	if err != nil {
+		return cloudflare.Status.TunnelHealth, tunnelHealthyCondition(cloudflarev1beta1.TunnelHealth{}, err, cloudflare.Generation)
+	}
+	health, err := fetchTunnelHealth(ctx, api, accountID, conn.tunnelID)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to get tunnel health", "tunnelID", conn.tunnelID)
+		r.eventsFor(cloudflare).warning(eventTunnelError, "Failed to get the health of tunnel %s: %v", conn.tunnelID, err)
+		return cloudflare.Status.TunnelHealth, tunnelHealthyCondition(health, err, cloudflare.Generation)
+	}
+	return health, tunnelHealthyCondition(health, nil, cloudflare.Generation)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
+)
+
+// zoneResolver は ingress ルールのホスト名を、DNS レコードを作成するゾーンの ID に解決します。
+// アカウントのゾーン一覧は最初の解決時に 1 度だけ取得し、Reconcile の間は使い回します。
+// 同じトークンで他のアカウントのゾーンが見えても、認証情報のアカウントに属するゾーンだけを使います。
+type zoneResolver struct {
+	api       cfapi.API
+	accountID string
+	// zones はゾーン名（小文字）からゾーン ID への対応です。nil の間は未取得です。
+	zones map[string]string
+}
+
+func newZoneResolver(api cfapi.API, accountID string) *zoneResolver {
+	return &zoneResolver{api: api, accountID: accountID}
+}
+
+// zoneID はルールの zoneID、zone、ホスト名の順にゾーンを決めます。
+// zoneID と zone はアカウントのゾーンにあり、ルールのホスト名を含むものだけを受け付けます。
+// ホスト名からは、"co.uk" のような複数ラベルの公開サフィックスや委任したサブゾーンも正しく扱えるよう、
+// ラベルを 1 つずつ外しながらアカウントに存在する最も長いゾーンを探します。
+func (z *zoneResolver) zoneID(ctx context.Context, rule cloudflarev1beta1.IngressRule) (string, error) {
+	if err := z.load(ctx); err != nil {
+		return "", err
+	}
+	if rule.ZoneID != "" {
+		zone := z.zoneName(ctx, rule.ZoneID)
+		if zone == "" {
+			return "", fmt.Errorf("zone ID %s not found in the account", rule.ZoneID)
+		}
+		if !hostnameInZone(rule.Hostname, zone) {
+			return "", fmt.Errorf("zone %s (%s) does not contain hostname %s", zone, rule.ZoneID, rule.Hostname)
+		}
+		return rule.ZoneID, nil
+	}
+	if rule.Zone != "" {
+		id, ok := z.zones[normalizeDNSName(rule.Zone)]
+		if !ok {
+			return "", fmt.Errorf("zone %s not found in the account", rule.Zone)
+		}
+		if !hostnameInZone(rule.Hostname, rule.Zone) {
+			return "", fmt.Errorf("zone %s does not contain hostname %s", rule.Zone, rule.Hostname)
+		}
+		return id, nil
+	}
+
+	name := strings.TrimPrefix(normalizeDNSName(rule.Hostname), "*.")
+	for name != "" {
+		if id, ok := z.zones[name]; ok {
+			return id, nil
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	return "", fmt.Errorf("no zone in the account contains hostname %s", rule.Hostname)
+}
+
+// zoneName はゾーン ID のゾーン名を返します。アカウントのゾーンに無い場合は空です。
+func (z *zoneResolver) zoneName(ctx context.Context, zoneID string) string {
+	if err := z.load(ctx); err != nil {
+		return ""
+	}
+	for name, id := range z.zones {
+		if id == zoneID {
+			return name
+		}
+	}
+	return ""
+}
+
+func (z *zoneResolver) load(ctx context.Context) error {
+	if z.zones != nil {
+		return nil
+	}
+	zones, err := z.api.ListAccountZones(ctx, z.accountID)
+	if err != nil {
+		return fmt.Errorf("failed to list zones: %w", err)
+	}
+	z.zones = make(map[string]string, len(zones))
+	for _, zone := range zones {
+		z.zones[normalizeDNSName(zone.Name)] = zone.ID
+	}
+	return nil
+}
+
+// hostnameInZone はホスト名がゾーンの頂点かその配下にあるかを返します。
+func hostnameInZone(hostname, zone string) bool {
+	hostname, zone = normalizeDNSName(hostname), normalizeDNSName(zone)
+	return hostname == zone || strings.HasSuffix(hostname, "."+zone)
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -19,20 +19,22 @@ package v1beta1
 import (
 	"context"
 	"fmt"
+	"path"
+	"regexp"
 	"strings"
 
-	corev1 "k8s.io/api/core/v1"
-
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	"github.com/cloudflare/cloudflare-go"
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi"
 )
 
 // nolint:unused
@@ -40,8 +42,9 @@ import (
 var cloudflarelog = logf.Log.WithName("cloudflare-resource")
 
 // SetupCloudflareWebhookWithManager registers the webhook for Cloudflare in the manager.
-func SetupCloudflareWebhookWithManager(mgr ctrl.Manager) error {
-	validator := &CloudflareCustomValidator{}
+// apiFactory が nil の場合は cfapi.NewClient を使います。
+func SetupCloudflareWebhookWithManager(mgr ctrl.Manager, apiFactory cfapi.ClientFactory) error {
+	validator := &CloudflareCustomValidator{APIFactory: apiFactory}
 	if err := validator.InjectClient(mgr.GetClient()); err != nil {
 		return err
 	}
@@ -94,6 +97,9 @@ func (d *CloudflareCustomDefaulter) Default(ctx context.Context, obj runtime.Obj
 type CloudflareCustomValidator struct {
 	// TODO(user): Add more fields as needed for validation
 	Client client.Client
+
+	// APIFactory は Cloudflare API クライアントを生成します。nil の場合は cfapi.NewClient を使います。
+	APIFactory cfapi.ClientFactory
 }
 
 var _ webhook.CustomValidator = &CloudflareCustomValidator{}
@@ -105,7 +111,6 @@ func (v *CloudflareCustomValidator) InjectClient(c client.Client) error {
 }
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Cloudflare.
-// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the Cloudflare type.
 func (v *CloudflareCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	// オブジェクトの型アサーション
 	cf, ok := obj.(*cloudflarev1beta1.Cloudflare)
@@ -115,57 +120,35 @@ func (v *CloudflareCustomValidator) ValidateCreate(ctx context.Context, obj runt
 
 	cloudflarelog.Info("Validation for Cloudflare upon creation", "name", cf.GetName())
 
-	// Secret から Cloudflare API の認証情報を取得する
-	secret := &corev1.Secret{}
-	secretName := "cloudflare-api-token"
-	secretNamespace := "default" // 必要に応じて調整
-	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: secretNamespace, Name: secretName}, secret); err != nil {
-		return nil, fmt.Errorf("failed to get secret %s/%s: %w", secretNamespace, secretName, err)
+	// ingress ルールの originRequest を検証する
+	warnings, err := validateCloudflareIngress(cf)
+	if err != nil {
+		return warnings, err
 	}
 
-	apiTokenBytes, ok := secret.Data["apiToken"]
-	if !ok {
-		return nil, fmt.Errorf("secret %s/%s does not contain key 'apiToken'", secretNamespace, secretName)
-	}
-	accountIDBytes, ok := secret.Data["account_id"]
-	if !ok {
-		return nil, fmt.Errorf("secret %s/%s does not contain key 'account_id'", secretNamespace, secretName)
+	// コントローラと同じく、secretRef・accountRef・tunnelRef の Tunnel の順に認証情報を解決する
+	// 同名の Tunnel が既に存在する場合はコントローラが再利用するため、名前の重複は拒否しない
+	namespace, src, err := cfapi.CloudflareCredentialsSource(ctx, v.Client, cf)
+	if err != nil {
+		return warnings, err
 	}
-	apiToken := strings.TrimSpace(string(apiTokenBytes))
-	accountID := strings.TrimSpace(string(accountIDBytes))
-
-	// Cloudflare API クライアントの生成
-	cfAPI, err := cloudflare.NewWithAPIToken(apiToken)
+	creds, err := cfapi.ResolveCredentials(ctx, v.Client, namespace, src)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Cloudflare API client: %w", err)
+		return warnings, err
 	}
 
-	// 既存のトンネル一覧を取得して、同じ TunnelName が既に存在しないかチェック
-	tunnels, _, err := cfAPI.ListTunnels(ctx, cloudflare.AccountIdentifier(accountID), cloudflare.TunnelListParams{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list tunnels: %w", err)
+	// Cloudflare API クライアントの生成
+	newAPI := v.APIFactory
+	if newAPI == nil {
+		newAPI = cfapi.NewClient
 	}
-	for _, t := range tunnels {
-		if t.Name == cf.Spec.TunnelName && t.DeletedAt == nil {
-			return nil, fmt.Errorf("tunnel name %q is already in use", cf.Spec.TunnelName)
-		}
+	if _, err := newAPI(creds); err != nil {
+		return warnings, err
 	}
 
-	return nil, nil
+	return warnings, nil
 }
 
-// func (v *CloudflareCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
-// 	cloudflare, ok := obj.(*cloudflarev1beta1.Cloudflare)
-// 	if !ok {
-// 		return nil, fmt.Errorf("expected a Cloudflare object but got %T", obj)
-// 	}
-// 	cloudflarelog.Info("Validation for Cloudflare upon creation", "name", cloudflare.GetName())
-
-// 	// TODO(user): fill in your validation logic upon object creation.
-
-// 	return nil, nil
-// }
-
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Cloudflare.
 func (v *CloudflareCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
 	cloudflare, ok := newObj.(*cloudflarev1beta1.Cloudflare)
@@ -174,9 +157,7 @@ func (v *CloudflareCustomValidator) ValidateUpdate(ctx context.Context, oldObj,
 	}
 	cloudflarelog.Info("Validation for Cloudflare upon update", "name", cloudflare.GetName())
 
-	// TODO(user): fill in your validation logic upon object update.
-
-	return nil, nil
+	return validateCloudflareIngress(cloudflare)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Cloudflare.
@@ -191,3 +172,107 @@ func (v *CloudflareCustomValidator) ValidateDelete(ctx context.Context, obj runt
 
 	return nil, nil
 }
+
+// validateCloudflareIngress は ingress ルールの並びと内容、spec.originRequest と各ルールの originRequest を検証します。
+func validateCloudflareIngress(cf *cloudflarev1beta1.Cloudflare) (admission.Warnings, error) {
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	w, errs := validateOriginRequest(specPath.Child("originRequest"), cf.Spec.OriginRequest)
+	warnings = append(warnings, w...)
+	allErrs = append(allErrs, errs...)
+	for i, rule := range cf.Spec.Ingress {
+		rulePath := specPath.Child("ingress").Index(i)
+		allErrs = append(allErrs, validateIngressRule(rulePath, rule)...)
+
+		// hostname が無いか "*" で path も無いルールは全てのリクエストに一致するため、cloudflared は後続のルールを受け付けない
+		if (rule.Hostname == "" || rule.Hostname == "*") && rule.Path == "" {
+			if i != len(cf.Spec.Ingress)-1 {
+				allErrs = append(allErrs, field.Invalid(rulePath, rule.Service, "a catch-all rule without a path and with an empty or \"*\" hostname must be the last rule"))
+			} else if cf.Spec.Fallback != nil {
+				allErrs = append(allErrs, field.Invalid(rulePath, rule.Service, "a catch-all rule cannot be combined with spec.fallback"))
+			} else if cf.Spec.TunnelRef != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: catch-all rules are ignored on a shared Tunnel; set fallback on the Tunnel instead", rulePath))
+			}
+		}
+
+		w, errs := validateOriginRequest(rulePath.Child("originRequest"), rule.OriginRequest)
+		warnings = append(warnings, w...)
+		allErrs = append(allErrs, errs...)
+	}
+
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(cloudflarev1beta1.GroupVersion.WithKind("Cloudflare").GroupKind(), cf.Name, allErrs)
+}
+
+// validateIngressRule は cloudflared が受け付けないホスト名とパスを弾きます。
+func validateIngressRule(fldPath *field.Path, rule cloudflarev1beta1.IngressRule) field.ErrorList {
+	var allErrs field.ErrorList
+	if strings.Contains(rule.Hostname, ":") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostname"), rule.Hostname, "hostname must not contain a port"))
+	}
+	// "*" だけのホスト名は全てのホスト名に一致する
+	if wildcard := strings.LastIndex(rule.Hostname, "*"); rule.Hostname != "*" && (wildcard > 0 || (wildcard == 0 && !strings.HasPrefix(rule.Hostname, "*."))) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostname"), rule.Hostname, "a wildcard is only allowed as the leftmost label, like *.example.com, or as the whole hostname"))
+	}
+	if rule.Path != "" {
+		if _, err := regexp.Compile(rule.Path); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), rule.Path, err.Error()))
+		}
+	}
+	if rule.Zone != "" && rule.Hostname != "" {
+		zone := strings.ToLower(strings.TrimSuffix(rule.Zone, "."))
+		host := strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(rule.Hostname, ".")), "*.")
+		if host != zone && !strings.HasSuffix(host, "."+zone) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("zone"), rule.Zone, "hostname must be within the zone"))
+		}
+	}
+	return allErrs
+}
+
+// validateOriginRequest は cloudflared が受け付けない originRequest の値を弾きます。
+func validateOriginRequest(fldPath *field.Path, o *cloudflarev1beta1.OriginRequest) (admission.Warnings, field.ErrorList) {
+	if o == nil {
+		return nil, nil
+	}
+	var warnings admission.Warnings
+	var allErrs field.ErrorList
+
+	for _, d := range []struct {
+		name  string
+		value *metav1.Duration
+	}{
+		{"connectTimeout", o.ConnectTimeout},
+		{"tlsTimeout", o.TLSTimeout},
+		{"tcpKeepAlive", o.TCPKeepAlive},
+		{"keepAliveTimeout", o.KeepAliveTimeout},
+	} {
+		if d.value != nil && d.value.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(d.name), d.value.Duration.String(), "must be a positive duration"))
+		}
+	}
+	if o.KeepAliveConnections != nil && *o.KeepAliveConnections < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("keepAliveConnections"), *o.KeepAliveConnections, "must not be negative"))
+	}
+	if o.CAPool != nil && !path.IsAbs(*o.CAPool) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("caPool"), *o.CAPool, "must be an absolute path inside the cloudflared container"))
+	}
+	if o.ProxyType != nil && *o.ProxyType != "socks" {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("proxyType"), *o.ProxyType, []string{"socks"}))
+	}
+	if o.Access != nil {
+		if o.Access.TeamName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("access", "teamName"), ""))
+		}
+		if o.Access.Required && len(o.Access.AudTag) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("access", "audTag"), "required when access.required is true"))
+		}
+	}
+	if o.NoTLSVerify != nil && *o.NoTLSVerify && (o.CAPool != nil || o.OriginServerName != nil) {
+		warnings = append(warnings, fmt.Sprintf("%s: caPool and originServerName are ignored when noTLSVerify is true", fldPath))
+	}
+	return warnings, allErrs
+}
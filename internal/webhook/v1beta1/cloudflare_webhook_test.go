@@ -17,8 +17,14 @@ limitations under the License.
 package v1beta1
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
 	// TODO (user): Add any additional imports if needed
@@ -82,6 +88,176 @@ var _ = Describe("Cloudflare Webhook", func() {
 		//     obj.SomeRequiredField = "updated_value"
 		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
 		// })
+
+		Context("resolving the credentials upon creation", func() {
+			BeforeEach(func() {
+				validator = CloudflareCustomValidator{Client: k8sClient, APIFactory: fakeAPI.Factory()}
+				obj.Name = "widgetcorp"
+				obj.Namespace = "default"
+				obj.Spec.TunnelName = "widgetcorp"
+				obj.Spec.Ingress = []cloudflarev1beta1.IngressRule{{Hostname: "app.widgetcorp.tech", Service: "http://app:80"}}
+
+				By("creating the Secret, the ClusterCloudflareAccount and the shared Tunnel")
+				for _, o := range []client.Object{
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "webhook-api-token", Namespace: "default"},
+						StringData: map[string]string{"apiToken": "test-token", "account_id": "test-account"},
+					},
+					&cloudflarev1beta1.ClusterCloudflareAccount{
+						ObjectMeta: metav1.ObjectMeta{Name: "webhook-account"},
+						Spec: cloudflarev1beta1.ClusterCloudflareAccountSpec{
+							AccountID:         "test-account",
+							APITokenSecretRef: &cloudflarev1beta1.SecretKeyReference{Name: "webhook-api-token", Namespace: "default"},
+							AllowedNamespaces: []string{"default"},
+						},
+					},
+					&cloudflarev1beta1.Tunnel{
+						ObjectMeta: metav1.ObjectMeta{Name: "webhook-shared", Namespace: "default"},
+						Spec: cloudflarev1beta1.TunnelSpec{
+							Replicas:          1,
+							CredentialsSource: cloudflarev1beta1.CredentialsSource{AccountRef: &cloudflarev1beta1.AccountReference{Name: "webhook-account"}},
+						},
+					},
+				} {
+					Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, o))).To(Succeed())
+				}
+			})
+
+			It("Should admit a Cloudflare resource whose secretRef resolves", func() {
+				obj.Spec.SecretRef = &cloudflarev1beta1.SecretReference{Name: "webhook-api-token"}
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			})
+
+			It("Should deny a Cloudflare resource whose Secret does not exist", func() {
+				obj.Spec.SecretRef = &cloudflarev1beta1.SecretReference{Name: "not-created-yet"}
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("secret default/not-created-yet not found")))
+			})
+
+			It("Should resolve the credentials of a ClusterCloudflareAccount", func() {
+				obj.Spec.AccountRef = &cloudflarev1beta1.AccountReference{Name: "webhook-account"}
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+				By("denying a namespace the account does not allow")
+				obj.Namespace = "kube-public"
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("namespace kube-public is not allowed to use ClusterCloudflareAccount webhook-account")))
+			})
+
+			It("Should resolve the credentials of the Tunnel referenced by tunnelRef", func() {
+				obj.Spec.TunnelName = ""
+				obj.Spec.TunnelRef = &cloudflarev1beta1.TunnelReference{Name: "webhook-shared"}
+				Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+				By("denying a namespace the Tunnel does not allow")
+				obj.Namespace = "kube-public"
+				obj.Spec.TunnelRef.Namespace = "default"
+				_, err := validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("namespace kube-public is not allowed to use the credentials of Tunnel default/webhook-shared")))
+
+				By("denying a Tunnel that does not exist")
+				obj.Spec.TunnelRef.Name = "not-created-yet"
+				_, err = validator.ValidateCreate(ctx, obj)
+				Expect(err).To(MatchError(ContainSubstring("tunnel default/not-created-yet to take the credentials from not found")))
+			})
+		})
+
+		It("Should admit originRequest settings that cloudflared accepts", func() {
+			obj.Spec.OriginRequest = &cloudflarev1beta1.OriginRequest{
+				ConnectTimeout: &metav1.Duration{Duration: 30 * time.Second},
+				CAPool:         ptr.To("/etc/cloudflared/ca/ca.crt"),
+			}
+			obj.Spec.Ingress = []cloudflarev1beta1.IngressRule{{
+				Hostname: "app.widgetcorp.tech",
+				Service:  "https://app:443",
+				OriginRequest: &cloudflarev1beta1.OriginRequest{
+					Access: &cloudflarev1beta1.OriginAccess{Required: true, TeamName: "widgetcorp", AudTag: []string{"aud"}},
+				},
+			}}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny invalid originRequest settings on a rule", func() {
+			obj.Spec.Ingress = []cloudflarev1beta1.IngressRule{{
+				Hostname: "app.widgetcorp.tech",
+				Service:  "https://app:443",
+				OriginRequest: &cloudflarev1beta1.OriginRequest{
+					ConnectTimeout: &metav1.Duration{Duration: -time.Second},
+					CAPool:         ptr.To("ca.crt"),
+					Access:         &cloudflarev1beta1.OriginAccess{Required: true, TeamName: "widgetcorp"},
+				},
+			}}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ingress[0].originRequest.connectTimeout"))
+			Expect(err.Error()).To(ContainSubstring("spec.ingress[0].originRequest.caPool"))
+			Expect(err.Error()).To(ContainSubstring("spec.ingress[0].originRequest.access.audTag"))
+		})
+
+		DescribeTable("placing a catch-all rule",
+			func(rules []cloudflarev1beta1.IngressRule, message string) {
+				obj.Spec.Ingress = rules
+				_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+				if message == "" {
+					Expect(err).NotTo(HaveOccurred())
+					return
+				}
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("admits a rule without a hostname at the end", []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.widgetcorp.tech", Service: "http://app:80"},
+				{Service: "http_status:404"},
+			}, ""),
+			Entry("admits a rule for any hostname at the end", []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.widgetcorp.tech", Service: "http://app:80"},
+				{Hostname: "*", Service: "http_status:404"},
+			}, ""),
+			Entry("admits a rule for any hostname with a path before other rules", []cloudflarev1beta1.IngressRule{
+				{Hostname: "*", Path: "^/healthz$", Service: "http://health:80"},
+				{Hostname: "app.widgetcorp.tech", Service: "http://app:80"},
+			}, ""),
+			Entry("denies a rule without a hostname before other rules", []cloudflarev1beta1.IngressRule{
+				{Service: "http_status:404"},
+				{Hostname: "app.widgetcorp.tech", Service: "http://app:80"},
+			}, "must be the last rule"),
+			Entry("denies a rule for any hostname before other rules", []cloudflarev1beta1.IngressRule{
+				{Hostname: "*", Service: "http_status:404"},
+				{Hostname: "app.widgetcorp.tech", Service: "http://app:80"},
+			}, "must be the last rule"),
+			Entry("denies a wildcard that is not the leftmost label", []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.*.widgetcorp.tech", Service: "http://app:80"},
+			}, "a wildcard is only allowed as the leftmost label"),
+		)
+
+		It("Should deny a path that is not a valid regular expression", func() {
+			obj.Spec.Ingress = []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.widgetcorp.tech", Path: "^/api/(", Service: "http://app:80"},
+			}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ingress[0].path"))
+		})
+
+		It("Should deny a zone that does not contain the hostname", func() {
+			obj.Spec.Ingress = []cloudflarev1beta1.IngressRule{
+				{Hostname: "app.dev.widgetcorp.tech", Zone: "dev.widgetcorp.tech", Service: "http://app:80"},
+				{Hostname: "app.widgetcorp.net", Zone: "widgetcorp.tech", Service: "http://app:80"},
+			}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.ingress[1].zone"))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.ingress[0].zone"))
+		})
+
+		It("Should warn when noTLSVerify makes other TLS settings meaningless", func() {
+			obj.Spec.OriginRequest = &cloudflarev1beta1.OriginRequest{
+				NoTLSVerify:      ptr.To(true),
+				OriginServerName: ptr.To("app.internal"),
+			}
+			warnings, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+		})
 	})
 
 })
@@ -31,6 +31,7 @@ import (
 
 	admissionv1 "k8s.io/api/admission/v1"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -41,6 +42,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	cloudflarev1beta1 "github.com/laininthewired/cloudflare-ingress-controller/api/v1beta1"
+	"github.com/laininthewired/cloudflare-ingress-controller/internal/cfapi/fake"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -53,6 +55,7 @@ var (
 	k8sClient client.Client
 	cfg       *rest.Config
 	testEnv   *envtest.Environment
+	fakeAPI   *fake.API
 )
 
 func TestAPIs(t *testing.T) {
@@ -68,6 +71,9 @@ var _ = BeforeSuite(func() {
 
 	var err error
 	scheme := apimachineryruntime.NewScheme()
+	err = clientgoscheme.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	err = cloudflarev1beta1.AddToScheme(scheme)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -114,7 +120,8 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupCloudflareWebhookWithManager(mgr)
+	fakeAPI = fake.NewAPI()
+	err = SetupCloudflareWebhookWithManager(mgr, fakeAPI.Factory())
 	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:webhook